@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+)
+
+var groupsListCommand = &command{
+	name:    "groups list",
+	args:    "[-limit n]",
+	summary: "List the most recently created groups",
+	setup: func(flags *flag.FlagSet) runFunc {
+		limit := flags.Int("limit", 50, "maximum number of groups to list")
+		return func(ctx context.Context, a *app, args []string) error {
+			groups, err := a.groups.GetAll(ctx)
+			if err != nil {
+				return err
+			}
+			if len(groups) > *limit {
+				groups = groups[:*limit]
+			}
+			return a.printGroups(groups)
+		}
+	},
+}
+
+var groupsSearchCommand = &command{
+	name:    "groups search",
+	args:    "[-limit n] <text>",
+	nargs:   1,
+	summary: "Find groups by ID or by part of their name",
+	setup: func(flags *flag.FlagSet) runFunc {
+		limit := flags.Int("limit", 50, "maximum number of groups to list")
+		return func(ctx context.Context, a *app, args []string) error {
+			groups, err := a.groups.Search(ctx, args[0], *limit)
+			if err != nil {
+				return err
+			}
+			return a.printGroups(groups)
+		}
+	},
+}
+
+var groupsEventsCommand = &command{
+	name:    "groups events",
+	args:    "[-table] <group_id>",
+	nargs:   1,
+	summary: "Dump the event log of a group as JSON lines, oldest first",
+	setup: func(flags *flag.FlagSet) runFunc {
+		table := flags.Bool("table", false, "print a table without payloads instead of JSON")
+		return func(ctx context.Context, a *app, args []string) error {
+			events, err := a.groupEvents(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			if !*table {
+				encoder := json.NewEncoder(a.out)
+				for _, event := range events {
+					if err := encoder.Encode(event); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "HLC\tCREATED AT\tEVENT ID\tTYPE\tUSER ID\tLINKED EVENT ID")
+			for _, event := range events {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", event.HLC, event.CreatedAt.Format("2006-01-02 15:04:05 MST"), event.EventID, event.EventType, event.UserID, event.LinkedEventID)
+			}
+			return w.Flush()
+		}
+	},
+}
+
+var groupsBalancesCommand = &command{
+	name:    "groups balances",
+	args:    "<group_id>",
+	nargs:   1,
+	summary: "Recompute the balances of a group from its events and how to settle them",
+	setup: func(flags *flag.FlagSet) runFunc {
+		return func(ctx context.Context, a *app, args []string) error {
+			events, err := a.groupEvents(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			balances := services.ComputeBalances(events)
+			name := a.userNames(ctx)
+
+			currencies := make([]string, 0, len(balances))
+			for currency := range balances {
+				currencies = append(currencies, currency)
+			}
+			sort.Strings(currencies)
+
+			w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "CURRENCY\tUSER\tBALANCE")
+			for _, currency := range currencies {
+				userIDs := make([]string, 0, len(balances[currency]))
+				for userID := range balances[currency] {
+					userIDs = append(userIDs, userID)
+				}
+				sort.Strings(userIDs)
+				for _, userID := range userIDs {
+					fmt.Fprintf(w, "%s\t%s\t%.2f\n", currency, name(userID), balances[currency][userID])
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			plan := balances.SettlementPlan()
+			if len(plan) == 0 {
+				fmt.Fprintln(a.out, "\nThe group is settled up.")
+				return nil
+			}
+			fmt.Fprintln(a.out, "\nSettlement plan:")
+			for _, settlement := range plan {
+				fmt.Fprintf(a.out, "  %s pays %s %.2f %s\n", name(settlement.FromUserID), name(settlement.ToUserID), settlement.Amount, settlement.Currency)
+			}
+			return nil
+		}
+	},
+}
+
+var groupsDeleteCommand = &command{
+	name:    "groups delete",
+	args:    "[-yes] <group_id>",
+	nargs:   1,
+	summary: "Delete a group with its events and webhooks",
+	setup: func(flags *flag.FlagSet) runFunc {
+		yes := flags.Bool("yes", false, "delete instead of only showing what would be deleted")
+		return func(ctx context.Context, a *app, args []string) error {
+			if !*yes {
+				group, err := a.groups.GetByID(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				events, err := a.groupEvents(ctx, group.GroupID)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(a.out, "Would delete group %s (%s) and its %d events.\n", group.Name, group.GroupID, len(events))
+				fmt.Fprintln(a.out, "Run again with -yes to delete.")
+				return nil
+			}
+
+			deleted, err := a.groups.DeleteWithEvents(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(a.out, "Deleted group %s and %d events\n", args[0], deleted)
+			return nil
+		}
+	},
+}
+
+// groupEvents returns the events of a group in replay order, earliest HLC first
+func (a *app) groupEvents(ctx context.Context, groupID string) ([]*domain.Event, error) {
+	events, err := a.events.GetByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(events)
+	return events, nil
+}
+
+// userNames returns a function naming users for output, falling back to the ID
+func (a *app) userNames(ctx context.Context) func(userID string) string {
+	names := make(map[string]string)
+	return func(userID string) string {
+		if name, ok := names[userID]; ok {
+			return name
+		}
+		name := userID
+		if user, err := a.users.GetByID(ctx, userID); err == nil {
+			name = fmt.Sprintf("%s (%s)", user.Name, userID)
+		}
+		names[userID] = name
+		return name
+	}
+}
+
+// printGroups writes groups as a table
+func (a *app) printGroups(groups []*domain.Group) error {
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP ID\tNAME\tVERSION\tCREATED AT")
+	for _, group := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", group.GroupID, group.Name, group.Version, group.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,153 @@
+// Command simplesplit-admin is the operator tool for a Simple Split database. It reads
+// the same configuration as the server, from CONFIG_FILE and the environment.
+//
+//	simplesplit-admin users list [-limit n]
+//	simplesplit-admin groups balances <group_id>
+//	simplesplit-admin notify <user_id>
+//
+// Run it without arguments for every command.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	// Import postgres driver
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// app holds what the commands work with
+type app struct {
+	cfg    *config.Config
+	users  *repository.UserRepository
+	groups *repository.GroupRepository
+	events *repository.EventRepository
+	out    io.Writer
+}
+
+// runFunc runs a command with the arguments left after the flags
+type runFunc func(ctx context.Context, a *app, args []string) error
+
+// command is a subcommand, named by one or two words
+type command struct {
+	name string
+	args string
+	// nargs is the number of arguments the command takes after its flags
+	nargs   int
+	summary string
+	// setup declares the flags of the command and returns the function running it
+	setup func(flags *flag.FlagSet) runFunc
+}
+
+var commands = []*command{
+	usersListCommand,
+	usersSearchCommand,
+	usersResetFirebaseTokenCommand,
+	usersMergeCommand,
+	groupsListCommand,
+	groupsSearchCommand,
+	groupsEventsCommand,
+	groupsBalancesCommand,
+	groupsDeleteCommand,
+	notifyCommand,
+}
+
+func main() {
+	// Repository logging goes to stderr and only when something is wrong, stdout is the command output
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	cmd, args := findCommand(os.Args[1:])
+	if cmd == nil {
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: simplesplit-admin %s %s\n", cmd.name, cmd.args)
+		flags.PrintDefaults()
+	}
+	run := cmd.setup(flags)
+	if err := flags.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	if flags.NArg() != cmd.nargs {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fatalf("failed to load configuration: %v", err)
+	}
+
+	// Merging and searching rely on Postgres SQL
+	if backend := cfg.DatabaseBackend(); backend != config.BackendPostgres {
+		fatalf("only Postgres databases are supported, DATABASE_URL selects the %s backend", backend)
+	}
+
+	db, err := sql.Open("pgx", cfg.Database.URL)
+	if err != nil {
+		fatalf("failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := db.PingContext(ctx); err != nil {
+		fatalf("failed to ping database %s: %v", cfg.Redacted().Database.URL, err)
+	}
+
+	database := util.NewDatabase(db)
+	a := &app{
+		cfg:    cfg,
+		users:  repository.NewUserRepository(database),
+		groups: repository.NewGroupRepository(database),
+		events: repository.NewEventRepository(database),
+		out:    os.Stdout,
+	}
+
+	if err := run(ctx, a, flags.Args()); err != nil {
+		db.Close()
+		fatalf("%v", err)
+	}
+}
+
+// findCommand returns the command named by the first arguments and the remaining arguments
+func findCommand(args []string) (*command, []string) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: simplesplit-admin <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-52s %s\n", cmd.name+" "+cmd.args, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Flags go before the arguments. The database is configured like the server, e.g. with DATABASE_URL.")
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "simplesplit-admin: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+)
+
+var notifyCommand = &command{
+	name:    "notify",
+	args:    "[-title text] [-body text] <user_id>",
+	nargs:   1,
+	summary: "Send a test push notification to a user",
+	setup: func(flags *flag.FlagSet) runFunc {
+		title := flags.String("title", "Simple Split", "notification title")
+		body := flags.String("body", "This is a test notification", "notification body")
+		return func(ctx context.Context, a *app, args []string) error {
+			if !a.cfg.PushEnabled() {
+				return errors.New("push notifications are not configured, set FIREBASE_URL and GOOGLE_SERVICE_ACCOUNT")
+			}
+
+			firebase := services.NewFirebaseService(a.users, a.cfg.Firebase)
+			if err := firebase.SendNotificationToUser(ctx, args[0], *title, *body, map[string]string{"type": "TEST"}); err != nil {
+				return err
+			}
+			fmt.Fprintf(a.out, "Sent a test notification to user %s\n", args[0])
+			return nil
+		}
+	},
+}
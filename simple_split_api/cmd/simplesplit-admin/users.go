@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+)
+
+var usersListCommand = &command{
+	name:    "users list",
+	args:    "[-limit n]",
+	summary: "List the most recently created users",
+	setup: func(flags *flag.FlagSet) runFunc {
+		limit := flags.Int("limit", 50, "maximum number of users to list")
+		return func(ctx context.Context, a *app, args []string) error {
+			users, err := a.users.GetAll(ctx)
+			if err != nil {
+				return err
+			}
+			if len(users) > *limit {
+				users = users[:*limit]
+			}
+			return a.printUsers(users)
+		}
+	},
+}
+
+var usersSearchCommand = &command{
+	name:    "users search",
+	args:    "[-limit n] <text>",
+	nargs:   1,
+	summary: "Find users by ID, or by part of their name or email",
+	setup: func(flags *flag.FlagSet) runFunc {
+		limit := flags.Int("limit", 50, "maximum number of users to list")
+		return func(ctx context.Context, a *app, args []string) error {
+			users, err := a.users.Search(ctx, args[0], *limit)
+			if err != nil {
+				return err
+			}
+			return a.printUsers(users)
+		}
+	},
+}
+
+var usersResetFirebaseTokenCommand = &command{
+	name:    "users reset-firebase-token",
+	args:    "<user_id>",
+	nargs:   1,
+	summary: "Remove a user's Firebase token, the app registers a new one on next start",
+	setup: func(flags *flag.FlagSet) runFunc {
+		return func(ctx context.Context, a *app, args []string) error {
+			if err := a.users.UpdateFirebaseID(ctx, args[0], ""); err != nil {
+				return err
+			}
+			fmt.Fprintf(a.out, "Removed the Firebase token of user %s\n", args[0])
+			return nil
+		}
+	},
+}
+
+var usersMergeCommand = &command{
+	name:    "users merge",
+	args:    "[-yes] <from_user_id> <into_user_id>",
+	nargs:   2,
+	summary: "Move everything of one user to another and delete the first",
+	setup: func(flags *flag.FlagSet) runFunc {
+		yes := flags.Bool("yes", false, "merge instead of only showing what would be merged")
+		return func(ctx context.Context, a *app, args []string) error {
+			from, err := a.users.GetByID(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			into, err := a.users.GetByID(ctx, args[1])
+			if err != nil {
+				return err
+			}
+
+			if !*yes {
+				groups, err := a.groups.GetByUserID(ctx, from.UserID)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(a.out, "Would merge %s (%s), a member of %d groups, into %s (%s).\n", from.Name, from.UserID, len(groups), into.Name, into.UserID)
+				fmt.Fprintln(a.out, "Run again with -yes to merge.")
+				return nil
+			}
+
+			result, err := a.users.Merge(ctx, from.UserID, into.UserID)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(a.out, "Merged %s into %s: %d events moved, %d event payloads rewritten, %d webhooks moved, %d groups changed\n",
+				from.UserID, into.UserID, result.EventsMoved, result.PayloadsRewritten, result.WebhooksMoved, result.GroupsChanged)
+			return nil
+		}
+	},
+}
+
+// printUsers writes users as a table
+func (a *app) printUsers(users []*domain.User) error {
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USER ID\tNAME\tEMAIL\tPUSH\tCREATED AT")
+	for _, user := range users {
+		email := user.Email.String
+		if user.EmailUnsubscribed {
+			email += " (unsubscribed)"
+		}
+		push := "no"
+		if user.FirebaseID.Valid && user.FirebaseID.String != "" {
+			push = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", user.UserID, user.Name, email, push, user.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/RealZimboGuy/budgetApp/internal/migrations"
+)
+
+// runMigrate implements the `migrate` subcommand:
+//
+//	simplesplit migrate [up] [-dry-run]
+//	simplesplit migrate status
+func runMigrate(ctx context.Context, db *sql.DB, dialect migrations.Dialect, args []string) error {
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "up", "status":
+			action = args[0]
+			args = args[1:]
+		default:
+			return fmt.Errorf("unknown migrate action %q, want up or status", args[0])
+		}
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the pending migrations without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments to migrate %s: %s", action, strings.Join(flags.Args(), " "))
+	}
+
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	if action == "status" {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			if status.Modified {
+				state = "applied (modified since)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	ran, err := migrator.Up(ctx, *dryRun)
+	for _, migration := range ran {
+		if *dryRun {
+			fmt.Printf("-- %04d_%s.sql (pending)\n%s\n", migration.Version, migration.Name, migration.SQL)
+		} else {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(ran) == 0 {
+		fmt.Println("Schema is up to date")
+	}
+
+	return nil
+}
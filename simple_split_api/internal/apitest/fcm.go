@@ -0,0 +1,123 @@
+package apitest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+)
+
+// fakeAccessToken is handed out by the fake token endpoint and required by the send endpoint
+const fakeAccessToken = "fake-access-token"
+
+// serviceAccount is a service account JSON key shared by every fake, generating
+// an RSA key is slow enough to be worth doing once
+var serviceAccount = sync.OnceValue(func() string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	account, err := json.Marshal(services.ServiceAccount{
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		ClientEmail: "apitest@simplesplit.iam.gserviceaccount.com",
+	})
+	if err != nil {
+		panic(err)
+	}
+	return string(account)
+})
+
+// FakeFCM stands in for Google's OAuth token endpoint and the FCM send endpoint,
+// recording every message it is asked to send
+type FakeFCM struct {
+	// SendURL and TokenURL are the addresses to configure in place of Google's
+	SendURL  string
+	TokenURL string
+	// ServiceAccount is a service account key accepted by the fake
+	ServiceAccount string
+
+	mu       sync.Mutex
+	messages []services.FirebaseMessage
+}
+
+// NewFakeFCM starts a fake FCM server, it is stopped when the test ends
+func NewFakeFCM(t *testing.T) *FakeFCM {
+	t.Helper()
+
+	fcm := &FakeFCM{ServiceAccount: serviceAccount()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", fcm.token)
+	mux.HandleFunc("POST /send", fcm.send)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	fcm.SendURL = server.URL + "/send"
+	fcm.TokenURL = server.URL + "/token"
+	return fcm
+}
+
+// Messages returns the messages sent so far, in the order they arrived
+func (f *FakeFCM) Messages() []services.FirebaseMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]services.FirebaseMessage(nil), f.messages...)
+}
+
+// Tokens returns the device tokens messages were sent to, in the order they arrived
+func (f *FakeFCM) Tokens() []string {
+	var tokens []string
+	for _, message := range f.Messages() {
+		tokens = append(tokens, message.Token)
+	}
+	return tokens
+}
+
+// token answers the exchange of a signed JWT for an access token
+func (f *FakeFCM) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || strings.Count(r.PostForm.Get("assertion"), ".") != 2 {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": fakeAccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+// send records a message
+func (f *FakeFCM) send(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+fakeAccessToken {
+		http.Error(w, `{"error":{"status":"UNAUTHENTICATED"}}`, http.StatusUnauthorized)
+		return
+	}
+
+	var request services.FCMRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Message.Token == "" {
+		http.Error(w, `{"error":{"status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.messages = append(f.messages, request.Message)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"name": "projects/apitest/messages/" + request.Message.Token})
+}
@@ -0,0 +1,269 @@
+// Package apitest runs the API on an httptest server for end-to-end tests. A
+// Harness serves the routes from controllers.NewRouter on fresh stores, sends
+// push notifications to a FakeFCM and talks to the API through pkg/client:
+//
+//	h := apitest.New(t, config.BackendMemory)
+//	user := h.CreateUser("Ann")
+//	group := h.CreateGroup("Trip")
+//	h.CreateExpense(group, user, user)
+//	h.Wait()
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/controllers"
+	"github.com/RealZimboGuy/budgetApp/internal/migrations"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/repository/memory"
+	"github.com/RealZimboGuy/budgetApp/internal/repository/sqlite"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/client"
+)
+
+// Backends are the storage backends a harness runs on without an external database
+var Backends = []string{config.BackendMemory, config.BackendSQLite}
+
+// Harness is a running API with its stores and fake FCM server
+type Harness struct {
+	t *testing.T
+	// Server serves the API, Client is a client of it that does not retry
+	Server *httptest.Server
+	Client *client.Client
+	// Stores are the stores behind the API, for setting up and checking data directly
+	Stores repository.Stores
+	FCM    *FakeFCM
+	Config *config.Config
+
+	background *util.Background
+}
+
+// Option changes the configuration of the API before it starts
+type Option func(cfg *config.Config)
+
+// WithPageSize sets the number of events returned per page
+func WithPageSize(pageSize int) Option {
+	return func(cfg *config.Config) {
+		cfg.Limits.EventsPageSize = pageSize
+	}
+}
+
+// WithWebhooks enables the webhook routes
+func WithWebhooks() Option {
+	return func(cfg *config.Config) {
+		cfg.Features.Webhooks = true
+	}
+}
+
+// WithSMTP sends emails to a fake SMTP server
+func WithSMTP(smtp *FakeSMTP) Option {
+	return func(cfg *config.Config) {
+		cfg.Features.Email = true
+		cfg.SMTP.Host = smtp.Host
+		cfg.SMTP.Port = smtp.Port
+	}
+}
+
+// New starts the API on empty stores of the given backend, one of Backends. The
+// server is stopped and its background work waited for when the test ends.
+func New(t *testing.T, backend string, opts ...Option) *Harness {
+	t.Helper()
+
+	fcm := NewFakeFCM(t)
+
+	cfg := config.Default()
+	cfg.Firebase.URL = fcm.SendURL
+	cfg.Firebase.TokenURL = fcm.TokenURL
+	cfg.Firebase.ServiceAccount = fcm.ServiceAccount
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var stores repository.Stores
+	var db *util.Database
+	switch backend {
+	case config.BackendMemory:
+		cfg.Database.URL = config.MemoryDatabaseURL
+		stores = memory.New().Stores()
+	case config.BackendSQLite:
+		cfg.Database.URL = "sqlite://" + filepath.Join(t.TempDir(), "simplesplit.db")
+		db = openSQLite(t, cfg.SQLitePath())
+		stores = sqlite.NewStores(db)
+	default:
+		t.Fatalf("apitest: unsupported backend %q", backend)
+	}
+
+	background := util.NewBackground()
+	router := controllers.NewRouter(stores, db, cfg, background)
+	server := httptest.NewServer(router.SetupRoutes())
+	t.Cleanup(func() {
+		server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := background.Shutdown(ctx); err != nil {
+			t.Errorf("apitest: background work did not finish: %v", err)
+		}
+	})
+
+	return &Harness{
+		t:          t,
+		Server:     server,
+		Client:     client.New(server.URL, client.WithRetry(1, 0, 0)),
+		Stores:     stores,
+		FCM:        fcm,
+		Config:     cfg,
+		background: background,
+	}
+}
+
+// openSQLite opens and migrates a SQLite database, closing it when the test ends
+func openSQLite(t *testing.T, path string) *util.Database {
+	t.Helper()
+
+	db, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, migrations.SQLite)
+	if err != nil {
+		t.Fatalf("apitest: failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), false); err != nil {
+		t.Fatalf("apitest: failed to migrate: %v", err)
+	}
+
+	return &util.Database{DB: db, System: sqlite.System}
+}
+
+// Wait blocks until the notifications, emails and webhook deliveries started by
+// earlier requests have been sent
+func (h *Harness) Wait() {
+	h.background.Wait()
+}
+
+// Do sends a request to the API, encoding body as JSON unless it is nil, and
+// returns the response status with its body
+func (h *Harness) Do(method string, path string, body any) (int, []byte) {
+	h.t.Helper()
+	resp, respBody := h.DoWithHeader(method, path, nil, body)
+	return resp.StatusCode, respBody
+}
+
+// DoWithHeader is Do with extra request headers, returning the whole response
+// for checking its headers. The response body has already been read.
+func (h *Harness) DoWithHeader(method string, path string, header http.Header, body any) (*http.Response, []byte) {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("apitest: failed to encode body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+path, reader)
+	if err != nil {
+		h.t.Fatalf("apitest: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("apitest: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("apitest: %s %s: failed to read response: %v", method, path, err)
+	}
+	return resp, respBody
+}
+
+// CreateUser creates a user
+func (h *Harness) CreateUser(name string) *client.User {
+	h.t.Helper()
+
+	user, err := h.Client.CreateUser(context.Background(), name)
+	if err != nil {
+		h.t.Fatalf("apitest: failed to create user: %v", err)
+	}
+	return user
+}
+
+// CreateUserWithToken creates a user with a registered push token
+func (h *Harness) CreateUserWithToken(name string, token string) *client.User {
+	h.t.Helper()
+
+	user := h.CreateUser(name)
+	if err := h.Client.RegisterFirebaseToken(context.Background(), user.UserID, token); err != nil {
+		h.t.Fatalf("apitest: failed to register push token: %v", err)
+	}
+	return user
+}
+
+// CreateGroup creates a group
+func (h *Harness) CreateGroup(name string) *client.Group {
+	h.t.Helper()
+
+	group, err := h.Client.CreateGroup(context.Background(), name)
+	if err != nil {
+		h.t.Fatalf("apitest: failed to create group: %v", err)
+	}
+	return group
+}
+
+// CreateEvent records an event created by user in group
+func (h *Harness) CreateEvent(group *client.Group, user *client.User, eventType util.EventType, payload any) *client.Event {
+	h.t.Helper()
+
+	event, err := client.NewEvent(group.GroupID, user.UserID, client.EventType(eventType), payload)
+	if err != nil {
+		h.t.Fatalf("apitest: %v", err)
+	}
+	created, err := h.Client.CreateEvent(context.Background(), event)
+	if err != nil {
+		h.t.Fatalf("apitest: failed to create event: %v", err)
+	}
+	return created
+}
+
+// Expense returns the payload of a 10.00 EUR expense paid by paidBy and split
+// equally between paidFor
+func Expense(paidBy *client.User, paidFor ...*client.User) events.ExpenseCreated {
+	expense := events.ExpenseCreated{
+		Description: "Dinner",
+		DateTime:    "2024-01-01T19:00:00Z",
+		SplitType:   "EQUAL",
+		Currency:    "EUR",
+		Total:       10,
+		PaidBy:      []events.PaidBy{{UserID: paidBy.UserID, Amount: 10}},
+	}
+	for _, user := range paidFor {
+		expense.PaidFor = append(expense.PaidFor, events.PaidFor{UserID: user.UserID, Amount: 10 / float64(len(paidFor))})
+	}
+	return expense
+}
+
+// CreateExpense records an EXPENSE_CREATED event for an Expense paid by paidBy
+func (h *Harness) CreateExpense(group *client.Group, paidBy *client.User, paidFor ...*client.User) *client.Event {
+	h.t.Helper()
+	return h.CreateEvent(group, paidBy, util.ExpenseCreated, Expense(paidBy, paidFor...))
+}
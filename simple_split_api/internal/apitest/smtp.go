@@ -0,0 +1,110 @@
+package apitest
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeSMTP is a minimal SMTP server recording the messages it receives. With
+// Stall set it accepts connections but never answers, like a stuck server.
+type FakeSMTP struct {
+	// Host and Port are the address to configure as the SMTP server
+	Host string
+	Port int
+
+	listener net.Listener
+	stall    bool
+
+	mu       sync.Mutex
+	messages []*mail.Message
+	conns    []net.Conn
+}
+
+// NewFakeSMTP starts a fake SMTP server, it is stopped when the test ends
+func NewFakeSMTP(t *testing.T, stall bool) *FakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("apitest: failed to listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	s := &FakeSMTP{listener: listener, stall: stall, Host: host}
+	s.Port, _ = strconv.Atoi(port)
+
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+	})
+	return s
+}
+
+// Messages returns the messages received so far
+func (s *FakeSMTP) Messages() []*mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*mail.Message(nil), s.messages...)
+}
+
+func (s *FakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		if !s.stall {
+			go s.session(conn)
+		}
+	}
+}
+
+// session answers one SMTP session, accepting every sender and recipient
+func (s *FakeSMTP) session(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost fake SMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			if message, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data))); err == nil {
+				s.mu.Lock()
+				s.messages = append(s.messages, message)
+				s.mu.Unlock()
+			}
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
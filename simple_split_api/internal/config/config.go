@@ -0,0 +1,563 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the API server.
+//
+// Settings are loaded from the defaults below, then from the optional file named
+// by CONFIG_FILE (YAML or JSON, picked by extension), then from environment
+// variables named in the env tags. Fields tagged secret are redacted when printed.
+type Config struct {
+	Server   ServerConfig   `json:"server" yaml:"server"`
+	Database DatabaseConfig `json:"database" yaml:"database"`
+	Logging  LogConfig      `json:"logging" yaml:"logging"`
+	Tracing  TracingConfig  `json:"tracing" yaml:"tracing"`
+	Firebase FirebaseConfig `json:"firebase" yaml:"firebase"`
+	SMTP     SMTPConfig     `json:"smtp" yaml:"smtp"`
+	Webhooks WebhookConfig  `json:"webhooks" yaml:"webhooks"`
+	Features FeatureConfig  `json:"features" yaml:"features"`
+	Limits   LimitConfig    `json:"limits" yaml:"limits"`
+}
+
+// ServerConfig holds the HTTP server settings
+type ServerConfig struct {
+	Port int `json:"port" yaml:"port" env:"PORT"`
+	// PublicBaseURL is the externally reachable address of the API, used in links sent to users
+	PublicBaseURL   string   `json:"public_base_url" yaml:"public_base_url" env:"PUBLIC_BASE_URL"`
+	ReadTimeout     Duration `json:"read_timeout" yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    Duration `json:"write_timeout" yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout     Duration `json:"idle_timeout" yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+}
+
+// DatabaseConfig holds the database connection settings
+type DatabaseConfig struct {
+	URL             string   `json:"url" yaml:"url" env:"DATABASE_URL" secret:"url"`
+	MaxOpenConns    int      `json:"max_open_conns" yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int      `json:"max_idle_conns" yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	MigrateOnStart  bool     `json:"migrate_on_start" yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	// Level is one of debug, info, warn or error
+	Level string `json:"level" yaml:"level" env:"LOG_LEVEL"`
+	// BodyLimit is the number of bytes of request and response bodies included in logs
+	BodyLimit int `json:"body_limit" yaml:"body_limit" env:"LOG_BODY_LIMIT"`
+	// SuccessSampleRate is the fraction of successful requests that are logged, failures are always logged
+	SuccessSampleRate float64 `json:"success_sample_rate" yaml:"success_sample_rate" env:"LOG_SUCCESS_SAMPLE_RATE"`
+	// RedactFields are JSON fields and query parameters whose values are replaced in logs
+	RedactFields []string `json:"redact_fields" yaml:"redact_fields" env:"LOG_REDACT_FIELDS"`
+}
+
+// TracingConfig holds the trace export settings
+type TracingConfig struct {
+	// Exporter is one of none, stdout or otlp
+	Exporter string `json:"exporter" yaml:"exporter" env:"TRACING_EXPORTER"`
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector, e.g. http://localhost:4318
+	OTLPEndpoint string `json:"otlp_endpoint" yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName  string `json:"service_name" yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	// SampleRatio is the fraction of new traces that are recorded, between 0 and 1
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// FirebaseConfig holds the push notification settings
+type FirebaseConfig struct {
+	URL string `json:"url" yaml:"url" env:"FIREBASE_URL"`
+	// TokenURL is where the service account's signed JWT is exchanged for an access token
+	TokenURL string `json:"token_url" yaml:"token_url" env:"FIREBASE_TOKEN_URL"`
+	// ServiceAccount is the JSON key of the Google service account used to authenticate with FCM
+	ServiceAccount string `json:"service_account" yaml:"service_account" env:"GOOGLE_SERVICE_ACCOUNT" secret:"true"`
+}
+
+// SMTPConfig holds the email settings
+type SMTPConfig struct {
+	Host     string `json:"host" yaml:"host" env:"SMTP_HOST"`
+	Port     int    `json:"port" yaml:"port" env:"SMTP_PORT"`
+	Username string `json:"username" yaml:"username" env:"SMTP_USERNAME"`
+	Password string `json:"password" yaml:"password" env:"SMTP_PASSWORD" secret:"true"`
+	From     string `json:"from" yaml:"from" env:"SMTP_FROM"`
+	// Timeout bounds sending one email, from connecting to the end of the session
+	Timeout Duration `json:"timeout" yaml:"timeout" env:"SMTP_TIMEOUT"`
+}
+
+// WebhookConfig holds the webhook delivery settings
+type WebhookConfig struct {
+	Timeout        Duration `json:"timeout" yaml:"timeout" env:"WEBHOOK_TIMEOUT"`
+	MaxAttempts    int      `json:"max_attempts" yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	InitialBackoff Duration `json:"initial_backoff" yaml:"initial_backoff" env:"WEBHOOK_INITIAL_BACKOFF"`
+	// DisableAfterFailures deactivates a webhook whose last n deliveries failed, 0 never does
+	DisableAfterFailures int `json:"disable_after_failures" yaml:"disable_after_failures" env:"WEBHOOK_DISABLE_AFTER_FAILURES"`
+	// AllowPrivateTargets lets webhooks point at loopback, private and link-local
+	// addresses, for development only
+	AllowPrivateTargets bool `json:"allow_private_targets" yaml:"allow_private_targets" env:"WEBHOOK_ALLOW_PRIVATE_TARGETS"`
+}
+
+// FeatureConfig switches optional features on and off
+type FeatureConfig struct {
+	// PushNotifications also needs firebase.url to be set
+	PushNotifications bool `json:"push_notifications" yaml:"push_notifications" env:"FEATURE_PUSH_NOTIFICATIONS"`
+	// Email also needs smtp.host to be set
+	Email    bool `json:"email" yaml:"email" env:"FEATURE_EMAIL"`
+	Webhooks bool `json:"webhooks" yaml:"webhooks" env:"FEATURE_WEBHOOKS"`
+}
+
+// LimitConfig holds request and response size limits
+type LimitConfig struct {
+	EventsPageSize      int   `json:"events_page_size" yaml:"events_page_size" env:"EVENTS_PAGE_SIZE"`
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes" yaml:"max_request_body_bytes" env:"MAX_REQUEST_BODY_BYTES"`
+	// IdempotencyKeyTTL is how long the response to a request with an Idempotency-Key is replayed
+	IdempotencyKeyTTL Duration `json:"idempotency_key_ttl" yaml:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+	// IdempotencyLease is how long a key stays held by a request that has not completed.
+	// It must exceed the longest a request takes, a key held longer is taken to be abandoned.
+	IdempotencyLease Duration `json:"idempotency_lease" yaml:"idempotency_lease" env:"IDEMPOTENCY_LEASE"`
+	// MaxClockDrift is how far ahead of the server's clock the HLC of a new event may be
+	MaxClockDrift Duration `json:"max_clock_drift" yaml:"max_clock_drift" env:"MAX_CLOCK_DRIFT"`
+}
+
+// Database backends, selected by the scheme of the database URL
+const (
+	// BackendPostgres is used for postgres:// URLs and anything not matched below
+	BackendPostgres = "postgres"
+	// BackendSQLite keeps the data in the file named by a sqlite: URL
+	BackendSQLite = "sqlite"
+	// BackendMemory keeps the data in process memory, for development
+	BackendMemory = "memory"
+)
+
+// MemoryDatabaseURL keeps all data in process memory instead of Postgres, for
+// development. Nothing survives a restart.
+const MemoryDatabaseURL = "memory://"
+
+// DatabaseBackend returns the backend selected by the database URL
+func (c *Config) DatabaseBackend() string {
+	switch {
+	case c.Database.URL == MemoryDatabaseURL:
+		return BackendMemory
+	case strings.HasPrefix(c.Database.URL, "sqlite:"):
+		return BackendSQLite
+	default:
+		return BackendPostgres
+	}
+}
+
+// SQLitePath returns the database file of a sqlite: URL. sqlite:///var/lib/simplesplit.db
+// names an absolute path, sqlite://simplesplit.db and sqlite:simplesplit.db relative ones.
+func (c *Config) SQLitePath() string {
+	return strings.TrimPrefix(strings.TrimPrefix(c.Database.URL, "sqlite:"), "//")
+}
+
+// PushEnabled reports whether push notifications should be sent
+func (c *Config) PushEnabled() bool {
+	return c.Features.PushNotifications && c.Firebase.URL != ""
+}
+
+// EmailEnabled reports whether emails should be sent
+func (c *Config) EmailEnabled() bool {
+	return c.Features.Email && c.SMTP.Host != ""
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			PublicBaseURL:   "http://localhost:8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(120 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			MigrateOnStart:  true,
+		},
+		Logging: LogConfig{
+			Level:             "info",
+			BodyLimit:         2048,
+			SuccessSampleRate: 1,
+			RedactFields: []string{
+				"token", "firebase_id", "firebase_token", "secret", "password",
+				"email", "unsubscribe_token", "authorization", "private_key", "service_account",
+				// Event payloads hold the expenses of a group
+				"payload",
+			},
+		},
+		Tracing: TracingConfig{
+			Exporter:     "none",
+			OTLPEndpoint: "http://localhost:4318",
+			ServiceName:  "simplesplit",
+			SampleRatio:  1,
+		},
+		Firebase: FirebaseConfig{
+			TokenURL: "https://oauth2.googleapis.com/token",
+		},
+		SMTP: SMTPConfig{
+			Port:    25,
+			From:    "Simple Split <no-reply@localhost>",
+			Timeout: Duration(30 * time.Second),
+		},
+		Webhooks: WebhookConfig{
+			Timeout:              Duration(10 * time.Second),
+			MaxAttempts:          5,
+			InitialBackoff:       Duration(2 * time.Second),
+			DisableAfterFailures: 10,
+		},
+		Features: FeatureConfig{
+			PushNotifications: true,
+			Email:             true,
+			Webhooks:          true,
+		},
+		Limits: LimitConfig{
+			EventsPageSize:      1000,
+			MaxRequestBodyBytes: 1 << 20,
+			IdempotencyKeyTTL:   Duration(24 * time.Hour),
+			IdempotencyLease:    Duration(time.Minute),
+			MaxClockDrift:       Duration(5 * time.Minute),
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional CONFIG_FILE and the environment
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overlays the settings found in a YAML or JSON file
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	default:
+		return fmt.Errorf("unsupported config file type: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides every field that has an env tag and a matching environment variable
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(name)
+		if name == "" || !ok || raw == "" {
+			continue
+		}
+
+		if err := setValue(value, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", value.Type())
+		}
+		// Lists are comma separated in the environment
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+// Validate checks that the settings are usable, reporting every problem at once
+func (c *Config) Validate() error {
+	var problems []error
+
+	if c.Database.URL == "" {
+		problems = append(problems, errors.New("database.url (DATABASE_URL) is required"))
+	} else if c.DatabaseBackend() == BackendSQLite && c.SQLitePath() == "" {
+		problems = append(problems, errors.New("database.url (DATABASE_URL) must name a file, e.g. sqlite:///var/lib/simplesplit.db"))
+	}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		problems = append(problems, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if u, err := url.Parse(c.Server.PublicBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Errorf("server.public_base_url must be an absolute URL, got %q", c.Server.PublicBaseURL))
+	}
+	for name, d := range map[string]Duration{
+		"server.read_timeout":      c.Server.ReadTimeout,
+		"server.write_timeout":     c.Server.WriteTimeout,
+		"server.idle_timeout":      c.Server.IdleTimeout,
+		"server.shutdown_timeout":  c.Server.ShutdownTimeout,
+		"webhooks.timeout":         c.Webhooks.Timeout,
+		"webhooks.initial_backoff": c.Webhooks.InitialBackoff,
+		"smtp.timeout":             c.SMTP.Timeout,
+	} {
+		if d <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if c.Database.MaxOpenConns <= 0 {
+		problems = append(problems, errors.New("database.max_open_conns must be positive"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		problems = append(problems, errors.New("database.max_idle_conns must be between 0 and database.max_open_conns"))
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Errorf("logging.level must be debug, info, warn or error, got %q", c.Logging.Level))
+	}
+	if c.Logging.BodyLimit < 0 {
+		problems = append(problems, errors.New("logging.body_limit must not be negative"))
+	}
+	if c.Logging.SuccessSampleRate < 0 || c.Logging.SuccessSampleRate > 1 {
+		problems = append(problems, fmt.Errorf("logging.success_sample_rate must be between 0 and 1, got %g", c.Logging.SuccessSampleRate))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Errorf("tracing.otlp_endpoint must be an absolute URL, got %q", c.Tracing.OTLPEndpoint))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %g", c.Tracing.SampleRatio))
+	}
+	if c.PushEnabled() {
+		var account struct {
+			PrivateKey  string `json:"private_key"`
+			ClientEmail string `json:"client_email"`
+		}
+		if c.Firebase.ServiceAccount == "" {
+			problems = append(problems, errors.New("firebase.service_account (GOOGLE_SERVICE_ACCOUNT) is required when firebase.url is set"))
+		} else if err := json.Unmarshal([]byte(c.Firebase.ServiceAccount), &account); err != nil || account.PrivateKey == "" || account.ClientEmail == "" {
+			problems = append(problems, errors.New("firebase.service_account must be a service account JSON key with private_key and client_email"))
+		}
+		if u, err := url.Parse(c.Firebase.TokenURL); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Errorf("firebase.token_url must be an absolute URL, got %q", c.Firebase.TokenURL))
+		}
+	}
+	if c.EmailEnabled() && (c.SMTP.Port <= 0 || c.SMTP.Port > 65535) {
+		problems = append(problems, fmt.Errorf("smtp.port must be between 1 and 65535, got %d", c.SMTP.Port))
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		problems = append(problems, errors.New("webhooks.max_attempts must be positive"))
+	}
+	if c.Webhooks.DisableAfterFailures < 0 {
+		problems = append(problems, errors.New("webhooks.disable_after_failures must not be negative"))
+	}
+	if c.Limits.EventsPageSize <= 0 {
+		problems = append(problems, errors.New("limits.events_page_size must be positive"))
+	}
+	if c.Limits.MaxRequestBodyBytes <= 0 {
+		problems = append(problems, errors.New("limits.max_request_body_bytes must be positive"))
+	}
+	if c.Limits.IdempotencyKeyTTL <= 0 {
+		problems = append(problems, errors.New("limits.idempotency_key_ttl must be positive"))
+	}
+	if c.Limits.IdempotencyLease <= 0 {
+		problems = append(problems, errors.New("limits.idempotency_lease must be positive"))
+	}
+	if c.Limits.MaxClockDrift <= 0 {
+		problems = append(problems, errors.New("limits.max_clock_drift must be positive"))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration that is safe to print
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			redact(value)
+			continue
+		}
+
+		switch field.Tag.Get("secret") {
+		case "true":
+			if value.String() != "" {
+				value.SetString("REDACTED")
+			}
+		case "url":
+			value.SetString(redactDatabaseURL(value.String()))
+		}
+	}
+}
+
+// keywordPassword matches the password of a keyword/value connection string,
+// quoted or not
+var keywordPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDatabaseURL hides the password of a database URL, whether it is in the
+// user info or the query string, or of a keyword/value connection string such as
+// "host=db user=app password=secret". The host and database stay visible.
+func redactDatabaseURL(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil && strings.Contains(dsn, "://") {
+		return "REDACTED"
+	}
+	if err != nil || u.Scheme == "" {
+		return keywordPassword.ReplaceAllString(dsn, "${1}REDACTED")
+	}
+
+	changed := false
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+			changed = true
+		}
+	}
+	query := u.Query()
+	for key := range query {
+		if strings.EqualFold(key, "password") {
+			query[key] = []string{"REDACTED"}
+			u.RawQuery = query.Encode()
+			changed = true
+		}
+	}
+	if !changed {
+		return dsn
+	}
+	return u.String()
+}
+
+// String renders the redacted configuration as indented JSON
+func (c *Config) String() string {
+	var buf strings.Builder
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return fmt.Sprintf("<invalid config: %v>", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Duration is a time.Duration read from and written as strings such as "15s"
+type Duration time.Duration
+
+// Std returns the value as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
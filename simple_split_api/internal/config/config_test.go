@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactedDatabaseURL(t *testing.T) {
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{"postgres://app:secret@db:5432/simplesplit", "postgres://app:REDACTED@db:5432/simplesplit"},
+		{"postgres://db/simplesplit?password=secret&sslmode=disable", "postgres://db/simplesplit?password=REDACTED&sslmode=disable"},
+		{"postgres://app@db/simplesplit?PASSWORD=secret", "postgres://app@db/simplesplit?PASSWORD=REDACTED"},
+		{"host=db user=app password=secret dbname=simplesplit", "host=db user=app password=REDACTED dbname=simplesplit"},
+		{"host=db password = 'a secret' dbname=simplesplit", "host=db password = REDACTED dbname=simplesplit"},
+		{"host=db user=app dbname=simplesplit", "host=db user=app dbname=simplesplit"},
+		{"postgres://app@db/simplesplit?sslmode=disable", "postgres://app@db/simplesplit?sslmode=disable"},
+		{"sqlite:///var/lib/simplesplit.db", "sqlite:///var/lib/simplesplit.db"},
+		{MemoryDatabaseURL, MemoryDatabaseURL},
+	} {
+		cfg := Default()
+		cfg.Database.URL = test.url
+		if got := cfg.Redacted().Database.URL; got != test.want {
+			t.Errorf("Redacted(%q) = %q, want %q", test.url, got, test.want)
+		}
+		if cfg.Database.URL != test.url {
+			t.Errorf("Redacted changed the original URL to %q", cfg.Database.URL)
+		}
+	}
+}
+
+func TestRedactedSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.URL = "host=db password=db-secret"
+	cfg.SMTP.Password = "smtp-secret"
+	cfg.Firebase.ServiceAccount = `{"private_key": "key-secret"}`
+
+	rendered := cfg.String()
+	for _, secret := range []string{"db-secret", "smtp-secret", "key-secret"} {
+		if strings.Contains(rendered, secret) {
+			t.Errorf("rendered configuration contains %q:\n%s", secret, rendered)
+		}
+	}
+	if cfg.SMTP.Password != "smtp-secret" {
+		t.Error("Redacted changed the original configuration")
+	}
+}
@@ -2,55 +2,231 @@ package config
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 )
 
+// redactedValue replaces the values of sensitive fields in logs
+const redactedValue = "[REDACTED]"
+
+// limitedBuffer keeps the first limit bytes written to it and counts the rest
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	b.total += len(data)
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(data) > remaining {
+			b.buf.Write(data[:remaining])
+		} else {
+			b.buf.Write(data)
+		}
+	}
+	return len(data), nil
+}
+
+func (b *limitedBuffer) truncated() bool {
+	return b.total > b.buf.Len()
+}
+
+// teeReadCloser copies what the handler reads from the request body into a limitedBuffer,
+// so the body is captured without being read into memory up front
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
 type loggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
-	body       bytes.Buffer
+	body       *limitedBuffer
 }
 
-func LoggingMiddleware(next http.Handler) http.Handler {
+func (lrw *loggingResponseWriter) WriteHeader(code int) {
+	lrw.statusCode = code
+	lrw.ResponseWriter.WriteHeader(code)
+}
+
+func (lrw *loggingResponseWriter) Write(data []byte) (int, error) {
+	lrw.body.Write(data) // Capture the start of the response body
+	return lrw.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
+// RequestLogger writes one log record per request. Sensitive fields are redacted,
+// bodies are truncated and successful requests can be sampled.
+type RequestLogger struct {
+	bodyLimit         int
+	successSampleRate float64
+	redactFields      map[string]bool
+	redactPattern     *regexp.Regexp
+}
+
+// NewRequestLogger creates a request logger from the logging settings
+func NewRequestLogger(logConfig LogConfig) *RequestLogger {
+	l := &RequestLogger{
+		bodyLimit:         logConfig.BodyLimit,
+		successSampleRate: logConfig.SuccessSampleRate,
+		redactFields:      make(map[string]bool),
+	}
+
+	var names []string
+	for _, field := range logConfig.RedactFields {
+		l.redactFields[strings.ToLower(field)] = true
+		names = append(names, regexp.QuoteMeta(field))
+	}
+	if len(names) > 0 {
+		// Matches "field": up to the value, which redact skips
+		l.redactPattern = regexp.MustCompile(`(?i)"(?:` + strings.Join(names, "|") + `)"\s*:\s*`)
+	}
+
+	return l
+}
+
+// Middleware logs requests handled by next
+func (l *RequestLogger) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				slog.Error("Panic in LoggingMiddleware", "error", err, "path", r.URL.Path)
+				slog.ErrorContext(r.Context(), "Panic in LoggingMiddleware", "error", err, "path", r.URL.Path)
 				// Re-panic so outer middleware can handle it
 				panic(err)
 			}
 		}()
 
-		// Log request details
-		slog.Info(fmt.Sprintf("Request URL: %s, Method: %s", r.URL.String(), r.Method))
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			slog.Error("Failed to read request body: %v", "error", err)
-		} else {
-			slog.Debug(fmt.Sprintf("Request URL: %s, Method: %s, Body: %s", r.URL.String(), r.Method, string(body)))
+		start := time.Now()
+
+		requestBody := &limitedBuffer{limit: l.bodyLimit}
+		if r.Body != nil {
+			r.Body = teeReadCloser{Reader: io.TeeReader(r.Body, requestBody), Closer: r.Body}
 		}
-		r.Body = io.NopCloser(bytes.NewBuffer(body)) // Restore request body
 
-		// Wrap ResponseWriter
-		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK, body: &limitedBuffer{limit: l.bodyLimit}}
 
 		// Call the next handler
 		next.ServeHTTP(lrw, r)
 
-		// Log response details
-		slog.Info(fmt.Sprintf("Response Status: %d, Body: %s", lrw.statusCode, lrw.body.String()), "status", lrw.statusCode, "body", lrw.body.String())
+		level := slog.LevelInfo
+		switch {
+		case lrw.statusCode >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case lrw.statusCode >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case l.successSampleRate < 1 && rand.Float64() >= l.successSampleRate:
+			// Skip this successful request
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("query", l.redactQuery(r.URL.Query())),
+			slog.Int("status", lrw.statusCode),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.Int("response_bytes", lrw.body.total),
+		}
+		if r.Pattern != "" {
+			attrs = append(attrs, slog.String("route", r.Pattern))
+		}
+
+		// Bodies of failed requests help explain the failure, for successful ones they are only debug output
+		if level > slog.LevelInfo || slog.Default().Enabled(r.Context(), slog.LevelDebug) {
+			attrs = append(attrs,
+				slog.String("request_body", l.formatBody(requestBody)),
+				slog.String("response_body", l.formatBody(lrw.body)),
+			)
+		}
+
+		slog.LogAttrs(r.Context(), level, "HTTP request", attrs...)
 	})
+}
 
+// formatBody returns the captured body with sensitive fields redacted
+func (l *RequestLogger) formatBody(body *limitedBuffer) string {
+	text := body.buf.String()
+	if l.redactPattern != nil {
+		text = l.redact(text)
+	}
+	if body.truncated() {
+		text += "...(truncated)"
+	}
+	return text
 }
-func (lrw *loggingResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
-	lrw.ResponseWriter.WriteHeader(code)
+
+// redact replaces the values of sensitive fields in a JSON body, whole objects and
+// arrays included, also when the body was cut off by truncation
+func (l *RequestLogger) redact(text string) string {
+	var out strings.Builder
+	for {
+		loc := l.redactPattern.FindStringIndex(text)
+		if loc == nil {
+			out.WriteString(text)
+			return out.String()
+		}
+		out.WriteString(text[:loc[1]])
+		out.WriteString(`"` + redactedValue + `"`)
+		text = text[loc[1]:]
+		text = text[jsonValueLen(text):]
+	}
 }
 
-func (lrw *loggingResponseWriter) Write(data []byte) (int, error) {
-	lrw.body.Write(data) // Capture response body
-	return lrw.ResponseWriter.Write(data)
+// jsonValueLen returns the length of the JSON value text starts with, or of as
+// much of it as there is when text ends early
+func jsonValueLen(text string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case depth == 0 && (c == ',' || c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			return i
+		}
+	}
+	return len(text)
+}
+
+// redactQuery encodes the query parameters with sensitive values replaced
+func (l *RequestLogger) redactQuery(query url.Values) string {
+	for name, values := range query {
+		if l.redactFields[strings.ToLower(name)] {
+			for i := range values {
+				values[i] = redactedValue
+			}
+		}
+	}
+	return query.Encode()
 }
@@ -0,0 +1,42 @@
+package config
+
+import "testing"
+
+func TestRequestLoggerRedactsBodies(t *testing.T) {
+	l := NewRequestLogger(Default().Logging)
+
+	for _, test := range []struct {
+		body  string
+		limit int
+		want  string
+	}{
+		{
+			body: `{"event_id":"e1","payload":{"description":"Dinner","paid_by":[{"user_id":"u1","amount":10}]},"hlc":"1"}`,
+			want: `{"event_id":"e1","payload":"[REDACTED]","hlc":"1"}`,
+		},
+		{
+			body: `{"email": "ann@example.com", "name": "Ann"}`,
+			want: `{"email": "[REDACTED]", "name": "Ann"}`,
+		},
+		{
+			body: `[{"token":null},{"password":"a \"quoted\" secret"}]`,
+			want: `[{"token":"[REDACTED]"},{"password":"[REDACTED]"}]`,
+		},
+		{
+			// Cut off inside the payload
+			body:  `{"event_id":"e1","payload":{"description":"Dinner","total":10}}`,
+			limit: 40,
+			want:  `{"event_id":"e1","payload":"[REDACTED]"...(truncated)`,
+		},
+	} {
+		limit := test.limit
+		if limit == 0 {
+			limit = len(test.body)
+		}
+		body := &limitedBuffer{limit: limit}
+		body.Write([]byte(test.body))
+		if got := l.formatBody(body); got != test.want {
+			t.Errorf("formatBody(%s)\n got %s\nwant %s", test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// Error codes returned in the code member of problem responses. Clients switch on
+// these, so existing codes must never change meaning.
+const (
+	CodeInvalidBody             = "invalid_body"
+	CodeMissingParameter        = "missing_parameter"
+	CodeValidationFailed        = "validation_failed"
+	CodeNotFound                = "not_found"
+	CodeUserNotFound            = "user_not_found"
+	CodeGroupNotFound           = "group_not_found"
+	CodeEventNotFound           = "event_not_found"
+	CodeWebhookNotFound         = "webhook_not_found"
+	CodeWebhookDeliveryNotFound = "webhook_delivery_not_found"
+	CodeConflict                = "conflict"
+	CodeRequestInProgress       = "request_in_progress"
+	CodeIdempotencyKeyReused    = "idempotency_key_reused"
+	CodeIdempotencyStoreFailed  = "idempotency_store_failed"
+	CodePreconditionFailed      = "precondition_failed"
+	CodeInvalidCurrency         = "invalid_currency"
+	CodeForbidden               = "forbidden"
+	CodeMethodNotAllowed        = "method_not_allowed"
+	CodeRequestTooLarge         = "request_too_large"
+	CodeServiceUnavailable      = "service_unavailable"
+	CodeInternal                = "internal_error"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response body
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+	// Code identifies the error for clients
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// PreconditionProblem is the problem response for an event whose expected group
+// version or chain head is no longer current. It carries the current state, so
+// the client can merge its edit with the ones it missed.
+type PreconditionProblem struct {
+	Problem
+	// GroupVersion is the current version of the group
+	GroupVersion int64 `json:"group_version"`
+	// Head is the latest event of the linked event chain, when the event is linked
+	Head *domain.Event `json:"head,omitempty"`
+}
+
+// newProblem returns the problem details of a failed request
+func newProblem(r *http.Request, status int, code string, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: util.RequestID(r.Context()),
+	}
+}
+
+// writeProblem writes a problem details response
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	writeProblemBody(w, status, newProblem(r, status, code, detail))
+}
+
+// writeProblemBody writes a problem details response with a body extending Problem
+func writeProblemBody(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a problem details response for an error returned by a
+// repository or service. notFoundCode is used when the error is repository.ErrNotFound,
+// in which case the error message, naming what was not found, is the detail.
+func writeError(w http.ResponseWriter, r *http.Request, err error, notFoundCode string, detail string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, notFoundCode, err.Error())
+	case errors.Is(err, repository.ErrConflict):
+		writeProblem(w, r, http.StatusConflict, CodeConflict, detail)
+	case errors.Is(err, repository.ErrValidation):
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, detail)
+	case errors.As(err, &maxBytesErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body too large")
+	default:
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, detail)
+	}
+}
+
+// lookupError is a failed lookup inside a unit of work, carrying the arguments of
+// the writeError call that reports it once the transaction has ended
+type lookupError struct {
+	err          error
+	notFoundCode string
+	detail       string
+}
+
+func (e *lookupError) Error() string {
+	return e.err.Error()
+}
+
+func (e *lookupError) Unwrap() error {
+	return e.err
+}
+
+// preconditionError is an event refused because the group or the linked event
+// chain changed since the client last saw it
+type preconditionError struct {
+	detail string
+	group  *domain.Group
+	head   *domain.Event
+}
+
+func (e *preconditionError) Error() string {
+	return e.detail
+}
+
+// currencyError is an event refused because its currency is not an ISO 4217 code
+// or does not fit the currencies of the group
+type currencyError struct {
+	err    error
+	detail string
+}
+
+func (e *currencyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *currencyError) Unwrap() error {
+	return e.err
+}
+
+// writeTxError writes the problem response for an error returned by a unit of
+// work, using the code and detail of a lookupError when it is one, the current
+// state for a preconditionError and the detail of a currencyError
+func writeTxError(w http.ResponseWriter, r *http.Request, err error, notFoundCode string, detail string) {
+	var preconditionErr *preconditionError
+	if errors.As(err, &preconditionErr) {
+		problem := PreconditionProblem{
+			Problem:      newProblem(r, http.StatusConflict, CodePreconditionFailed, preconditionErr.detail),
+			GroupVersion: preconditionErr.group.Version,
+			Head:         preconditionErr.head,
+		}
+		writeProblemBody(w, http.StatusConflict, problem)
+		return
+	}
+
+	var currencyErr *currencyError
+	if errors.As(err, &currencyErr) {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidCurrency, currencyErr.detail)
+		return
+	}
+
+	var lookupErr *lookupError
+	if errors.As(err, &lookupErr) {
+		notFoundCode, detail = lookupErr.notFoundCode, lookupErr.detail
+	}
+	writeError(w, r, err, notFoundCode, detail)
+}
+
+// writeBodyError writes the problem response for a request body that could not be decoded
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body too large")
+		return
+	}
+	writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+}
@@ -3,121 +3,155 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/metrics"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
 	"github.com/RealZimboGuy/budgetApp/internal/repository"
 	"github.com/RealZimboGuy/budgetApp/internal/services"
 	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
 )
 
 // EventController handles HTTP requests related to events
 type EventController struct {
-	EventRepo       *repository.EventRepository
-	UserRepo        *repository.UserRepository
-	GroupRepo       *repository.GroupRepository
+	// Tx runs the checks and insert of a new event as one unit of work
+	Tx              repository.Transactor
+	EventRepo       repository.EventStore
+	UserRepo        repository.UserStore
+	GroupRepo       repository.GroupStore
 	FirebaseService *services.FirebaseService
+	WebhookService  *services.WebhookService
+	EmailService    *services.EmailService
+	// PageSize is the maximum number of events returned by GetEventsByGroup
+	PageSize int
+	// Clock stamps events sent without an HLC and merges the HLCs clients send
+	Clock *hlc.Clock
+	// Background runs the notifications and webhook deliveries triggered by new events
+	Background *util.Background
 }
 
 // NewEventController creates a new event controller
 func NewEventController(
-	eventRepo *repository.EventRepository,
-	userRepo *repository.UserRepository,
-	groupRepo *repository.GroupRepository,
+	tx repository.Transactor,
+	eventRepo repository.EventStore,
+	userRepo repository.UserStore,
+	groupRepo repository.GroupStore,
 	firebaseService *services.FirebaseService,
+	webhookService *services.WebhookService,
+	emailService *services.EmailService,
+	pageSize int,
+	clock *hlc.Clock,
+	background *util.Background,
 ) *EventController {
 	return &EventController{
+		Tx:              tx,
 		EventRepo:       eventRepo,
 		UserRepo:        userRepo,
 		GroupRepo:       groupRepo,
 		FirebaseService: firebaseService,
+		WebhookService:  webhookService,
+		EmailService:    emailService,
+		PageSize:        pageSize,
+		Clock:           clock,
+		Background:      background,
 	}
 }
 
 // CreateEvent handles event creation requests
 func (c *EventController) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
-	var reqBody struct {
-		EventID       string          `json:"event_id"`
-		LinkedEventID string          `json:"linked_event_id"`
-		GroupID       string          `json:"group_id"`
-		UserID        string          `json:"user_id"`
-		EventType     string          `json:"event_type"`
-		Payload       json.RawMessage `json:"payload"`
-	}
+	var reqBody createEventRequest
 
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
 	// Validate request
 	if reqBody.EventID == "" {
-		http.Error(w, "Event ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Event ID is required")
+		return
+	}
+
+	// v2 routes name the group in the path
+	groupID, ok := bodyParam(r, "group_id", reqBody.GroupID)
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Group ID in the body does not match the path")
 		return
 	}
+	reqBody.GroupID = groupID
 
 	if reqBody.GroupID == "" {
-		http.Error(w, "Group ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
 		return
 	}
 	if reqBody.UserID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
 		return
 	}
 	if reqBody.EventType == "" {
-		http.Error(w, "Event type is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Event type is required")
 		return
 	}
 	if len(reqBody.Payload) == 0 {
-		http.Error(w, "Payload is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Payload is required")
 		return
 	}
-
-	// Check if user exists
-	_, err = c.UserRepo.GetByID(r.Context(), reqBody.UserID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("User not found:%s", reqBody.UserID), http.StatusBadRequest)
+	if isExpenseChange(util.EventType(reqBody.EventType)) && reqBody.LinkedEventID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Linked event ID of the expense is required")
 		return
 	}
-
-	// Check if group exists
-	_, err = c.GroupRepo.GetByID(r.Context(), reqBody.GroupID)
-	if err != nil {
-		http.Error(w, "Group not found", http.StatusBadRequest)
+	if util.EventType(reqBody.EventType) == util.ExpenseUpdated {
+		var update events.ExpenseUpdated
+		if err := json.Unmarshal(reqBody.Payload, &update); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Invalid EXPENSE_UPDATED payload: "+err.Error())
+			return
+		}
+	}
+	if reqBody.ExpectedHeadID != "" && reqBody.LinkedEventID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Expected head ID requires a linked event ID")
 		return
 	}
 
-	//if the event exists then return it as is already
-	existing, err := c.EventRepo.GetByID(r.Context(), reqBody.EventID)
-	if existing != nil {
-		slog.InfoContext(r.Context(), "Event already exists", "event", existing)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(existing)
+	// The event keeps the time the client recorded it, which orders it among the
+	// events other clients recorded while it was offline
+	if reqBody.HLC.IsZero() {
+		reqBody.HLC = c.Clock.Now()
+	} else if _, err := c.Clock.Update(reqBody.HLC); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Invalid HLC: "+err.Error())
 		return
 	}
 
-	// Create event
-	event := domain.NewEvent(
-		reqBody.EventID,
-		reqBody.LinkedEventID,
-		reqBody.GroupID,
-		reqBody.UserID,
-		util.EventType(reqBody.EventType),
-		reqBody.Payload,
-	)
-
-	err = c.EventRepo.Create(r.Context(), event)
+	// The checks and the insert run in one transaction, so the user and group are
+	// still there when the event is written
+	var event *domain.Event
+	var created bool
+	err = c.Tx.InTx(r.Context(), func(ctx context.Context) error {
+		event, created, err = c.createEvent(ctx, reqBody)
+		return err
+	})
 	if err != nil {
 		slog.ErrorContext(r.Context(), "Failed to create event", "error", err)
-		http.Error(w, "Failed to create event", http.StatusInternalServerError)
+		writeTxError(w, r, err, CodeEventNotFound, "Failed to create event")
+		return
+	}
+
+	// A repeated event ID is answered with the stored event
+	if !created {
+		slog.InfoContext(r.Context(), "Event already exists", "event", event)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(event)
 		return
 	}
+	metrics.EventsIngested.WithLabelValues(string(event.EventType)).Inc()
 
 	slog.InfoContext(r.Context(), "Created event", "event", event)
 
@@ -126,38 +160,288 @@ func (c *EventController) CreateEvent(w http.ResponseWriter, r *http.Request) {
 		// Parse the expense payload
 		var expense map[string]interface{}
 		if err := json.Unmarshal(event.Payload, &expense); err == nil {
-			// Process in a goroutine to not block the response
-			go func() {
-				err := c.FirebaseService.ProcessExpenseCreatedEvent(context.Background(), event, expense)
+			// Process in the background to not block the response
+			c.Background.Go(func(ctx context.Context) {
+				err := c.FirebaseService.ProcessExpenseCreatedEvent(ctx, event, expense)
 				if err != nil {
-					log.Printf("Failed to process ExpenseCreated notification: %v", err)
+					slog.ErrorContext(ctx, "Failed to process ExpenseCreated notification", "error", err)
 				}
-			}()
+			})
 		}
 	} else {
 		slog.InfoContext(r.Context(), "Not processing ExpenseCreated event as FirebaseService is nil")
 	}
 
+	// Email the users involved in a new expense
+	if c.EmailService != nil && event.EventType == util.ExpenseCreated {
+		c.Background.Go(func(ctx context.Context) {
+			err := c.EmailService.ProcessExpenseCreatedEvent(ctx, event)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to process ExpenseCreated email", "error", err)
+			}
+		})
+	}
+
+	// Deliver the event to the group's webhooks
+	if c.WebhookService != nil {
+		c.Background.Go(func(ctx context.Context) {
+			c.WebhookService.DispatchEvent(ctx, event)
+		})
+	}
+
 	// Return created event
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(event)
 }
 
+// createEvent records the requested event unless one with its ID exists, in which
+// case the stored event is returned with created false. An event whose expected
+// group version or chain head is no longer current is refused with a
+// preconditionError. It runs as a unit of work.
+func (c *EventController) createEvent(ctx context.Context, req createEventRequest) (*domain.Event, bool, error) {
+	// Check if user exists
+	if _, err := c.UserRepo.GetByID(ctx, req.UserID); err != nil {
+		return nil, false, &lookupError{err, CodeUserNotFound, "Failed to get user"}
+	}
+
+	// Check if group exists, holding it so its version can't change until the event is written
+	group, err := c.GroupRepo.GetForUpdate(ctx, req.GroupID)
+	if err != nil {
+		return nil, false, &lookupError{err, CodeGroupNotFound, "Group not found"}
+	}
+
+	//if the event exists then return it as is already, before checking the
+	// preconditions that accepting it made stale
+	existing, err := c.EventRepo.GetByID(ctx, req.EventID)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, false, err
+	}
+
+	// A stale client is shown the current state before being told its edit is invalid
+	if err := c.checkPreconditions(ctx, req, group); err != nil {
+		return nil, false, err
+	}
+	if isExpenseChange(util.EventType(req.EventType)) {
+		if err := c.checkLinkedExpense(ctx, req); err != nil {
+			return nil, false, err
+		}
+	}
+	if hasCurrency(util.EventType(req.EventType)) {
+		if err := c.checkCurrency(ctx, req); err != nil {
+			return nil, false, err
+		}
+	}
+
+	event := domain.NewEvent(
+		req.EventID,
+		req.LinkedEventID,
+		req.GroupID,
+		req.UserID,
+		util.EventType(req.EventType),
+		req.Payload,
+	)
+	event.HLC = req.HLC
+
+	err = c.EventRepo.Create(ctx, event)
+	if errors.Is(err, repository.ErrConflict) {
+		// A concurrent request wrote the event after the lookup above
+		existing, err := c.EventRepo.GetByID(ctx, req.EventID)
+		return existing, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if _, err := c.GroupRepo.IncrementVersion(ctx, req.GroupID); err != nil {
+		return nil, false, err
+	}
+
+	return event, true, nil
+}
+
+// isExpenseChange reports whether events of type eventType change the expense
+// they link to
+func isExpenseChange(eventType util.EventType) bool {
+	return eventType == util.ExpenseUpdated || eventType == util.ExpenseDeleted || eventType == util.ExpenseRestored
+}
+
+// checkLinkedExpense checks that the expense an EXPENSE_UPDATED, EXPENSE_DELETED
+// or EXPENSE_RESTORED event links to was created in the same group, and that the
+// event applies to it: only active expenses are updated or deleted and only deleted
+// ones restored. A deletion or restore must also order after the previous one by
+// HLC, or it would have no effect on replay.
+func (c *EventController) checkLinkedExpense(ctx context.Context, req createEventRequest) error {
+	expense, err := c.EventRepo.GetByID(ctx, req.LinkedEventID)
+	if err != nil {
+		return &lookupError{err, CodeEventNotFound, "Failed to get the linked expense"}
+	}
+
+	invalid := func(detail string) error {
+		err := fmt.Errorf("%w: %s event linked to expense %s: %s", repository.ErrValidation, req.EventType, req.LinkedEventID, detail)
+		return &lookupError{err, CodeEventNotFound, detail}
+	}
+
+	switch {
+	case expense.EventType != util.ExpenseCreated:
+		return invalid("Linked event is not an EXPENSE_CREATED event")
+	case !strings.EqualFold(expense.GroupID, req.GroupID):
+		return invalid("Linked expense is in another group")
+	}
+
+	chain, err := c.EventRepo.GetChain(ctx, expense.GroupID, expense.EventID)
+	if err != nil {
+		return &lookupError{err, CodeEventNotFound, "Failed to get the linked expense"}
+	}
+
+	deleted, last := services.DeletionState(chain)
+	switch util.EventType(req.EventType) {
+	case util.ExpenseUpdated:
+		if deleted {
+			return invalid("Linked expense has been deleted")
+		}
+	case util.ExpenseDeleted:
+		if deleted {
+			return invalid("Linked expense is already deleted")
+		}
+	case util.ExpenseRestored:
+		if !deleted {
+			return invalid("Linked expense is not deleted")
+		}
+	}
+	if last != nil && util.EventType(req.EventType) != util.ExpenseUpdated && !last.HLC.Before(req.HLC) {
+		return invalid("Linked expense was deleted or restored after this event was recorded")
+	}
+	return nil
+}
+
+// hasCurrency reports whether events of eventType must be in, or name, a currency
+// of the group: expenses, including the one an EXPENSE_RESTORED event brings back,
+// and the group currency events
+func hasCurrency(eventType util.EventType) bool {
+	switch eventType {
+	case util.ExpenseCreated, util.ExpenseUpdated, util.ExpenseRestored:
+		return true
+	}
+	return eventType.IsCurrencyChange()
+}
+
+// checkCurrency checks the currency of an expense or a group currency event is an
+// ISO 4217 code and fits the currencies of the group: expenses must be in an
+// enabled currency, and a currency can only be removed when it is enabled, is not
+// the default and its balances are settled. A restored expense is checked in its
+// currency as last edited, which must still be enabled.
+func (c *EventController) checkCurrency(ctx context.Context, req createEventRequest) error {
+	invalid := func(detail string) error {
+		err := fmt.Errorf("%w: %s event in group %s: %s", repository.ErrValidation, req.EventType, req.GroupID, detail)
+		return &currencyError{err, detail}
+	}
+	eventType := util.EventType(req.EventType)
+
+	payload := req.Payload
+	if eventType == util.ExpenseRestored {
+		chain, err := c.EventRepo.GetChain(ctx, req.GroupID, req.LinkedEventID)
+		if err != nil {
+			return &lookupError{err, CodeEventNotFound, "Failed to get the linked expense"}
+		}
+		if expenses := services.FoldExpenses(chain); len(expenses) > 0 {
+			payload = expenses[0].Payload
+		}
+	}
+	var fields struct {
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return invalid("Payload must be a JSON object with a currency")
+	}
+	currency := fields.Currency
+	if !util.IsCurrency(currency) {
+		return invalid(fmt.Sprintf("Currency %q is not an ISO 4217 code", currency))
+	}
+
+	currencyEvents, err := c.EventRepo.GetCurrencyEvents(ctx, req.GroupID)
+	if err != nil {
+		return err
+	}
+	currencies := services.FoldCurrencies(currencyEvents)
+
+	// Removing a currency needs the balances, and the first currency event of a group
+	// the expenses recorded before it, both of which take the whole log
+	var groupEvents []*domain.Event
+	if eventType == util.GroupRemoveCurrency || (!currencies.Configured && eventType.IsCurrencyChange()) {
+		if groupEvents, err = c.EventRepo.GetByGroupID(ctx, req.GroupID); err != nil {
+			return err
+		}
+	}
+	if !currencies.Configured && eventType.IsCurrencyChange() {
+		currencies = services.ConfigureCurrencies(groupEvents, req.HLC)
+	}
+
+	switch eventType {
+	case util.ExpenseCreated, util.ExpenseUpdated, util.ExpenseRestored:
+		if !currencies.Accepts(currency) {
+			return invalid(fmt.Sprintf("Currency %s is not enabled for the group", currency))
+		}
+	case util.GroupRemoveCurrency:
+		switch {
+		case !currencies.Enabled[currency]:
+			return invalid(fmt.Sprintf("Currency %s is not enabled for the group", currency))
+		case currency == currencies.Default:
+			return invalid(fmt.Sprintf("Currency %s is the default currency of the group", currency))
+		case !services.ComputeBalances(groupEvents).Settled(currency):
+			return invalid(fmt.Sprintf("Currency %s has outstanding balances", currency))
+		}
+	}
+	return nil
+}
+
+// checkPreconditions returns a preconditionError with the current state when the
+// group version or chain head the request expects is no longer current
+func (c *EventController) checkPreconditions(ctx context.Context, req createEventRequest, group *domain.Group) error {
+	var head *domain.Event
+	if req.LinkedEventID != "" && req.ExpectedHeadID != "" {
+		var err error
+		head, err = c.EventRepo.GetChainHead(ctx, group.GroupID, req.LinkedEventID)
+		if err != nil {
+			return &lookupError{err, CodeEventNotFound, "Linked event not found"}
+		}
+	}
+
+	if req.ExpectedGroupVersion != nil && *req.ExpectedGroupVersion != group.Version {
+		return &preconditionError{
+			detail: fmt.Sprintf("Group is at version %d, not the expected %d", group.Version, *req.ExpectedGroupVersion),
+			group:  group,
+			head:   head,
+		}
+	}
+	// UUIDs may be sent in upper case, the stored IDs are those first sent
+	if head != nil && !strings.EqualFold(head.EventID, req.ExpectedHeadID) {
+		return &preconditionError{
+			detail: fmt.Sprintf("Latest event linked to %s is %s, not the expected %s", req.LinkedEventID, head.EventID, req.ExpectedHeadID),
+			group:  group,
+			head:   head,
+		}
+	}
+	return nil
+}
+
 // GetEvent handles event retrieval requests
 func (c *EventController) GetEvent(w http.ResponseWriter, r *http.Request) {
 	// Get event ID from URL
-	eventID := r.URL.Query().Get("id")
+	eventID := param(r, "id")
 	if eventID == "" {
-		http.Error(w, "Event ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Event ID is required")
 		return
 	}
 
 	// Get event
 	event, err := c.EventRepo.GetByID(r.Context(), eventID)
 	if err != nil {
-		log.Printf("Failed to get event: %v", err)
-		http.Error(w, "Failed to get event", http.StatusNotFound)
+		slog.ErrorContext(r.Context(), "Failed to get event", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get event")
 		return
 	}
 
@@ -169,23 +453,23 @@ func (c *EventController) GetEvent(w http.ResponseWriter, r *http.Request) {
 // GetEventsByGroup handles requests to get events for a group
 func (c *EventController) GetEventsByGroup(w http.ResponseWriter, r *http.Request) {
 	// Get group ID from URL
-	groupID := r.URL.Query().Get("group_id")
+	groupID := param(r, "group_id")
 	if groupID == "" {
-		http.Error(w, "Group ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
 		return
 	}
 
 	// Get event ID to start from (or "0" for the beginning)
-	afterEventID := r.URL.Query().Get("after_id")
+	afterEventID := param(r, "after_id")
 	if afterEventID == "" {
 		afterEventID = "0" // Default to start from the beginning
 	}
 
-	// Get events (limit to 1000)
-	events, err := c.EventRepo.GetEventsByGroupAfterID(r.Context(), groupID, afterEventID, 1000)
+	// Get events (limited to the configured page size)
+	events, err := c.EventRepo.GetEventsByGroupAfterID(r.Context(), groupID, afterEventID, c.PageSize)
 	if err != nil {
-		log.Printf("Failed to get events: %v", err)
-		http.Error(w, "Failed to get events", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get events", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get events")
 		return
 	}
 
@@ -199,8 +483,8 @@ func (c *EventController) GetAllEvents(w http.ResponseWriter, r *http.Request) {
 	// Get all events
 	events, err := c.EventRepo.GetAll(r.Context())
 	if err != nil {
-		log.Printf("Failed to get events: %v", err)
-		http.Error(w, "Failed to get events", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get events", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get events")
 		return
 	}
 
@@ -212,17 +496,49 @@ func (c *EventController) GetAllEvents(w http.ResponseWriter, r *http.Request) {
 // DeleteEvent handles event deletion requests
 func (c *EventController) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	// Get event ID from URL
-	eventID := r.URL.Query().Get("id")
+	eventID := param(r, "id")
 	if eventID == "" {
-		http.Error(w, "Event ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Event ID is required")
 		return
 	}
 
-	// Delete event
-	err := c.EventRepo.Delete(r.Context(), eventID)
+	// Delete event, bumping the version of its group so clients expecting the
+	// previous version see the log changed. Expenses are undone with EXPENSE_DELETED
+	// events instead, removing one of their events or an event others link to
+	// would leave the chain it belongs to inconsistent.
+	err := c.Tx.InTx(r.Context(), func(ctx context.Context) error {
+		event, err := c.EventRepo.GetByID(ctx, eventID)
+		if err != nil {
+			return err
+		}
+		if _, err := c.GroupRepo.GetForUpdate(ctx, event.GroupID); err != nil {
+			return err
+		}
+		refuse := func(detail string) error {
+			err := fmt.Errorf("%w: event %s: %s", repository.ErrConflict, event.EventID, detail)
+			return &lookupError{err, CodeEventNotFound, detail}
+		}
+		if event.EventType.IsExpense() {
+			return refuse("Expense events can't be deleted, record an EXPENSE_DELETED event instead")
+		}
+		groupEvents, err := c.EventRepo.GetByGroupID(ctx, event.GroupID)
+		if err != nil {
+			return err
+		}
+		for _, other := range groupEvents {
+			if strings.EqualFold(other.LinkedEventID, event.EventID) {
+				return refuse("Other events link to this event, record an EXPENSE_DELETED event instead")
+			}
+		}
+		if err := c.EventRepo.Delete(ctx, event.EventID); err != nil {
+			return err
+		}
+		_, err = c.GroupRepo.IncrementVersion(ctx, event.GroupID)
+		return err
+	})
 	if err != nil {
-		log.Printf("Failed to delete event: %v", err)
-		http.Error(w, "Failed to delete event", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to delete event", "error", err)
+		writeTxError(w, r, err, CodeEventNotFound, "Failed to delete event")
 		return
 	}
 
@@ -0,0 +1,456 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/client"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+	"github.com/google/uuid"
+)
+
+// forEachBackend runs test once on every backend the harness supports
+func forEachBackend(t *testing.T, test func(t *testing.T, backend string)) {
+	for _, backend := range apitest.Backends {
+		t.Run(backend, func(t *testing.T) {
+			test(t, backend)
+		})
+	}
+}
+
+func TestCreateEventDuplicate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ann := h.CreateUserWithToken("Ann", "token-ann")
+		bob := h.CreateUserWithToken("Bob", "token-bob")
+		group := h.CreateGroup("Trip")
+
+		event, err := client.NewEvent(group.GroupID, ann.UserID, client.ExpenseCreated, apitest.Expense(ann, bob))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := map[string]any{
+			"event_id":   event.EventID,
+			"user_id":    event.UserID,
+			"event_type": event.EventType,
+			"payload":    event.Payload,
+		}
+		path := "/api/v2/groups/" + group.GroupID + "/events"
+
+		status, first := h.Do(http.MethodPost, path, body)
+		if status != http.StatusOK {
+			t.Fatalf("first create: status %d: %s", status, first)
+		}
+
+		// A repeat with a different payload is answered with the stored event
+		body["payload"] = json.RawMessage(`{"description":"changed"}`)
+		status, second := h.Do(http.MethodPost, path, body)
+		if status != http.StatusOK {
+			t.Fatalf("repeated create: status %d: %s", status, second)
+		}
+
+		var created, repeated client.Event
+		json.Unmarshal(first, &created)
+		json.Unmarshal(second, &repeated)
+		if repeated.EventID != created.EventID || !repeated.CreatedAt.Equal(created.CreatedAt) || string(repeated.Payload) != string(created.Payload) {
+			t.Errorf("repeated create returned %s, want the stored event %s", second, first)
+		}
+
+		h.Wait()
+		stored, err := h.Client.ListEvents(context.Background(), group.GroupID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) != 1 {
+			t.Errorf("got %d events after a duplicate, want 1", len(stored))
+		}
+		if got := len(h.FCM.Messages()); got != 2 {
+			t.Errorf("got %d notifications, want 2 for the first create only", got)
+		}
+	})
+}
+
+func TestCreateEventConcurrentDuplicates(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ann := h.CreateUserWithToken("Ann", "token-ann")
+		group := h.CreateGroup("Trip")
+
+		event, err := client.NewEvent(group.GroupID, ann.UserID, client.ExpenseCreated, apitest.Expense(ann, ann))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every attempt resolves to the same event, whether it wrote it, found it
+		// before writing or lost the race to write it
+		const attempts = 8
+		var wg sync.WaitGroup
+		results := make([]*client.Event, attempts)
+		for i := range attempts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				attempt := *event
+				created, err := h.Client.CreateEvent(context.Background(), &attempt)
+				if err != nil {
+					t.Errorf("attempt %d: %v", i, err)
+					return
+				}
+				results[i] = created
+			}()
+		}
+		wg.Wait()
+
+		h.Wait()
+		stored, err := h.Client.ListEvents(context.Background(), group.GroupID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) != 1 {
+			t.Fatalf("got %d events, want 1", len(stored))
+		}
+
+		for i, result := range results {
+			if result != nil && (result.EventID != stored[0].EventID || !result.CreatedAt.Equal(stored[0].CreatedAt)) {
+				t.Errorf("attempt %d returned event %s created at %v, want %s created at %v",
+					i, result.EventID, result.CreatedAt, stored[0].EventID, stored[0].CreatedAt)
+			}
+		}
+		if got := len(h.FCM.Messages()); got != 1 {
+			t.Errorf("got %d notifications, want 1", got)
+		}
+	})
+}
+
+func TestGetEventsByGroupPaging(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend, apitest.WithPageSize(3))
+		ann := h.CreateUser("Ann")
+		group := h.CreateGroup("Trip")
+		other := h.CreateGroup("Other trip")
+
+		var want []string
+		for range 7 {
+			want = append(want, h.CreateExpense(group, ann, ann).EventID)
+			h.CreateExpense(other, ann, ann)
+		}
+
+		pager := h.Client.Events(group.GroupID, "")
+		var got []string
+		var pageSizes []int
+		for {
+			page, err := pager.Next(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageSizes = append(pageSizes, len(page))
+			if len(page) == 0 {
+				break
+			}
+			for _, event := range page {
+				got = append(got, event.EventID)
+			}
+		}
+
+		if !slices.Equal(pageSizes, []int{3, 3, 1, 0}) {
+			t.Errorf("got pages of %v events, want [3 3 1 0]", pageSizes)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("paged through %v, want the group's events in creation order %v", got, want)
+		}
+
+		// Events recorded after the last page are returned by the next request
+		later := h.CreateExpense(group, ann, ann)
+		page, err := pager.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 1 || page[0].EventID != later.EventID {
+			t.Errorf("got %d events after the last page, want the new event %s", len(page), later.EventID)
+		}
+
+		status, body := h.Do(http.MethodGet, "/api/v2/groups/"+group.GroupID+"/events?after_id=not-a-uuid", nil)
+		if status != http.StatusBadRequest {
+			t.Errorf("malformed after_id: status %d: %s", status, body)
+		}
+	})
+}
+
+func TestExpenseNotificationFanOut(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ann := h.CreateUserWithToken("Ann", "token-ann")
+		bob := h.CreateUserWithToken("Bob", "token-bob")
+		cat := h.CreateUserWithToken("Cat", "token-cat")
+		dan := h.CreateUser("Dan")
+		eve := h.CreateUserWithToken("Eve", "token-eve")
+		group := h.CreateGroup("Trip")
+
+		// Ann pays for herself and others, Eve is not part of the expense and Dan has no token
+		expense := h.CreateExpense(group, ann, ann, bob, cat, dan)
+		h.CreateEvent(group, eve, util.GroupUserJoined, map[string]string{"user_id": eve.UserID, "name": "Eve"})
+		h.Wait()
+
+		tokens := h.FCM.Tokens()
+		slices.Sort(tokens)
+		if want := []string{"token-ann", "token-bob", "token-cat"}; !slices.Equal(tokens, want) {
+			t.Fatalf("notified %v, want %v", tokens, want)
+		}
+
+		for _, message := range h.FCM.Messages() {
+			if message.Notification.Title != "New Expense Added" || message.Notification.Body != "Dinner - EUR - 10.00" {
+				t.Errorf("notification to %s is %+v", message.Token, message.Notification)
+			}
+			wantData := map[string]string{"event_id": expense.EventID, "group_id": group.GroupID, "type": "expense_created"}
+			if !maps.Equal(message.Data, wantData) {
+				t.Errorf("notification data to %s is %v, want %v", message.Token, message.Data, wantData)
+			}
+		}
+	})
+}
+
+func TestCreateEventHLC(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		bob := h.CreateUser("Bob")
+		group := h.CreateGroup("Trip")
+
+		// Bob records an expense offline, Ann records one online afterwards and Bob
+		// uploads his once he is back online
+		offline, err := client.NewEvent(group.GroupID, bob.UserID, client.ExpenseCreated, apitest.Expense(bob, ann))
+		if err != nil {
+			t.Fatal(err)
+		}
+		offline.HLC = hlc.HLC{Wall: time.Now().Add(-time.Hour).UnixMilli()}
+		online := h.CreateExpense(group, ann, ann, bob)
+		if _, err := h.Client.CreateEvent(ctx, offline); err != nil {
+			t.Fatal(err)
+		}
+
+		// A client without a clock gets a server timestamp after every event seen so far
+		path := "/api/v2/groups/" + group.GroupID + "/events"
+		status, body := h.Do(http.MethodPost, path, map[string]any{
+			"event_id":   uuid.NewString(),
+			"user_id":    ann.UserID,
+			"event_type": util.GroupUserJoined,
+			"payload":    map[string]string{"user_id": ann.UserID, "name": "Ann"},
+		})
+		if status != http.StatusOK {
+			t.Fatalf("create without an HLC: status %d: %s", status, body)
+		}
+		var stamped client.Event
+		json.Unmarshal(body, &stamped)
+		if !online.HLC.Before(stamped.HLC) {
+			t.Errorf("server stamped %s, want after the online event's %s", stamped.HLC, online.HLC)
+		}
+
+		events, err := h.Client.ListEvents(ctx, group.GroupID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var arrival []string
+		for _, event := range events {
+			arrival = append(arrival, event.EventID)
+		}
+		if want := []string{online.EventID, offline.EventID, stamped.EventID}; !slices.Equal(arrival, want) {
+			t.Errorf("listed %v, want arrival order %v", arrival, want)
+		}
+
+		client.SortByHLC(events)
+		var replay []string
+		for _, event := range events {
+			replay = append(replay, event.EventID)
+		}
+		if want := []string{offline.EventID, online.EventID, stamped.EventID}; !slices.Equal(replay, want) {
+			t.Errorf("replay order is %v, want %v", replay, want)
+		}
+
+		future := map[string]any{
+			"event_id":   uuid.NewString(),
+			"user_id":    ann.UserID,
+			"event_type": util.ExpenseCreated,
+			"payload":    apitest.Expense(ann, ann),
+			"hlc":        hlc.HLC{Wall: time.Now().Add(time.Hour).UnixMilli()},
+		}
+		if status, body := h.Do(http.MethodPost, path, future); status != http.StatusBadRequest {
+			t.Errorf("HLC an hour ahead: status %d: %s", status, body)
+		}
+		future["hlc"] = "yesterday"
+		if status, body := h.Do(http.MethodPost, path, future); status != http.StatusBadRequest {
+			t.Errorf("malformed HLC: status %d: %s", status, body)
+		}
+	})
+}
+
+func TestCreateEventPreconditions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		bob := h.CreateUser("Bob")
+		group := h.CreateGroup("Trip")
+		expense := h.CreateExpense(group, ann, ann, bob)
+
+		seen, err := h.Client.GetGroup(ctx, group.GroupID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen.Version != 1 {
+			t.Errorf("group version after one event is %d, want 1", seen.Version)
+		}
+
+		// Ann and Bob both act on the expense as they last saw it, Bob is first
+		deletion := &client.Event{
+			EventID:       uuid.NewString(),
+			LinkedEventID: expense.EventID,
+			GroupID:       group.GroupID,
+			UserID:        bob.UserID,
+			EventType:     client.ExpenseDeleted,
+			Payload:       json.RawMessage(`{}`),
+		}
+		// IDs are compared regardless of case
+		if _, err := h.Client.CreateEvent(ctx, deletion, client.ExpectHead(strings.ToUpper(expense.EventID))); err != nil {
+			t.Fatal(err)
+		}
+		edit := &client.Event{
+			EventID:       uuid.NewString(),
+			LinkedEventID: expense.EventID,
+			GroupID:       group.GroupID,
+			UserID:        ann.UserID,
+			EventType:     client.ExpenseUpdated,
+			Payload:       expense.Payload,
+		}
+		_, err = h.Client.CreateEvent(ctx, edit, client.ExpectHead(expense.EventID))
+		var apiErr *client.Error
+		if !client.IsPreconditionFailed(err) || !errors.As(err, &apiErr) {
+			t.Fatalf("edit of a deleted expense: got %v, want precondition_failed", err)
+		}
+		if apiErr.Head == nil || apiErr.Head.EventID != deletion.EventID || apiErr.GroupVersion != 2 {
+			t.Errorf("precondition failure carries head %+v at version %d, want the deletion at version 2", apiErr.Head, apiErr.GroupVersion)
+		}
+
+		// Resending an accepted event returns it even though its precondition is now stale
+		if again, err := h.Client.CreateEvent(ctx, deletion, client.ExpectHead(expense.EventID)); err != nil || again.EventID != deletion.EventID {
+			t.Errorf("resent deletion: got %v, %v", again, err)
+		}
+
+		stale := &client.Event{
+			EventID:   uuid.NewString(),
+			GroupID:   group.GroupID,
+			UserID:    ann.UserID,
+			EventType: client.ExpenseCreated,
+			Payload:   expense.Payload,
+		}
+		if _, err := h.Client.CreateEvent(ctx, stale, client.ExpectGroupVersion(seen.Version)); !client.IsPreconditionFailed(err) {
+			t.Errorf("event at a stale group version: got %v, want precondition_failed", err)
+		}
+		if _, err := h.Client.CreateEvent(ctx, stale, client.ExpectGroupVersion(2)); err != nil {
+			t.Errorf("event at the current group version: %v", err)
+		}
+
+		status, body := h.Do(http.MethodPost, "/api/v2/groups/"+group.GroupID+"/events", map[string]any{
+			"event_id":         uuid.NewString(),
+			"user_id":          ann.UserID,
+			"event_type":       util.ExpenseUpdated,
+			"payload":          expense.Payload,
+			"expected_head_id": expense.EventID,
+		})
+		if status != http.StatusBadRequest {
+			t.Errorf("expected head without a linked event: status %d: %s", status, body)
+		}
+	})
+}
+
+func TestDeleteEventBumpsGroupVersion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		group := h.CreateGroup("Trip")
+		expense := h.CreateExpense(group, ann, ann)
+		joined := h.CreateEvent(group, ann, util.GroupUserJoined, events.GroupUserJoin{Name: "Ann", UserId: ann.UserID})
+
+		seen, err := h.Client.GetGroup(ctx, group.GroupID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status, body := h.Do(http.MethodDelete, "/api/v2/events/"+joined.EventID, nil); status != http.StatusOK {
+			t.Fatalf("delete event: status %d: %s", status, body)
+		}
+
+		after, err := h.Client.GetGroup(ctx, group.GroupID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.Version != seen.Version+1 {
+			t.Errorf("group version after a delete is %d, want %d", after.Version, seen.Version+1)
+		}
+		event := &client.Event{
+			EventID:   uuid.NewString(),
+			GroupID:   group.GroupID,
+			UserID:    ann.UserID,
+			EventType: client.ExpenseCreated,
+			Payload:   expense.Payload,
+		}
+		if _, err := h.Client.CreateEvent(ctx, event, client.ExpectGroupVersion(seen.Version)); !client.IsPreconditionFailed(err) {
+			t.Errorf("event at the version before a delete: got %v, want precondition_failed", err)
+		}
+
+		if status, _ := h.Do(http.MethodGet, "/api/v2/events", nil); status != http.StatusNotFound && status != http.StatusMethodNotAllowed {
+			t.Errorf("GET /api/v2/events: status %d, want it not to be routed", status)
+		}
+	})
+}
+
+func TestDeleteEventKeepsExpenseChains(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		group := h.CreateGroup("Trip")
+		expense := h.CreateExpense(group, ann, ann)
+		update, err := h.Client.CreateEvent(ctx, &client.Event{
+			LinkedEventID: expense.EventID,
+			GroupID:       group.GroupID,
+			UserID:        ann.UserID,
+			EventType:     client.ExpenseUpdated,
+			Payload:       expense.Payload,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen, err := h.Client.GetGroup(ctx, group.GroupID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, event := range []*client.Event{expense, update} {
+			status, body := h.Do(http.MethodDelete, "/api/v2/events/"+event.EventID, nil)
+			if status != http.StatusConflict || !hasCode(body, "conflict") {
+				t.Errorf("delete %s: status %d: %s, want a 409 conflict", event.EventType, status, body)
+			}
+		}
+
+		history, err := h.Client.GetExpenseHistory(ctx, expense.EventID)
+		if err != nil || len(history.Revisions) != 2 {
+			t.Errorf("history after refused deletes: got %+v, %v, want both revisions", history, err)
+		}
+		after, err := h.Client.GetGroup(ctx, group.GroupID)
+		if err != nil || after.Version != seen.Version {
+			t.Errorf("group after refused deletes: got %+v, %v, want version %d", after, err, seen.Version)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+)
+
+// ExpenseController handles HTTP requests about expenses, which clients build from
+// the EXPENSE_* events of a group
+type ExpenseController struct {
+	EventRepo repository.EventStore
+}
+
+// NewExpenseController creates a new expense controller
+func NewExpenseController(eventRepo repository.EventStore) *ExpenseController {
+	return &ExpenseController{
+		EventRepo: eventRepo,
+	}
+}
+
+// GetExpensesByGroup handles requests for the expenses of a group, optionally only
+// the active or the deleted ones
+func (c *ExpenseController) GetExpensesByGroup(w http.ResponseWriter, r *http.Request) {
+	// Get group ID from URL
+	groupID := param(r, "group_id")
+	if groupID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
+		return
+	}
+
+	status := domain.ExpenseStatus(r.URL.Query().Get("status"))
+	if status != "" && status != domain.ExpenseActive && status != domain.ExpenseDeleted {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Status must be active or deleted")
+		return
+	}
+
+	groupEvents, err := c.EventRepo.GetByGroupID(r.Context(), groupID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get events", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get expenses")
+		return
+	}
+
+	expenses := services.FoldExpenses(groupEvents)
+	if status != "" {
+		expenses = slices.DeleteFunc(expenses, func(e *domain.Expense) bool { return e.Status != status })
+	}
+	if expenses == nil {
+		expenses = make([]*domain.Expense, 0)
+	}
+
+	// Return expenses
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(expenses)
+}
+
+// GetExpenseHistory handles requests for the revisions of an expense
+func (c *ExpenseController) GetExpenseHistory(w http.ResponseWriter, r *http.Request) {
+	// Get the ID of the EXPENSE_CREATED event
+	eventID := param(r, "event_id")
+	if eventID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Event ID is required")
+		return
+	}
+
+	expense, err := c.EventRepo.GetByID(r.Context(), eventID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get expense history", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get expense history")
+		return
+	}
+
+	// Only the expense's own events are part of its history, whatever else links to it
+	chain, err := c.EventRepo.GetChain(r.Context(), expense.GroupID, expense.EventID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Failed to get expense history", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get expense history")
+		return
+	}
+
+	history, err := services.BuildExpenseHistory(expense.EventID, chain)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Event has no expense history", "event_id", eventID, "error", err)
+		writeError(w, r, fmt.Errorf("%w: %v", repository.ErrValidation, err), CodeEventNotFound, "Event is not an expense")
+		return
+	}
+
+	// Return history
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,346 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/client"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+	"github.com/google/uuid"
+)
+
+func TestExpenseHistory(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		bob := h.CreateUser("Bob")
+		group := h.CreateGroup("Trip")
+		expense := h.CreateExpense(group, ann, ann, bob)
+
+		edited := events.ExpenseUpdated(apitest.Expense(ann, ann, bob))
+		edited.Description = "Dinner and drinks"
+		edited.Total = 30
+		edited.PaidBy[0].Amount = 30
+		update, err := client.NewExpenseUpdate(group.GroupID, bob.UserID, expense.EventID, edited)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := h.Client.CreateEvent(ctx, update); err != nil {
+			t.Fatal(err)
+		}
+
+		history, err := h.Client.GetExpenseHistory(ctx, expense.EventID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history.Revisions) != 2 || history.Deleted || history.GroupID != group.GroupID {
+			t.Fatalf("history is %+v, want the creation and one edit", history)
+		}
+		var fields []string
+		for _, change := range history.Revisions[1].Changes {
+			fields = append(fields, change.Field)
+		}
+		if want := []string{"description", "paid_by", "total"}; !slices.Equal(fields, want) {
+			t.Errorf("edit changed %v, want %v", fields, want)
+		}
+		var current events.ExpenseCreated
+		json.Unmarshal(history.Expense, &current)
+		if current.Total != 30 || current.Description != "Dinner and drinks" {
+			t.Errorf("current expense is %+v, want the edited one", current)
+		}
+
+		// Updates must link to an expense of the same group that still exists
+		other := h.CreateGroup("Other trip")
+		joined := h.CreateEvent(group, ann, util.GroupUserJoined, events.GroupUserJoin{Name: "Ann", UserId: ann.UserID})
+		tests := []struct {
+			name    string
+			groupID string
+			linked  string
+			status  int
+		}{
+			{"no linked expense", group.GroupID, "", http.StatusBadRequest},
+			{"linked to another event type", group.GroupID, joined.EventID, http.StatusBadRequest},
+			{"expense in another group", other.GroupID, expense.EventID, http.StatusBadRequest},
+			{"missing expense", group.GroupID, uuid.NewString(), http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			status, body := h.Do(http.MethodPost, "/api/v2/groups/"+tt.groupID+"/events", map[string]any{
+				"event_id":        uuid.NewString(),
+				"linked_event_id": tt.linked,
+				"user_id":         ann.UserID,
+				"event_type":      util.ExpenseUpdated,
+				"payload":         edited,
+			})
+			if status != tt.status {
+				t.Errorf("%s: status %d, want %d: %s", tt.name, status, tt.status, body)
+			}
+		}
+
+		// Events that are not expense events don't join the expense's chain by linking to it
+		unrelated := &client.Event{
+			LinkedEventID: expense.EventID,
+			GroupID:       group.GroupID,
+			UserID:        ann.UserID,
+			EventType:     client.UserNameChanged,
+			Payload:       json.RawMessage(`{"name":"Annie"}`),
+		}
+		if _, err := h.Client.CreateEvent(ctx, unrelated); err != nil {
+			t.Fatal(err)
+		}
+
+		deletion := &client.Event{
+			EventID:       uuid.NewString(),
+			LinkedEventID: expense.EventID,
+			GroupID:       group.GroupID,
+			UserID:        ann.UserID,
+			EventType:     client.ExpenseDeleted,
+			Payload:       expense.Payload,
+		}
+		if _, err := h.Client.CreateEvent(ctx, deletion, client.ExpectHead(update.EventID)); err != nil {
+			t.Fatal(err)
+		}
+		late, _ := client.NewExpenseUpdate(group.GroupID, bob.UserID, expense.EventID, edited)
+		if _, err := h.Client.CreateEvent(ctx, late); err == nil {
+			t.Error("update of a deleted expense was accepted")
+		}
+
+		status, body := h.Do(http.MethodGet, "/api/expenses/history?event_id="+expense.EventID, nil)
+		if status != http.StatusOK {
+			t.Fatalf("history: status %d: %s", status, body)
+		}
+		json.Unmarshal(body, &history)
+		if len(history.Revisions) != 3 || !history.Deleted {
+			t.Errorf("history after deletion has %d revisions, deleted %t", len(history.Revisions), history.Deleted)
+		}
+
+		if status, body := h.Do(http.MethodGet, "/api/v2/expenses/"+joined.EventID+"/history", nil); status != http.StatusBadRequest {
+			t.Errorf("history of a non-expense: status %d: %s", status, body)
+		}
+	})
+}
+
+func TestExpenseDeletion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		bob := h.CreateUser("Bob")
+		group := h.CreateGroup("Trip")
+		lunch := h.CreateExpense(group, ann, ann, bob)
+		dinner := h.CreateExpense(group, bob, ann, bob)
+		joined := h.CreateEvent(group, ann, util.GroupUserJoined, events.GroupUserJoin{Name: "Ann", UserId: ann.UserID})
+		other := h.CreateGroup("Other trip")
+		elsewhere := h.CreateExpense(other, ann, ann)
+
+		change := func(eventType client.EventType, expense *client.Event) (*client.Event, error) {
+			return h.Client.CreateEvent(ctx, &client.Event{
+				LinkedEventID: expense.EventID,
+				GroupID:       group.GroupID,
+				UserID:        ann.UserID,
+				EventType:     eventType,
+				Payload:       expense.Payload,
+			})
+		}
+		statuses := func() map[string]client.ExpenseStatus {
+			expenses, err := h.Client.ListExpenses(ctx, group.GroupID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := make(map[string]client.ExpenseStatus)
+			for _, expense := range expenses {
+				statuses[expense.ExpenseID] = expense.Status
+			}
+			return statuses
+		}
+
+		status, body := h.Do(http.MethodPost, "/api/v2/groups/"+group.GroupID+"/events", map[string]any{
+			"event_id":   uuid.NewString(),
+			"user_id":    ann.UserID,
+			"event_type": util.ExpenseDeleted,
+			"payload":    lunch.Payload,
+		})
+		if status != http.StatusBadRequest {
+			t.Errorf("deletion without a linked expense: status %d: %s", status, body)
+		}
+		if _, err := change(client.ExpenseDeleted, joined); err == nil {
+			t.Error("deletion of a non-expense was accepted")
+		}
+		if _, err := change(client.ExpenseDeleted, elsewhere); err == nil {
+			t.Error("deletion of another group's expense was accepted")
+		}
+		if _, err := change(client.ExpenseRestored, lunch); err == nil {
+			t.Error("restore of an active expense was accepted")
+		}
+
+		if _, err := change(client.ExpenseDeleted, lunch); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := change(client.ExpenseDeleted, lunch); err == nil {
+			t.Error("second deletion of an expense was accepted")
+		}
+		if got := statuses(); got[lunch.EventID] != client.ExpenseStatusDeleted || got[dinner.EventID] != client.ExpenseStatusActive || len(got) != 2 {
+			t.Errorf("expenses after deleting lunch: %v", got)
+		}
+		deleted, err := h.Client.ListExpenses(ctx, group.GroupID, client.ExpenseStatusDeleted)
+		if err != nil || len(deleted) != 1 || deleted[0].ExpenseID != lunch.EventID {
+			t.Errorf("deleted expenses: got %v, %v, want lunch only", deleted, err)
+		}
+
+		// Undo
+		if _, err := change(client.ExpenseRestored, lunch); err != nil {
+			t.Fatal(err)
+		}
+		if got := statuses(); got[lunch.EventID] != client.ExpenseStatusActive {
+			t.Errorf("restored expense is %s", got[lunch.EventID])
+		}
+
+		// A deletion recorded offline before the restore would not take effect on replay
+		stale := &client.Event{
+			LinkedEventID: lunch.EventID,
+			GroupID:       group.GroupID,
+			UserID:        bob.UserID,
+			EventType:     client.ExpenseDeleted,
+			Payload:       lunch.Payload,
+			HLC:           hlc.HLC{Wall: lunch.HLC.Wall, Logical: lunch.HLC.Logical + 1},
+		}
+		if _, err := h.Client.CreateEvent(ctx, stale); err == nil {
+			t.Error("deletion ordering before the restore was accepted")
+		}
+
+		if status, body := h.Do(http.MethodGet, "/api/expenses/by-group?group_id="+group.GroupID+"&status=gone", nil); status != http.StatusBadRequest {
+			t.Errorf("unknown status: status %d: %s", status, body)
+		}
+	})
+}
+
+func TestGroupCurrencies(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		bob := h.CreateUser("Bob")
+
+		create := func(group *client.Group, eventType client.EventType, payload any) error {
+			event, err := client.NewEvent(group.GroupID, ann.UserID, eventType, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = h.Client.CreateEvent(ctx, event)
+			return err
+		}
+		change := func(group *client.Group, eventType client.EventType, expense *client.Event, payload any) error {
+			event, err := client.NewEvent(group.GroupID, ann.UserID, eventType, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			event.LinkedEventID = expense.EventID
+			_, err = h.Client.CreateEvent(ctx, event)
+			return err
+		}
+		invalidCurrency := func(err error) bool {
+			var apiErr *client.Error
+			return errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest && apiErr.Code == "invalid_currency"
+		}
+		expense := func(currency string, paidBy *client.User) events.ExpenseCreated {
+			expense := apitest.Expense(paidBy, ann, bob)
+			expense.Currency = currency
+			return expense
+		}
+		currencyEvent := func(currency string) map[string]any {
+			return map[string]any{"currency": currency, "date_time": time.Now()}
+		}
+
+		// Groups without currency events accept any valid currency
+		legacy := h.CreateGroup("Legacy")
+		dinner := h.CreateEvent(legacy, ann, util.ExpenseCreated, expense("JPY", ann))
+		if err := create(legacy, client.ExpenseCreated, expense("usd", ann)); err == nil {
+			t.Error("expense in an invalid currency was accepted")
+		}
+
+		group := h.CreateGroup("Trip")
+		for _, currency := range []string{"usd", "XXX", ""} {
+			if err := create(group, client.GroupAddCurrency, currencyEvent(currency)); err == nil {
+				t.Errorf("currency %q was added", currency)
+			}
+		}
+		h.CreateEvent(group, ann, util.GroupSetDefaultCurrency, events.GroupSetDefaultCurrency{Currency: "EUR"})
+		h.CreateEvent(group, ann, util.GroupAddCurrency, events.GroupAddCurrency{Currency: "USD"})
+		h.CreateEvent(group, ann, util.GroupAddCurrency, events.GroupAddCurrency{Currency: "GBP"})
+
+		lunch := h.CreateEvent(group, ann, util.ExpenseCreated, expense("USD", ann))
+		if err := create(group, client.ExpenseCreated, expense("JPY", ann)); !invalidCurrency(err) {
+			t.Errorf("expense in a currency that isn't enabled: got %v, want a 400 invalid_currency", err)
+		}
+		for _, payload := range []any{"x", []string{"USD"}} {
+			if err := create(group, client.ExpenseCreated, payload); !invalidCurrency(err) {
+				t.Errorf("expense with payload %v: got %v, want a 400 invalid_currency", payload, err)
+			}
+			if err := create(group, client.GroupAddCurrency, payload); !invalidCurrency(err) {
+				t.Errorf("currency event with payload %v: got %v, want a 400 invalid_currency", payload, err)
+			}
+		}
+
+		if err := create(group, client.GroupRemoveCurrency, currencyEvent("EUR")); err == nil {
+			t.Error("the default currency was removed")
+		}
+		if err := create(group, client.GroupRemoveCurrency, currencyEvent("JPY")); err == nil {
+			t.Error("a currency that isn't enabled was removed")
+		}
+		if err := create(group, client.GroupRemoveCurrency, currencyEvent("USD")); err == nil {
+			t.Error("a currency with outstanding balances was removed")
+		}
+		if err := create(group, client.GroupRemoveCurrency, currencyEvent("GBP")); err != nil {
+			t.Errorf("unused currency: %v", err)
+		}
+
+		// Bob pays back what Ann paid, settling USD
+		payback := h.CreateEvent(group, bob, util.ExpenseCreated, expense("USD", bob))
+		if err := create(group, client.GroupRemoveCurrency, currencyEvent("USD")); err != nil {
+			t.Errorf("settled currency: %v", err)
+		}
+		if err := create(group, client.ExpenseCreated, expense("USD", ann)); !invalidCurrency(err) {
+			t.Errorf("expense in a removed currency: got %v, want a 400 invalid_currency", err)
+		}
+
+		// Deleted expenses stay in their currency, and can't be restored once it is removed,
+		// whatever the payload of the restore says
+		for _, deleted := range []*client.Event{lunch, payback} {
+			if err := change(group, client.ExpenseDeleted, deleted, deleted.Payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := change(group, client.ExpenseRestored, lunch, expense("EUR", ann)); !invalidCurrency(err) {
+			t.Errorf("restore of an expense in a removed currency: got %v, want a 400 invalid_currency", err)
+		}
+
+		// The first currency event of a legacy group keeps its expenses' currencies enabled
+		h.CreateEvent(legacy, ann, util.GroupAddCurrency, events.GroupAddCurrency{Currency: "EUR"})
+		if err := change(legacy, client.ExpenseUpdated, dinner, expense("JPY", bob)); err != nil {
+			t.Errorf("edit of an expense recorded before the first currency event: %v", err)
+		}
+		if err := create(legacy, client.ExpenseCreated, expense("GBP", ann)); !invalidCurrency(err) {
+			t.Errorf("expense in a currency the legacy group never used: got %v, want a 400 invalid_currency", err)
+		}
+		if err := create(legacy, client.GroupRemoveCurrency, currencyEvent("JPY")); !invalidCurrency(err) {
+			t.Errorf("removal of a legacy currency with outstanding balances: got %v, want a 400 invalid_currency", err)
+		}
+		if err := change(legacy, client.ExpenseDeleted, dinner, dinner.Payload); err != nil {
+			t.Fatal(err)
+		}
+		if err := create(legacy, client.GroupRemoveCurrency, currencyEvent("JPY")); err != nil {
+			t.Errorf("removal of a settled legacy currency: %v", err)
+		}
+		if err := change(legacy, client.ExpenseRestored, dinner, dinner.Payload); !invalidCurrency(err) {
+			t.Errorf("restore of a legacy expense in a removed currency: got %v, want a 400 invalid_currency", err)
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/RealZimboGuy/budgetApp/internal/domain"
@@ -11,11 +13,11 @@ import (
 
 // GroupController handles HTTP requests related to groups
 type GroupController struct {
-	GroupRepo *repository.GroupRepository
+	GroupRepo repository.GroupStore
 }
 
 // NewGroupController creates a new group controller
-func NewGroupController(groupRepo *repository.GroupRepository) *GroupController {
+func NewGroupController(groupRepo repository.GroupStore) *GroupController {
 	return &GroupController{
 		GroupRepo: groupRepo,
 	}
@@ -24,28 +26,35 @@ func NewGroupController(groupRepo *repository.GroupRepository) *GroupController
 // CreateGroup handles group creation requests
 func (c *GroupController) CreateGroup(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
-	var reqBody struct {
-		Name string `json:"name"`
-	}
+	var reqBody createGroupRequest
 
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
 	// Validate request
 	if reqBody.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Name is required")
 		return
 	}
+	if reqBody.GroupID != "" {
+		groupID, ok := clientID(reqBody.GroupID)
+		if !ok {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Group ID must be a version 4 or 7 UUID")
+			return
+		}
+		reqBody.GroupID = groupID
+	}
 
 	// Create group
 	group := domain.NewGroup(reqBody.Name)
-	err = c.GroupRepo.Create(r.Context(), group)
+	group.GroupID = reqBody.GroupID
+	group, err = c.createGroup(r.Context(), group)
 	if err != nil {
-		log.Printf("Failed to create group: %v", err)
-		http.Error(w, "Failed to create group", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to create group", "error", err)
+		writeError(w, r, err, CodeGroupNotFound, "Failed to create group")
 		return
 	}
 
@@ -55,20 +64,38 @@ func (c *GroupController) CreateGroup(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(group)
 }
 
+// createGroup adds the group. A group stored earlier under the same client-chosen
+// ID is returned instead, whatever it has been renamed to since, so a client
+// syncing groups created offline can send them again. Client-chosen IDs are
+// random UUIDs, so the ID alone identifies the group.
+func (c *GroupController) createGroup(ctx context.Context, group *domain.Group) (*domain.Group, error) {
+	clientChosen := group.GroupID != ""
+	err := c.GroupRepo.Create(ctx, group)
+	if !clientChosen || !errors.Is(err, repository.ErrConflict) {
+		return group, err
+	}
+
+	existing, getErr := c.GroupRepo.GetByID(ctx, group.GroupID)
+	if getErr != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
 // GetGroup handles group retrieval requests
 func (c *GroupController) GetGroup(w http.ResponseWriter, r *http.Request) {
 	// Get group ID from URL
-	groupID := r.URL.Query().Get("id")
+	groupID := param(r, "id")
 	if groupID == "" {
-		http.Error(w, "Group ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
 		return
 	}
 
 	// Get group
 	group, err := c.GroupRepo.GetByID(r.Context(), groupID)
 	if err != nil {
-		log.Printf("Failed to get group: %v", err)
-		http.Error(w, "Failed to get group", http.StatusNotFound)
+		slog.ErrorContext(r.Context(), "Failed to get group", "error", err)
+		writeError(w, r, err, CodeGroupNotFound, "Failed to get group")
 		return
 	}
 
@@ -82,8 +109,8 @@ func (c *GroupController) GetAllGroups(w http.ResponseWriter, r *http.Request) {
 	// Get all groups
 	groups, err := c.GroupRepo.GetAll(r.Context())
 	if err != nil {
-		log.Printf("Failed to get groups: %v", err)
-		http.Error(w, "Failed to get groups", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get groups", "error", err)
+		writeError(w, r, err, CodeGroupNotFound, "Failed to get groups")
 		return
 	}
 
@@ -95,26 +122,24 @@ func (c *GroupController) GetAllGroups(w http.ResponseWriter, r *http.Request) {
 // UpdateGroup handles group update requests
 func (c *GroupController) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 	// Get group ID from URL
-	groupID := r.URL.Query().Get("id")
+	groupID := param(r, "id")
 	if groupID == "" {
-		http.Error(w, "Group ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
 		return
 	}
 
 	// Parse request body
-	var reqBody struct {
-		Name string `json:"name"`
-	}
+	var reqBody updateGroupRequest
 
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
 	// Validate request
 	if reqBody.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Name is required")
 		return
 	}
 
@@ -126,8 +151,8 @@ func (c *GroupController) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 
 	err = c.GroupRepo.Update(r.Context(), group)
 	if err != nil {
-		log.Printf("Failed to update group: %v", err)
-		http.Error(w, "Failed to update group", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to update group", "error", err)
+		writeError(w, r, err, CodeGroupNotFound, "Failed to update group")
 		return
 	}
 
@@ -139,17 +164,17 @@ func (c *GroupController) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 // DeleteGroup handles group deletion requests
 func (c *GroupController) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	// Get group ID from URL
-	groupID := r.URL.Query().Get("id")
+	groupID := param(r, "id")
 	if groupID == "" {
-		http.Error(w, "Group ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
 		return
 	}
 
 	// Delete group
 	err := c.GroupRepo.Delete(r.Context(), groupID)
 	if err != nil {
-		log.Printf("Failed to delete group: %v", err)
-		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to delete group", "error", err)
+		writeError(w, r, err, CodeGroupNotFound, "Failed to delete group")
 		return
 	}
 
@@ -161,17 +186,17 @@ func (c *GroupController) DeleteGroup(w http.ResponseWriter, r *http.Request) {
 // GetGroupsByUser handles requests to get all groups for a user
 func (c *GroupController) GetGroupsByUser(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from URL
-	userID := r.URL.Query().Get("user_id")
+	userID := param(r, "user_id")
 	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
 		return
 	}
 
 	// Get groups for user
 	groups, err := c.GroupRepo.GetByUserID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to get groups for user: %v", err)
-		http.Error(w, "Failed to get groups", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get groups for user", "error", err)
+		writeError(w, r, err, CodeGroupNotFound, "Failed to get groups")
 		return
 	}
 
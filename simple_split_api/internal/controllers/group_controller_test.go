@@ -0,0 +1,76 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/pkg/client"
+	"github.com/google/uuid"
+)
+
+func TestCreateGroupWithClientID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+
+		// Everything is created offline first and synced afterwards
+		userID, groupID := uuid.NewString(), strings.ToUpper(uuid.NewString())
+		event, err := client.NewEvent(groupID, userID, client.GroupUserJoined, map[string]string{"user_id": userID, "name": "Ann"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := h.Client.CreateUserWithID(ctx, userID, "Ann"); err != nil {
+			t.Fatal(err)
+		}
+		group, err := h.Client.CreateGroupWithID(ctx, groupID, "Trip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if group.GroupID != strings.ToLower(groupID) {
+			t.Errorf("created group %s, want the client's ID %s", group.GroupID, strings.ToLower(groupID))
+		}
+		event.GroupID = group.GroupID
+		if _, err := h.Client.CreateEvent(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+
+		// A resent group is answered with the stored one
+		again, err := h.Client.CreateGroupWithID(ctx, groupID, "Trip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.GroupID != group.GroupID || !again.CreatedAt.Equal(group.CreatedAt) {
+			t.Errorf("resent create returned %+v, want the stored group %+v", again, group)
+		}
+		groups, err := h.Stores.Groups.GetAll(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 1 {
+			t.Errorf("got %d groups after a resent create, want 1", len(groups))
+		}
+
+		// A group renamed since is still the one the resent create made
+		if _, err := h.Client.UpdateGroup(ctx, group.GroupID, "Other trip"); err != nil {
+			t.Fatal(err)
+		}
+		again, err = h.Client.CreateGroupWithID(ctx, groupID, "Trip")
+		if err != nil {
+			t.Fatalf("resent create after a rename: %v", err)
+		}
+		if again.Name != "Other trip" {
+			t.Errorf("resent create after a rename returned %q, want the stored name", again.Name)
+		}
+
+		for _, id := range []string{"not-a-uuid", uuid.Must(uuid.NewUUID()).String()} {
+			status, body := h.Do(http.MethodPost, "/api/v2/groups", map[string]string{"group_id": id, "name": "Trip"})
+			if status != http.StatusBadRequest {
+				t.Errorf("group ID %s: status %d: %s", id, status, body)
+			}
+		}
+	})
+}
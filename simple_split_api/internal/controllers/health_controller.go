@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/buildinfo"
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/migrations"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// readinessTimeout bounds how long a readiness probe waits on the database
+const readinessTimeout = 2 * time.Second
+
+// HealthController handles liveness, readiness and version probes. DB and
+// Migrator are nil when the data is kept in memory.
+type HealthController struct {
+	DB       *util.Database
+	Migrator *migrations.Migrator
+	Config   *config.Config
+}
+
+// NewHealthController creates a new health controller
+func NewHealthController(db *util.Database, migrator *migrations.Migrator, cfg *config.Config) *HealthController {
+	return &HealthController{
+		DB:       db,
+		Migrator: migrator,
+		Config:   cfg,
+	}
+}
+
+// healthCheck is the result of a single readiness check
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// readinessResponse is the body of readiness responses
+type readinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+// versionResponse is the body of version responses
+type versionResponse struct {
+	buildinfo.Info
+	SchemaVersion       *int `json:"schema_version"`
+	LatestSchemaVersion int  `json:"latest_schema_version"`
+}
+
+// Healthz reports that the process is alive. It deliberately checks nothing else
+// so that a database outage does not get the process restarted.
+func (c *HealthController) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// Readyz reports whether the instance can serve traffic: the database answers,
+// every migration has been applied and the enabled notifiers are configured
+func (c *HealthController) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]healthCheck{
+		"database":      c.checkDatabase(ctx),
+		"migrations":    c.checkMigrations(ctx),
+		"notifications": c.checkNotifications(),
+	}
+
+	ready := true
+	for name, check := range checks {
+		if check.Status != "ok" && check.Status != "disabled" {
+			ready = false
+			slog.WarnContext(ctx, "Readiness check failed", "check", name, "error", check.Error)
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(readinessResponse{Status: status, Checks: checks})
+}
+
+// Version reports the build and the database schema version
+func (c *HealthController) Version(w http.ResponseWriter, r *http.Request) {
+	response := versionResponse{
+		Info: buildinfo.Get(),
+	}
+	if c.Migrator == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	response.LatestSchemaVersion = c.Migrator.LatestVersion()
+
+	// The schema version is left null rather than failing the request when the database is down
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	if version, err := c.Migrator.AppliedVersion(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to get schema version", "error", err)
+	} else {
+		response.SchemaVersion = &version
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (c *HealthController) checkDatabase(ctx context.Context) healthCheck {
+	if c.DB == nil {
+		return healthCheck{Status: "disabled"}
+	}
+	if err := c.DB.DB.PingContext(ctx); err != nil {
+		slog.WarnContext(ctx, "Database ping failed", "error", err)
+		return healthCheck{Status: "failed", Error: "database is not reachable"}
+	}
+	return healthCheck{Status: "ok"}
+}
+
+func (c *HealthController) checkMigrations(ctx context.Context) healthCheck {
+	if c.Migrator == nil {
+		return healthCheck{Status: "disabled"}
+	}
+	pending, err := c.Migrator.Pending(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to read migration status", "error", err)
+		return healthCheck{Status: "failed", Error: "failed to read migration status"}
+	}
+	if len(pending) > 0 {
+		return healthCheck{Status: "pending", Error: "migrations have not been applied, run `simplesplit migrate`"}
+	}
+	return healthCheck{Status: "ok"}
+}
+
+func (c *HealthController) checkNotifications() healthCheck {
+	if !c.Config.Features.PushNotifications {
+		return healthCheck{Status: "disabled"}
+	}
+	if !c.Config.PushEnabled() {
+		return healthCheck{Status: "failed", Error: "push notifications are enabled but FIREBASE_URL is not set"}
+	}
+	return healthCheck{Status: "ok"}
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	// IdempotencyKeyHeader carries a client-chosen key that makes a request safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a retried request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength leaves room for any UUID or random string a client picks
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotencyResponseWriter passes the response through while keeping a copy of it
+type idempotencyResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (irw *idempotencyResponseWriter) WriteHeader(code int) {
+	irw.statusCode = code
+	irw.ResponseWriter.WriteHeader(code)
+}
+
+func (irw *idempotencyResponseWriter) Write(data []byte) (int, error) {
+	irw.body.Write(data)
+	return irw.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (irw *idempotencyResponseWriter) Unwrap() http.ResponseWriter {
+	return irw.ResponseWriter
+}
+
+// IdempotencyMiddleware makes requests sent with an Idempotency-Key header safe to
+// retry. The response to the first request with a key is stored for ttl and replayed
+// to retries, which must have the same method, URL and body. Reusing a key for a
+// different request is rejected with 422, and a retry arriving while the first
+// request is still being handled with 409. Server errors release the key so the
+// request can be retried for real, and a key whose request has not completed within
+// lease, e.g. because the server crashed, can be claimed again.
+func IdempotencyMiddleware(store repository.IdempotencyStore, ttl time.Duration, lease time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeBodyError(w, r, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			claimed := &domain.IdempotencyKey{
+				Key:         key,
+				ClaimID:     uuid.NewString(),
+				Fingerprint: fingerprint(r, body),
+				ExpiresAt:   now.Add(ttl),
+				LeaseUntil:  now.Add(lease),
+			}
+			err = store.Create(r.Context(), claimed)
+			if errors.Is(err, repository.ErrConflict) {
+				replay(w, r, store, claimed)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to store idempotency key", "error", err)
+				writeProblem(w, r, http.StatusInternalServerError, CodeIdempotencyStoreFailed, "Failed to store idempotency key")
+				return
+			}
+
+			// The outcome is recorded even when the client has gone away, it may retry
+			ctx := context.WithoutCancel(r.Context())
+			irw := &idempotencyResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			defer func() {
+				if p := recover(); p != nil {
+					release(ctx, store, claimed)
+					panic(p)
+				}
+			}()
+
+			next.ServeHTTP(irw, r)
+
+			if irw.statusCode >= http.StatusInternalServerError {
+				release(ctx, store, claimed)
+				return
+			}
+			claimed.ResponseStatus = irw.statusCode
+			claimed.ResponseContentType = irw.Header().Get("Content-Type")
+			claimed.ResponseBody = irw.body.Bytes()
+			err = store.Complete(ctx, claimed)
+			if errors.Is(err, repository.ErrNotFound) {
+				// The lease passed and a retry holds the key now, its response is the one kept
+				slog.WarnContext(ctx, "Lost the lease on idempotency key before storing the response", "error", err)
+				return
+			}
+			if err != nil {
+				// A retry can't be answered with the response, let it run the request again
+				slog.ErrorContext(ctx, "Failed to store response for idempotency key", "error", err)
+				release(ctx, store, claimed)
+			}
+		})
+	}
+}
+
+// replay answers a request whose idempotency key is already held
+func replay(w http.ResponseWriter, r *http.Request, store repository.IdempotencyStore, claimed *domain.IdempotencyKey) {
+	stored, err := store.GetByKey(r.Context(), claimed.Key)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Released by a failed first request since the key was found taken
+		writeInProgress(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get idempotency key", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, CodeIdempotencyStoreFailed, "Failed to get idempotency key")
+		return
+	}
+
+	if stored.Fingerprint != claimed.Fingerprint {
+		writeProblem(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if !stored.Completed() {
+		writeInProgress(w, r)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Replaying response for idempotency key", "status", stored.ResponseStatus)
+	if stored.ResponseContentType != "" {
+		w.Header().Set("Content-Type", stored.ResponseContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.ResponseStatus)
+	w.Write(stored.ResponseBody)
+}
+
+// writeInProgress asks the client to retry once the first request with its key is done
+func writeInProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	writeProblem(w, r, http.StatusConflict, CodeRequestInProgress, "A request with this Idempotency-Key is being handled, retry later")
+}
+
+// release deletes an idempotency key whose request failed, unless another claim
+// has taken it over since
+func release(ctx context.Context, store repository.IdempotencyStore, claimed *domain.IdempotencyKey) {
+	if err := store.Delete(ctx, claimed); err != nil {
+		slog.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+	}
+}
+
+// fingerprint hashes what identifies a request: its method, URL and body
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
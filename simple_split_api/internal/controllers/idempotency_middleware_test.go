@@ -0,0 +1,138 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+)
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		header := http.Header{"Idempotency-Key": {"key-1"}}
+		body := map[string]string{"name": "Ann"}
+
+		first, firstBody := h.DoWithHeader(http.MethodPost, "/api/v2/users", header, body)
+		if first.StatusCode != http.StatusOK {
+			t.Fatalf("first create: status %d: %s", first.StatusCode, firstBody)
+		}
+		if first.Header.Get("Idempotent-Replayed") != "" {
+			t.Error("first response is marked as replayed")
+		}
+
+		retry, retryBody := h.DoWithHeader(http.MethodPost, "/api/v2/users", header, body)
+		if retry.StatusCode != http.StatusOK || string(retryBody) != string(firstBody) {
+			t.Errorf("retry: status %d: %s, want the first response %s", retry.StatusCode, retryBody, firstBody)
+		}
+		if retry.Header.Get("Idempotent-Replayed") != "true" {
+			t.Error("retried response is not marked as replayed")
+		}
+		if got := retry.Header.Get("Content-Type"); got != first.Header.Get("Content-Type") {
+			t.Errorf("retry has content type %q, want %q", got, first.Header.Get("Content-Type"))
+		}
+
+		users, err := h.Stores.Users.GetAll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 1 {
+			t.Errorf("got %d users after a retry, want 1", len(users))
+		}
+
+		reused, reusedBody := h.DoWithHeader(http.MethodPost, "/api/v2/users", header, map[string]string{"name": "Bob"})
+		if reused.StatusCode != http.StatusUnprocessableEntity || !hasCode(reusedBody, "idempotency_key_reused") {
+			t.Errorf("key reused for another body: status %d: %s", reused.StatusCode, reusedBody)
+		}
+	})
+}
+
+func TestCreateGroupIdempotencyKey(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		body := map[string]string{"name": "Trip"}
+
+		// Without a key every request creates a group
+		h.Do(http.MethodPost, "/api/v2/groups", body)
+		h.Do(http.MethodPost, "/api/v2/groups", body)
+
+		header := http.Header{"Idempotency-Key": {"key-1"}}
+		var created []domain.Group
+		for range 3 {
+			resp, respBody := h.DoWithHeader(http.MethodPost, "/api/groups/create", header, body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("create with key: status %d: %s", resp.StatusCode, respBody)
+			}
+			var group domain.Group
+			json.Unmarshal(respBody, &group)
+			created = append(created, group)
+		}
+		for _, group := range created[1:] {
+			if group.GroupID != created[0].GroupID {
+				t.Errorf("retry returned group %s, want %s", group.GroupID, created[0].GroupID)
+			}
+		}
+
+		groups, err := h.Stores.Groups.GetAll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 3 {
+			t.Errorf("got %d groups, want 2 created without a key and 1 with", len(groups))
+		}
+
+		// The same key on another route is a different request
+		resp, respBody := h.DoWithHeader(http.MethodPost, "/api/v2/groups", header, body)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("key reused on another route: status %d: %s", resp.StatusCode, respBody)
+		}
+
+		tooLong := http.Header{"Idempotency-Key": {strings.Repeat("k", 256)}}
+		resp, respBody = h.DoWithHeader(http.MethodPost, "/api/v2/groups", tooLong, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("key of 256 characters: status %d: %s", resp.StatusCode, respBody)
+		}
+	})
+}
+
+func TestAbandonedIdempotencyKey(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ctx := context.Background()
+		body := map[string]string{"name": "Ann"}
+
+		// Keys left incomplete by a crashed server, one still within its lease
+		for key, lease := range map[string]time.Duration{"held": time.Minute, "abandoned": -time.Second} {
+			claimed := &domain.IdempotencyKey{Key: key, Fingerprint: "crashed", ExpiresAt: time.Now().Add(time.Hour), LeaseUntil: time.Now().Add(lease)}
+			if err := h.Stores.Idempotency.Create(ctx, claimed); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp, respBody := h.DoWithHeader(http.MethodPost, "/api/v2/users", http.Header{"Idempotency-Key": {"held"}}, body)
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("key within its lease: status %d: %s", resp.StatusCode, respBody)
+		}
+		resp, respBody = h.DoWithHeader(http.MethodPost, "/api/v2/users", http.Header{"Idempotency-Key": {"abandoned"}}, body)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("key past its lease: status %d: %s", resp.StatusCode, respBody)
+		}
+		stored, err := h.Stores.Idempotency.GetByKey(ctx, "abandoned")
+		if err != nil || !stored.Completed() {
+			t.Errorf("reclaimed key: got %+v, %v, want it completed", stored, err)
+		}
+	})
+}
+
+// hasCode reports whether a problem response body has the given code
+func hasCode(body []byte, code string) bool {
+	var problem struct {
+		Code string `json:"code"`
+	}
+	return json.Unmarshal(body, &problem) == nil && problem.Code == code
+}
@@ -0,0 +1,28 @@
+package controllers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+)
+
+func TestMetricsMethodLabel(t *testing.T) {
+	h := apitest.New(t, config.BackendMemory)
+
+	// Legacy routes match any method
+	h.Do("MADEUP", "/api/users/get?id=unknown", nil)
+
+	status, body := h.Do(http.MethodGet, "/metrics", nil)
+	if status != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", status)
+	}
+	if strings.Contains(string(body), "MADEUP") {
+		t.Errorf("custom method used as a label")
+	}
+	if !strings.Contains(string(body), `method="other"`) {
+		t.Errorf("no request labelled with method other")
+	}
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+)
+
+// NotificationController handles HTTP requests that send notifications on demand
+type NotificationController struct {
+	EventRepo    repository.EventStore
+	GroupRepo    repository.GroupStore
+	EmailService *services.EmailService
+}
+
+// NewNotificationController creates a new notification controller
+func NewNotificationController(
+	eventRepo repository.EventStore,
+	groupRepo repository.GroupStore,
+	emailService *services.EmailService,
+) *NotificationController {
+	return &NotificationController{
+		EventRepo:    eventRepo,
+		GroupRepo:    groupRepo,
+		EmailService: emailService,
+	}
+}
+
+// SendSettlementPlan handles requests to email a group's settlement plan to its members
+func (c *NotificationController) SendSettlementPlan(w http.ResponseWriter, r *http.Request) {
+	c.sendGroupEmails(w, r, c.EmailService.SendSettlementPlan)
+}
+
+// SendReminders handles requests to email payment reminders to members who owe money
+func (c *NotificationController) SendReminders(w http.ResponseWriter, r *http.Request) {
+	c.sendGroupEmails(w, r, c.EmailService.SendReminders)
+}
+
+func (c *NotificationController) sendGroupEmails(w http.ResponseWriter, r *http.Request, send func(ctx context.Context, group *domain.Group, balances services.Balances) int) {
+	// Ensure this endpoint only accepts POST requests
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if c.EmailService == nil {
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeServiceUnavailable, "Email notifications are not configured")
+		return
+	}
+
+	// Get group ID from URL
+	groupID := param(r, "group_id")
+	if groupID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
+		return
+	}
+
+	group, err := c.GroupRepo.GetByID(r.Context(), groupID)
+	if err != nil {
+		writeError(w, r, err, CodeGroupNotFound, "Group not found")
+		return
+	}
+
+	events, err := c.EventRepo.GetByGroupID(r.Context(), groupID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get events", "error", err)
+		writeError(w, r, err, CodeEventNotFound, "Failed to get events")
+		return
+	}
+
+	sent := send(r.Context(), group, services.ComputeBalances(events))
+
+	// Return the number of emails sent
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"sent": sent})
+}
@@ -0,0 +1,397 @@
+package controllers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/RealZimboGuy/budgetApp/internal/buildinfo"
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/openapi"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// docsPage renders the OpenAPI document with Redoc
+//
+//go:embed openapi_docs.html
+var docsPage []byte
+
+// messageResponse is the body written by handlers that only confirm an action
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// emailResponse is the body of email registration responses, the only ones that
+// carry a user's email address
+type emailResponse struct {
+	Message string `json:"message"`
+	UserID  string `json:"user_id"`
+	Email   string `json:"email,omitempty"`
+}
+
+// sentResponse is the body of notification responses
+type sentResponse struct {
+	Sent int `json:"sent"`
+}
+
+// healthResponse is the body of liveness responses
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+// paramDoc documents a query parameter
+type paramDoc struct {
+	name string
+	// description defaults to the one in paramDescriptions
+	description string
+	required    bool
+}
+
+// routeDoc documents the operation served by a route
+type routeDoc struct {
+	// method is used for the original routes, whose patterns don't name one
+	method  string
+	summary string
+	tag     string
+	// query lists the query parameters, path parameters come from the route pattern
+	query []paramDoc
+	// headers lists the request headers the route reads
+	headers []paramDoc
+	// body and response are values of the request and response body types, nil for none
+	body     any
+	response any
+	// conflict is a value of the body of 409 responses, when it extends Problem
+	conflict any
+	// contentType is the media type of the response, JSON when empty
+	contentType string
+}
+
+// legacy documents an original route. They accept any method and take IDs from the
+// query string, method is the one clients use.
+func legacy(method string, doc routeDoc, query ...paramDoc) routeDoc {
+	doc.method = method
+	doc.query = append(query, doc.query...)
+	return doc
+}
+
+// required documents a required query parameter
+func required(name string) paramDoc {
+	return paramDoc{name: name, required: true}
+}
+
+// paramDescriptions describes parameters by name. The id parameter is described
+// from the tag of the route.
+var paramDescriptions = map[string]string{
+	"group_id":    "ID of the group",
+	"user_id":     "ID of the user",
+	"delivery_id": "ID of the webhook delivery",
+	"event_id":    "ID of the EXPENSE_CREATED event of the expense",
+	"after_id":    "Only return events created after this event, omit to start from the first event",
+	"token":       "Unsubscribe token from the email link",
+	"status":      "Only return active or deleted expenses, omit for both",
+
+	IdempotencyKeyHeader: "Unique key making the request safe to retry, the first response is replayed for 24 hours by default",
+}
+
+// idempotencyKey is the header of the creation routes without a client-chosen ID
+var idempotencyKey = paramDoc{name: IdempotencyKeyHeader}
+
+// requester is the user_id query parameter of the webhook routes
+var requester = paramDoc{name: "user_id", description: "ID of the user making the request, who must own the group", required: true}
+
+var (
+	createUserDoc    = routeDoc{summary: "Create a user, an existing user with the same client-chosen ID, name and email is returned as is", tag: "users", headers: []paramDoc{idempotencyKey}, body: createUserRequest{}, response: domain.User{}}
+	getUserDoc       = routeDoc{summary: "Get a user", tag: "users", response: domain.User{}}
+	updateUserDoc    = routeDoc{summary: "Rename a user", tag: "users", body: updateUserRequest{}, response: domain.User{}}
+	deleteUserDoc    = routeDoc{summary: "Delete a user", tag: "users", response: messageResponse{}}
+	firebaseTokenDoc = routeDoc{summary: "Register a Firebase token for push notifications, an empty token removes it", tag: "users", body: firebaseTokenRequest{}, response: messageResponse{}}
+	emailDoc         = routeDoc{summary: "Set the email address of a user, an empty address removes it", tag: "users", body: emailRequest{}, response: emailResponse{}}
+	unsubscribeDoc   = routeDoc{summary: "Show the confirmation form of the unsubscribe link in notification emails. POST to the same URL unsubscribes.", tag: "users", query: []paramDoc{required("token")}, response: "", contentType: "text/html"}
+	groupsByUserDoc  = routeDoc{summary: "List the groups of a user", tag: "groups", response: []*domain.Group{}}
+
+	createGroupDoc = routeDoc{summary: "Create a group, an existing group with the same client-chosen ID is returned as is", tag: "groups", headers: []paramDoc{idempotencyKey}, body: createGroupRequest{}, response: domain.Group{}}
+	getGroupDoc    = routeDoc{summary: "Get a group", tag: "groups", response: domain.Group{}}
+	updateGroupDoc = routeDoc{summary: "Rename a group", tag: "groups", body: updateGroupRequest{}, response: domain.Group{}}
+	deleteGroupDoc = routeDoc{summary: "Delete a group", tag: "groups", response: messageResponse{}}
+
+	createEventDoc   = routeDoc{summary: "Record an event, an existing event with the same ID is returned as is. An event whose expected group version or chain head is stale is refused with the current state.", tag: "events", body: createEventRequest{}, response: domain.Event{}, conflict: PreconditionProblem{}}
+	getEventDoc      = routeDoc{summary: "Get an event", tag: "events", response: domain.Event{}}
+	eventsByGroupDoc = routeDoc{summary: "List a page of the events of a group in the order they were created", tag: "events", query: []paramDoc{{name: "after_id"}}, response: []*domain.Event{}}
+	deleteEventDoc   = routeDoc{summary: "Delete an event, which bumps the version of its group", tag: "events", response: messageResponse{}}
+
+	expensesByGroupDoc = routeDoc{summary: "List the expenses of a group as last edited, in the order they were recorded in", tag: "expenses", query: []paramDoc{{name: "status"}}, response: []*domain.Expense{}}
+	expenseHistoryDoc  = routeDoc{summary: "List the revisions of an expense in HLC order, with the fields each edit changed", tag: "expenses", response: domain.ExpenseHistory{}}
+
+	settlementDoc = routeDoc{summary: "Email every member of the group how to settle up", tag: "notifications", response: sentResponse{}}
+	remindersDoc  = routeDoc{summary: "Email a reminder to every member of the group who owes money", tag: "notifications", response: sentResponse{}}
+
+	createWebhookDoc     = routeDoc{summary: "Register a webhook for the events of a group, the response holds the signing secret", tag: "webhooks", body: createWebhookRequest{}, response: webhookWithSecret{}}
+	webhooksByGroupDoc   = routeDoc{summary: "List the webhooks of a group", tag: "webhooks", query: []paramDoc{requester}, response: []*domain.Webhook{}}
+	deleteWebhookDoc     = routeDoc{summary: "Delete a webhook", tag: "webhooks", query: []paramDoc{requester}, response: messageResponse{}}
+	rotateSecretDoc      = routeDoc{summary: "Replace the signing secret of a webhook, the previous one stays valid for a grace period", tag: "webhooks", query: []paramDoc{requester}, response: webhookWithSecret{}}
+	setWebhookActiveDoc  = routeDoc{summary: "Turn deliveries to a webhook on or off, webhooks whose recent deliveries all failed are turned off", tag: "webhooks", query: []paramDoc{requester}, body: setWebhookActiveRequest{}, response: domain.Webhook{}}
+	webhookDeliveriesDoc = routeDoc{summary: "List the recent deliveries of a webhook", tag: "webhooks", query: []paramDoc{requester}, response: []*domain.WebhookDelivery{}}
+	replayDeliveryDoc    = routeDoc{summary: "Deliver the payload of a previous delivery again", tag: "webhooks", query: []paramDoc{requester}, response: domain.WebhookDelivery{}}
+)
+
+// routeDocs documents every route registered by SetupRoutes, keyed by pattern
+var routeDocs = map[string]routeDoc{
+	"/healthz":      {method: http.MethodGet, summary: "Liveness probe", tag: "operations", response: healthResponse{}},
+	"/readyz":       {method: http.MethodGet, summary: "Readiness probe, responds 503 when the instance can't serve traffic", tag: "operations", response: readinessResponse{}},
+	"/version":      {method: http.MethodGet, summary: "Build and database schema version", tag: "operations", response: versionResponse{}},
+	"/metrics":      {method: http.MethodGet, summary: "Prometheus metrics", tag: "operations", response: "", contentType: "text/plain"},
+	"/openapi.json": {method: http.MethodGet, summary: "This OpenAPI document", tag: "operations"},
+	"/docs":         {method: http.MethodGet, summary: "API documentation page", tag: "operations", response: "", contentType: "text/html"},
+
+	"/api/users/create":      legacy(http.MethodPost, createUserDoc),
+	"/api/users/get":         legacy(http.MethodGet, getUserDoc, required("id")),
+	"/api/users/firebase":    legacy(http.MethodPost, firebaseTokenDoc, required("id")),
+	"/api/users/email":       legacy(http.MethodPost, emailDoc, required("id")),
+	"/api/users/unsubscribe": legacy(http.MethodGet, unsubscribeDoc),
+	"/api/groups/create":     legacy(http.MethodPost, createGroupDoc),
+	"/api/groups/get":        legacy(http.MethodGet, getGroupDoc, required("id")),
+	"/api/groups/by-user":    legacy(http.MethodGet, groupsByUserDoc, required("user_id")),
+	"/api/events/create":     legacy(http.MethodPost, createEventDoc),
+	"/api/events/get":        legacy(http.MethodGet, getEventDoc, required("id")),
+	"/api/events/by-group":   legacy(http.MethodGet, eventsByGroupDoc, required("group_id")),
+	"/api/expenses/by-group": legacy(http.MethodGet, expensesByGroupDoc, required("group_id")),
+	"/api/expenses/history":  legacy(http.MethodGet, expenseHistoryDoc, required("event_id")),
+
+	"/api/notifications/settlement": legacy(http.MethodPost, settlementDoc, required("group_id")),
+	"/api/notifications/reminders":  legacy(http.MethodPost, remindersDoc, required("group_id")),
+
+	"/api/webhooks/create":        legacy(http.MethodPost, createWebhookDoc),
+	"/api/webhooks/by-group":      legacy(http.MethodGet, webhooksByGroupDoc, required("group_id")),
+	"/api/webhooks/delete":        legacy(http.MethodPost, deleteWebhookDoc, required("id")),
+	"/api/webhooks/rotate-secret": legacy(http.MethodPost, rotateSecretDoc, required("id")),
+	"/api/webhooks/set-active":    legacy(http.MethodPost, setWebhookActiveDoc, required("id")),
+	"/api/webhooks/deliveries":    legacy(http.MethodGet, webhookDeliveriesDoc, required("id")),
+	"/api/webhooks/replay":        legacy(http.MethodPost, replayDeliveryDoc, required("delivery_id")),
+
+	"POST /api/v2/users":                      createUserDoc,
+	"GET /api/v2/users/{id}":                  getUserDoc,
+	"PUT /api/v2/users/{id}":                  updateUserDoc,
+	"DELETE /api/v2/users/{id}":               deleteUserDoc,
+	"POST /api/v2/users/{id}/firebase-token":  firebaseTokenDoc,
+	"POST /api/v2/users/{id}/email":           emailDoc,
+	"GET /api/v2/users/{user_id}/groups":      groupsByUserDoc,
+	"POST /api/v2/groups":                     createGroupDoc,
+	"GET /api/v2/groups/{id}":                 getGroupDoc,
+	"PUT /api/v2/groups/{id}":                 updateGroupDoc,
+	"DELETE /api/v2/groups/{id}":              deleteGroupDoc,
+	"GET /api/v2/groups/{group_id}/events":    eventsByGroupDoc,
+	"POST /api/v2/groups/{group_id}/events":   createEventDoc,
+	"GET /api/v2/events/{id}":                 getEventDoc,
+	"DELETE /api/v2/events/{id}":              deleteEventDoc,
+	"GET /api/v2/groups/{group_id}/expenses":  expensesByGroupDoc,
+	"GET /api/v2/expenses/{event_id}/history": expenseHistoryDoc,
+
+	"POST /api/v2/groups/{group_id}/notifications/settlement": settlementDoc,
+	"POST /api/v2/groups/{group_id}/notifications/reminders":  remindersDoc,
+
+	"POST /api/v2/groups/{group_id}/webhooks":              createWebhookDoc,
+	"GET /api/v2/groups/{group_id}/webhooks":               webhooksByGroupDoc,
+	"DELETE /api/v2/webhooks/{id}":                         deleteWebhookDoc,
+	"POST /api/v2/webhooks/{id}/rotate-secret":             rotateSecretDoc,
+	"PUT /api/v2/webhooks/{id}/active":                     setWebhookActiveDoc,
+	"GET /api/v2/webhooks/{id}/deliveries":                 webhookDeliveriesDoc,
+	"POST /api/v2/webhook-deliveries/{delivery_id}/replay": replayDeliveryDoc,
+}
+
+var tags = []openapi.Tag{
+	{Name: "users", Description: "Users and how they are notified"},
+	{Name: "groups", Description: "Groups of users sharing expenses"},
+	{Name: "events", Description: "The event log of a group, from which clients build its state"},
+	{Name: "expenses", Description: "Expenses as built from the EXPENSE_* events of a group"},
+	{Name: "notifications", Description: "Emails sent on request"},
+	{Name: "webhooks", Description: "Webhooks receiving the events of a group, managed by the group owner"},
+	{Name: "operations", Description: "Probes, metrics and documentation"},
+}
+
+// pathParamPattern matches the wildcards of a route pattern
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// OpenAPI returns the OpenAPI document of the routes registered by SetupRoutes.
+// Routes missing from routeDocs are left out.
+func (r *Router) OpenAPI() *openapi.Document {
+	gen := openapi.NewGenerator()
+	problem := gen.Schema(Problem{})
+	defineEventSchemas(gen)
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Simple Split API",
+			Description: "Shares expenses in groups. Errors are RFC 7807 problem details.",
+			Version:     buildinfo.Get().Commit,
+		},
+		Tags:  tags,
+		Paths: make(map[string]*openapi.PathItem),
+	}
+	if r.config.Server.PublicBaseURL != "" {
+		doc.Servers = []openapi.Server{{URL: r.config.Server.PublicBaseURL}}
+	}
+
+	for _, pattern := range r.routes {
+		route, ok := routeDocs[pattern]
+		if !ok {
+			continue
+		}
+
+		method, path, hasMethod := strings.Cut(pattern, " ")
+		if !hasMethod {
+			method, path = route.method, pattern
+		}
+
+		operation := &openapi.Operation{
+			OperationID: operationID(method, path),
+			Summary:     route.summary,
+			Tags:        []string{route.tag},
+			Responses:   make(map[string]*openapi.Response),
+		}
+
+		for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+			operation.Parameters = append(operation.Parameters, route.parameter(paramDoc{name: match[1], required: true}, "path"))
+		}
+		for _, query := range route.query {
+			operation.Parameters = append(operation.Parameters, route.parameter(query, "query"))
+		}
+		for _, header := range route.headers {
+			operation.Parameters = append(operation.Parameters, route.parameter(header, "header"))
+		}
+
+		if route.body != nil {
+			operation.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(gen.Schema(route.body))}
+		}
+
+		contentType := route.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		operation.Responses["200"] = &openapi.Response{
+			Description: "OK",
+			Content:     map[string]openapi.MediaType{contentType: {Schema: gen.Schema(route.response)}},
+		}
+		if route.conflict != nil {
+			operation.Responses["409"] = &openapi.Response{
+				Description: "Conflict",
+				Content:     map[string]openapi.MediaType{problemContentType: {Schema: gen.Schema(route.conflict)}},
+			}
+		}
+		if strings.HasPrefix(path, "/api/") {
+			operation.Responses["default"] = &openapi.Response{
+				Description: "Error",
+				Content:     map[string]openapi.MediaType{problemContentType: {Schema: problem}},
+			}
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(method)] = operation
+	}
+
+	doc.Components = gen.Components()
+	return doc
+}
+
+// parameter documents a parameter of the route
+func (route routeDoc) parameter(param paramDoc, in string) openapi.Parameter {
+	description := param.description
+	if description == "" {
+		description = paramDescriptions[param.name]
+	}
+	if param.name == "id" {
+		description = "ID of the " + strings.TrimSuffix(route.tag, "s")
+	}
+	return openapi.Parameter{
+		Name:        param.name,
+		In:          in,
+		Description: description,
+		Required:    param.required,
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+// operationID derives a unique operation ID from the method and path
+func operationID(method string, path string) string {
+	words := strings.FieldsFunc(path, func(c rune) bool {
+		return !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9')
+	})
+	return strings.ToLower(method) + "_" + strings.Join(words, "_")
+}
+
+// defineEventSchemas documents the payload of each event type. The payloads are
+// stored as raw JSON, so the generated schemas of events only say "any value".
+func defineEventSchemas(gen *openapi.Generator) {
+	eventTypes := make([]string, 0, len(events.Payloads))
+	for eventType := range events.Payloads {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+	slices.Sort(eventTypes)
+
+	payload := &openapi.Schema{}
+	description := []string{"The shape of the payload depends on the event type:"}
+	seen := make(map[string]bool)
+	for _, eventType := range eventTypes {
+		value := events.Payloads[util.EventType(eventType)]
+		if value == nil {
+			description = append(description, fmt.Sprintf("- %s: any JSON object", eventType))
+			payload.AnyOf = append(payload.AnyOf, &openapi.Schema{Type: "object"})
+			continue
+		}
+
+		ref := gen.Schema(value)
+		name := strings.TrimPrefix(ref.Ref, "#/components/schemas/")
+		description = append(description, fmt.Sprintf("- %s: %s", eventType, name))
+		if !seen[name] {
+			seen[name] = true
+			payload.AnyOf = append(payload.AnyOf, ref)
+		}
+	}
+	payload.Description = strings.Join(description, "\n")
+	payloadRef := gen.Define("EventPayload", payload)
+
+	// GROUP_UPDATE events are not emitted yet, their payload is documented for clients
+	gen.Schema(events.GroupUpdate{})
+
+	eventType := &openapi.Schema{Type: "string", Enum: eventTypes}
+	for _, value := range []any{domain.Event{}, createEventRequest{}} {
+		ref := gen.Schema(value)
+		schema := gen.Component(strings.TrimPrefix(ref.Ref, "#/components/schemas/"))
+		schema.Properties["payload"] = payloadRef
+		schema.Properties["event_type"] = eventType
+	}
+}
+
+// ServeOpenAPI writes the OpenAPI document of the API
+func (r *Router) ServeOpenAPI(w http.ResponseWriter, req *http.Request) {
+	spec, err := r.openAPISpec()
+	if err != nil {
+		slog.ErrorContext(req.Context(), "Failed to encode OpenAPI document", "error", err)
+		writeProblem(w, req, http.StatusInternalServerError, CodeInternal, "Failed to encode OpenAPI document")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// ServeDocs writes a page rendering the OpenAPI document
+func (r *Router) ServeDocs(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// encodeOpenAPI encodes the OpenAPI document once all routes are registered
+func (r *Router) encodeOpenAPI() ([]byte, error) {
+	return json.MarshalIndent(r.OpenAPI(), "", "  ")
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/repository/memory"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// newDocumentedRouter sets up every route, including the optional ones
+func newDocumentedRouter(t *testing.T) (*Router, http.Handler) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Features.Webhooks = true
+
+	router := NewRouter(memory.New().Stores(), nil, cfg, util.NewBackground())
+	return router, router.SetupRoutes()
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	router, _ := newDocumentedRouter(t)
+	doc := router.OpenAPI()
+
+	for _, pattern := range router.Routes() {
+		method, path, hasMethod := strings.Cut(pattern, " ")
+		if !hasMethod {
+			method, path = routeDocs[pattern].method, pattern
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok || (*item)[strings.ToLower(method)] == nil {
+			t.Errorf("route %q is not in the OpenAPI document, add it to routeDocs", pattern)
+		}
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	_, handler := newDocumentedRouter(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", rec.Code)
+	}
+
+	var doc struct {
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+
+	refs := regexp.MustCompile(`"\$ref":\s*"#/components/schemas/(\w+)"`).FindAllStringSubmatch(rec.Body.String(), -1)
+	for _, ref := range refs {
+		if _, ok := doc.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("schema %s is referenced but not defined", ref[1])
+		}
+	}
+
+	payload := string(doc.Components.Schemas["EventPayload"])
+	for eventType := range events.Payloads {
+		if !strings.Contains(payload, string(eventType)) {
+			t.Errorf("payload of %s is not documented", eventType)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// param returns a request parameter. The v2 routes carry IDs as path wildcards,
+// the original routes in the query string, and both use the same names.
+func param(r *http.Request, name string) string {
+	if value := r.PathValue(name); value != "" {
+		return value
+	}
+	return r.URL.Query().Get(name)
+}
+
+// bodyParam reconciles an ID sent in the request body with the same ID in the path
+// of a v2 route. It returns false when both are present but differ.
+func bodyParam(r *http.Request, name string, bodyValue string) (string, bool) {
+	pathValue := r.PathValue(name)
+	switch {
+	case pathValue == "":
+		return bodyValue, true
+	case bodyValue == "" || bodyValue == pathValue:
+		return pathValue, true
+	default:
+		return "", false
+	}
+}
+
+// clientID normalises an ID chosen by a client for a new resource. It must be a
+// random (version 4) or time-ordered (version 7) UUID, so it won't collide with
+// IDs chosen by other clients.
+func clientID(id string) (string, bool) {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.Variant() != uuid.RFC4122 || (parsed.Version() != 4 && parsed.Version() != 7) {
+		return "", false
+	}
+	return parsed.String(), true
+}
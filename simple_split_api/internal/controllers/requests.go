@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+)
+
+// createEventRequest is the body of event creation requests
+type createEventRequest struct {
+	EventID       string          `json:"event_id"`
+	LinkedEventID string          `json:"linked_event_id"`
+	GroupID       string          `json:"group_id"`
+	UserID        string          `json:"user_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	// HLC is optional, the server stamps events sent without one
+	HLC hlc.HLC `json:"hlc"`
+	// ExpectedGroupVersion, when set, refuses the event unless the group is still
+	// at that version
+	ExpectedGroupVersion *int64 `json:"expected_group_version,omitempty"`
+	// ExpectedHeadID, when set, refuses the event unless it is still the latest
+	// expense event of the group linked to LinkedEventID, or LinkedEventID itself
+	// if nothing links to it
+	ExpectedHeadID string `json:"expected_head_id,omitempty"`
+}
+
+// createGroupRequest is the body of group creation requests
+type createGroupRequest struct {
+	// GroupID is optional, clients creating groups offline choose it themselves
+	GroupID string `json:"group_id"`
+	Name    string `json:"name"`
+}
+
+// updateGroupRequest is the body of group update requests
+type updateGroupRequest struct {
+	Name string `json:"name"`
+}
+
+// createUserRequest is the body of user creation requests
+type createUserRequest struct {
+	// UserID is optional, clients creating users offline choose it themselves
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+// updateUserRequest is the body of user update requests
+type updateUserRequest struct {
+	Name string `json:"name"`
+}
+
+// firebaseTokenRequest is the body of Firebase token registration requests
+type firebaseTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// emailRequest is the body of email registration requests
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+// createWebhookRequest is the body of webhook registration requests
+type createWebhookRequest struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+	URL     string `json:"url"`
+}
+
+// setWebhookActiveRequest is the body of requests turning a webhook on or off
+type setWebhookActiveRequest struct {
+	Active *bool `json:"active"`
+}
@@ -1,55 +1,117 @@
 package controllers
 
 import (
+	"log"
 	"log/slog"
 	"net/http"
-	"os"
 	"runtime/debug"
+	"slices"
+	"sync"
 
 	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/metrics"
+	"github.com/RealZimboGuy/budgetApp/internal/migrations"
 	"github.com/RealZimboGuy/budgetApp/internal/repository"
 	"github.com/RealZimboGuy/budgetApp/internal/services"
 	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
 )
 
 // Router handles HTTP routing for the application
 type Router struct {
-	UserController  *UserController
-	GroupController *GroupController
-	EventController *EventController
-	mux             *http.ServeMux
+	UserController         *UserController
+	GroupController        *GroupController
+	EventController        *EventController
+	ExpenseController      *ExpenseController
+	WebhookController      *WebhookController
+	NotificationController *NotificationController
+	HealthController       *HealthController
+	config                 *config.Config
+	middleware             []Middleware
+	// idempotency wraps the creation routes that have no client-chosen ID
+	idempotency Middleware
+	routes      []string
+	mux         *http.ServeMux
+	// openAPISpec returns the encoded OpenAPI document, built on first use
+	openAPISpec func() ([]byte, error)
 }
 
-// NewRouter creates a new router with all controllers
-func NewRouter(db *util.Database) *Router {
-	// Create repositories
-	userRepo := repository.NewUserRepository(db)
-	groupRepo := repository.NewGroupRepository(db)
-	eventRepo := repository.NewEventRepository(db)
+// NewRouter creates a new router with all controllers. db is nil when the stores
+// are not backed by a database, which disables the database health checks.
+func NewRouter(stores repository.Stores, db *util.Database, cfg *config.Config, background *util.Background) *Router {
+	userRepo, groupRepo, eventRepo, webhookRepo := stores.Users, stores.Groups, stores.Events, stores.Webhooks
 
-	// Get Firebase API key from environment or use a default for development
-	firebaseUrl := os.Getenv("FIREBASE_URL")
+	var migrator *migrations.Migrator
+	if db != nil {
+		metrics.RegisterDB(db.DB)
 
-	// Create Firebase service if API key is provided
+		var err error
+		migrator, err = migrations.NewMigrator(db.DB, migrations.Dialect(cfg.DatabaseBackend()))
+		if err != nil {
+			// The migrations are embedded in the binary, so this only fails on a broken build
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+	}
+
+	// Create Firebase service if push notifications are configured
 	var firebaseService *services.FirebaseService
-	if firebaseUrl != "" {
+	if cfg.PushEnabled() {
 		slog.Info("Firebase URL key found, initializing Firebase service")
-		firebaseService = services.NewFirebaseService(userRepo, firebaseUrl)
+		firebaseService = services.NewFirebaseService(userRepo, cfg.Firebase)
 	} else {
 		slog.Warn("No Firebase URL key found, notifications will not be sent")
 	}
 
+	// Create email service if an SMTP host is configured
+	var emailService *services.EmailService
+	if cfg.EmailEnabled() {
+		slog.Info("SMTP host found, initializing email service", "host", cfg.SMTP.Host, "port", cfg.SMTP.Port)
+		emailService = services.NewEmailService(userRepo, groupRepo, cfg.SMTP, cfg.Server.PublicBaseURL)
+	} else {
+		slog.Warn("No SMTP host found, emails will not be sent")
+	}
+
+	var webhookService *services.WebhookService
+	if cfg.Features.Webhooks {
+		webhookService = services.NewWebhookService(webhookRepo, cfg.Webhooks)
+	} else {
+		slog.Warn("Webhooks are disabled")
+	}
+
 	// Create controllers
 	userController := NewUserController(userRepo)
 	groupController := NewGroupController(groupRepo)
-	eventController := NewEventController(eventRepo, userRepo, groupRepo, firebaseService)
+	eventController := NewEventController(stores.Tx, eventRepo, userRepo, groupRepo, firebaseService, webhookService, emailService, cfg.Limits.EventsPageSize, hlc.NewClock(cfg.Limits.MaxClockDrift.Std()), background)
+	expenseController := NewExpenseController(eventRepo)
+	webhookController := NewWebhookController(webhookRepo, eventRepo, groupRepo, webhookService, background)
+	notificationController := NewNotificationController(eventRepo, groupRepo, emailService)
 
-	return &Router{
-		UserController:  userController,
-		GroupController: groupController,
-		EventController: eventController,
-		mux:             http.NewServeMux(),
+	healthController := NewHealthController(db, migrator, cfg)
+
+	router := &Router{
+		UserController:         userController,
+		GroupController:        groupController,
+		EventController:        eventController,
+		ExpenseController:      expenseController,
+		WebhookController:      webhookController,
+		NotificationController: notificationController,
+		HealthController:       healthController,
+		config:                 cfg,
+		// Wraps every API route, outermost first
+		middleware: []Middleware{
+			RequestIDMiddleware,
+			TracingMiddleware,
+			MetricsMiddleware,
+			config.NewRequestLogger(cfg.Logging).Middleware,
+			MaxBodyMiddleware(cfg.Limits.MaxRequestBodyBytes),
+			PanicRecoveryMiddleware,
+		},
+		idempotency: IdempotencyMiddleware(stores.Idempotency, cfg.Limits.IdempotencyKeyTTL.Std(), cfg.Limits.IdempotencyLease.Std()),
+		mux:         http.NewServeMux(),
 	}
+	router.openAPISpec = sync.OnceValues(router.encodeOpenAPI)
+
+	return router
 }
 
 type Middleware func(http.Handler) http.Handler
@@ -64,24 +126,136 @@ func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
 
 // SetupRoutes configures all routes
 func (r *Router) SetupRoutes() http.Handler {
+	// Probe routes skip the logging middleware so load balancer checks don't flood the logs
+	r.register("/healthz", Chain(http.HandlerFunc(r.HealthController.Healthz), PanicRecoveryMiddleware))
+	r.register("/readyz", Chain(http.HandlerFunc(r.HealthController.Readyz), PanicRecoveryMiddleware))
+	r.register("/version", Chain(http.HandlerFunc(r.HealthController.Version), PanicRecoveryMiddleware))
+	r.register("/metrics", Chain(metrics.Handler(), PanicRecoveryMiddleware))
+
+	// API documentation
+	r.register("/openapi.json", Chain(http.HandlerFunc(r.ServeOpenAPI), PanicRecoveryMiddleware))
+	r.register("/docs", Chain(http.HandlerFunc(r.ServeDocs), PanicRecoveryMiddleware))
+
+	// Original routes, they accept any method and take IDs from the query string.
+	// The mobile app uses these, so they stay as aliases of the v2 routes below.
+
+	// User routes
+	r.handleIdempotent("/api/users/create", r.UserController.CreateUser)
+	r.handle("/api/users/get", r.UserController.GetUser)
+	r.handle("/api/users/firebase", r.UserController.RegisterFirebaseToken)
+	r.handle("/api/users/email", r.UserController.RegisterEmail)
+	r.handle("/api/users/unsubscribe", r.UserController.Unsubscribe)
+	// Group routes
+	r.handleIdempotent("/api/groups/create", r.GroupController.CreateGroup)
+	r.handle("/api/groups/get", r.GroupController.GetGroup)
+	r.handle("/api/groups/by-user", r.GroupController.GetGroupsByUser)
+
+	// Event routes
+	r.handle("/api/events/create", r.EventController.CreateEvent)
+	r.handle("/api/events/get", r.EventController.GetEvent)
+	r.handle("/api/events/by-group", r.EventController.GetEventsByGroup)
+
+	// Expense routes
+	r.handle("/api/expenses/by-group", r.ExpenseController.GetExpensesByGroup)
+	r.handle("/api/expenses/history", r.ExpenseController.GetExpenseHistory)
+
+	// Notification routes
+	r.handle("/api/notifications/settlement", r.NotificationController.SendSettlementPlan)
+	r.handle("/api/notifications/reminders", r.NotificationController.SendReminders)
+
+	// Webhook routes
+	if r.config.Features.Webhooks {
+		r.handle("/api/webhooks/create", r.WebhookController.CreateWebhook)
+		r.handle("/api/webhooks/by-group", r.WebhookController.GetWebhooksByGroup)
+		r.handle("/api/webhooks/delete", r.WebhookController.DeleteWebhook)
+		r.handle("/api/webhooks/rotate-secret", r.WebhookController.RotateWebhookSecret)
+		r.handle("/api/webhooks/set-active", r.WebhookController.SetWebhookActive)
+		r.handle("/api/webhooks/deliveries", r.WebhookController.GetWebhookDeliveries)
+		r.handle("/api/webhooks/replay", r.WebhookController.ReplayWebhookDelivery)
+	}
+
+	// v2 routes match on method and path. Path wildcards are named like the query
+	// parameters of the original routes, so both are served by the same handlers.
+
 	// User routes
-	// fix: use Handle because Chain returns http.Handler
-	r.mux.Handle("/api/users/create", Chain(http.HandlerFunc(r.UserController.CreateUser), config.LoggingMiddleware, PanicRecoveryMiddleware))
-	r.mux.Handle("/api/users/get", Chain(http.HandlerFunc(r.UserController.GetUser), config.LoggingMiddleware, PanicRecoveryMiddleware))
-	r.mux.Handle("/api/users/firebase", Chain(http.HandlerFunc(r.UserController.RegisterFirebaseToken), config.LoggingMiddleware, PanicRecoveryMiddleware))
+	r.handleIdempotent("POST /api/v2/users", r.UserController.CreateUser)
+	r.handle("GET /api/v2/users/{id}", r.UserController.GetUser)
+	r.handle("PUT /api/v2/users/{id}", r.UserController.UpdateUser)
+	r.handle("DELETE /api/v2/users/{id}", r.UserController.DeleteUser)
+	r.handle("POST /api/v2/users/{id}/firebase-token", r.UserController.RegisterFirebaseToken)
+	r.handle("POST /api/v2/users/{id}/email", r.UserController.RegisterEmail)
+	r.handle("GET /api/v2/users/{user_id}/groups", r.GroupController.GetGroupsByUser)
+
 	// Group routes
-	r.mux.Handle("/api/groups/create", Chain(http.HandlerFunc(r.GroupController.CreateGroup), config.LoggingMiddleware, PanicRecoveryMiddleware))
-	r.mux.Handle("/api/groups/get", Chain(http.HandlerFunc(r.GroupController.GetGroup), config.LoggingMiddleware, PanicRecoveryMiddleware))
-	r.mux.Handle("/api/groups/by-user", Chain(http.HandlerFunc(r.GroupController.GetGroupsByUser), config.LoggingMiddleware, PanicRecoveryMiddleware))
+	r.handleIdempotent("POST /api/v2/groups", r.GroupController.CreateGroup)
+	r.handle("GET /api/v2/groups/{id}", r.GroupController.GetGroup)
+	r.handle("PUT /api/v2/groups/{id}", r.GroupController.UpdateGroup)
+	r.handle("DELETE /api/v2/groups/{id}", r.GroupController.DeleteGroup)
 
 	// Event routes
-	r.mux.Handle("/api/events/create", Chain(http.HandlerFunc(r.EventController.CreateEvent), config.LoggingMiddleware, PanicRecoveryMiddleware))
-	r.mux.Handle("/api/events/get", Chain(http.HandlerFunc(r.EventController.GetEvent), config.LoggingMiddleware, PanicRecoveryMiddleware))
-	r.mux.Handle("/api/events/by-group", Chain(http.HandlerFunc(r.EventController.GetEventsByGroup), config.LoggingMiddleware, PanicRecoveryMiddleware))
+	r.handle("GET /api/v2/groups/{group_id}/events", r.EventController.GetEventsByGroup)
+	r.handle("POST /api/v2/groups/{group_id}/events", r.EventController.CreateEvent)
+	r.handle("GET /api/v2/events/{id}", r.EventController.GetEvent)
+	r.handle("DELETE /api/v2/events/{id}", r.EventController.DeleteEvent)
+
+	// Expense routes
+	r.handle("GET /api/v2/groups/{group_id}/expenses", r.ExpenseController.GetExpensesByGroup)
+	r.handle("GET /api/v2/expenses/{event_id}/history", r.ExpenseController.GetExpenseHistory)
+
+	// Notification routes
+	r.handle("POST /api/v2/groups/{group_id}/notifications/settlement", r.NotificationController.SendSettlementPlan)
+	r.handle("POST /api/v2/groups/{group_id}/notifications/reminders", r.NotificationController.SendReminders)
+
+	// Webhook routes
+	if r.config.Features.Webhooks {
+		r.handle("POST /api/v2/groups/{group_id}/webhooks", r.WebhookController.CreateWebhook)
+		r.handle("GET /api/v2/groups/{group_id}/webhooks", r.WebhookController.GetWebhooksByGroup)
+		r.handle("DELETE /api/v2/webhooks/{id}", r.WebhookController.DeleteWebhook)
+		r.handle("POST /api/v2/webhooks/{id}/rotate-secret", r.WebhookController.RotateWebhookSecret)
+		r.handle("PUT /api/v2/webhooks/{id}/active", r.WebhookController.SetWebhookActive)
+		r.handle("GET /api/v2/webhooks/{id}/deliveries", r.WebhookController.GetWebhookDeliveries)
+		r.handle("POST /api/v2/webhook-deliveries/{delivery_id}/replay", r.WebhookController.ReplayWebhookDelivery)
+	}
 
 	return r.mux
 }
 
+// handle registers an API route wrapped in the API middleware
+func (r *Router) handle(pattern string, handler http.HandlerFunc) {
+	r.register(pattern, Chain(handler, r.middleware...))
+}
+
+// handleIdempotent registers an API route that honours the Idempotency-Key header
+func (r *Router) handleIdempotent(pattern string, handler http.HandlerFunc) {
+	r.register(pattern, Chain(handler, append(slices.Clone(r.middleware), r.idempotency)...))
+}
+
+// register adds a route to the mux and records its pattern
+func (r *Router) register(pattern string, handler http.Handler) {
+	r.mux.Handle(pattern, handler)
+	r.routes = append(r.routes, pattern)
+}
+
+// Routes returns the patterns of the routes registered by SetupRoutes
+func (r *Router) Routes() []string {
+	return r.routes
+}
+
+// MaxBodyMiddleware rejects request bodies larger than limit bytes
+func MaxBodyMiddleware(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeProblem(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body too large")
+				return
+			}
+			// Bodies without a declared length fail while being read instead
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // ServeHTTP implements the http.Handler interface
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.mux.ServeHTTP(w, req)
@@ -99,7 +273,7 @@ func PanicRecoveryMiddleware(next http.Handler) http.Handler {
 				// optionally print stack to stderr for diagnostics:
 				debug.PrintStack()
 
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "Internal Server Error")
 			}
 		}()
 
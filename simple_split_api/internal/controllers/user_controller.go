@@ -1,23 +1,29 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"html"
 	"log/slog"
 	"net/http"
+	"net/mail"
+	"net/url"
 
 	"github.com/RealZimboGuy/budgetApp/internal/domain"
 	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/services"
 )
 
 // UserController handles HTTP requests related to users
 type UserController struct {
-	UserRepo *repository.UserRepository
+	UserRepo repository.UserStore
 }
 
 // NewUserController creates a new user controller
-func NewUserController(userRepo *repository.UserRepository) *UserController {
+func NewUserController(userRepo repository.UserStore) *UserController {
 	return &UserController{
 		UserRepo: userRepo,
 	}
@@ -26,28 +32,49 @@ func NewUserController(userRepo *repository.UserRepository) *UserController {
 // CreateUser handles user creation requests
 func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
-	var reqBody struct {
-		Name string `json:"name"`
-	}
+	var reqBody createUserRequest
 
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
 	// Validate request
 	if reqBody.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Name is required")
+		return
+	}
+	if reqBody.Email != "" && !isValidEmail(reqBody.Email) {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Invalid email address")
 		return
 	}
+	if reqBody.UserID != "" {
+		userID, ok := clientID(reqBody.UserID)
+		if !ok {
+			writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "User ID must be a version 4 or 7 UUID")
+			return
+		}
+		reqBody.UserID = userID
+	}
 
 	// Create user
 	user := domain.NewUser(reqBody.Name)
-	err = c.UserRepo.Create(r.Context(), user)
+	user.UserID = reqBody.UserID
+	if reqBody.Email != "" {
+		token, err := services.GenerateUnsubscribeToken()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to generate unsubscribe token", "error", err)
+			writeError(w, r, err, CodeUserNotFound, "Failed to create user")
+			return
+		}
+		user.Email = sql.NullString{String: reqBody.Email, Valid: true}
+		user.UnsubscribeToken = sql.NullString{String: token, Valid: true}
+	}
+	user, err = c.createUser(r.Context(), user)
 	if err != nil {
-		log.Printf("Failed to create user: %v", err)
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to create user", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to create user")
 		return
 	}
 
@@ -57,20 +84,37 @@ func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
+// createUser adds the user. A user stored earlier under the same client-chosen ID
+// is returned instead when it has the same name and email address, so a client
+// syncing users created offline can send them again.
+func (c *UserController) createUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	clientChosen := user.UserID != ""
+	err := c.UserRepo.Create(ctx, user)
+	if !clientChosen || !errors.Is(err, repository.ErrConflict) {
+		return user, err
+	}
+
+	existing, getErr := c.UserRepo.GetByID(ctx, user.UserID)
+	if getErr != nil || existing.Name != user.Name || existing.Email.String != user.Email.String {
+		return nil, err
+	}
+	return existing, nil
+}
+
 // GetUser handles user retrieval requests
 func (c *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from URL
-	userID := r.URL.Query().Get("id")
+	userID := param(r, "id")
 	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
 		return
 	}
 
 	// Get user
 	user, err := c.UserRepo.GetByID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to get user: %v", err)
-		http.Error(w, "Failed to get user", http.StatusNotFound)
+		slog.ErrorContext(r.Context(), "Failed to get user", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to get user")
 		return
 	}
 
@@ -84,8 +128,8 @@ func (c *UserController) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	// Get all users
 	users, err := c.UserRepo.GetAll(r.Context())
 	if err != nil {
-		log.Printf("Failed to get users: %v", err)
-		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get users", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to get users")
 		return
 	}
 
@@ -97,26 +141,24 @@ func (c *UserController) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 // UpdateUser handles user update requests
 func (c *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from URL
-	userID := r.URL.Query().Get("id")
+	userID := param(r, "id")
 	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
 		return
 	}
 
 	// Parse request body
-	var reqBody struct {
-		Name string `json:"name"`
-	}
+	var reqBody updateUserRequest
 
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
 	// Validate request
 	if reqBody.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Name is required")
 		return
 	}
 
@@ -128,8 +170,8 @@ func (c *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	err = c.UserRepo.Update(r.Context(), user)
 	if err != nil {
-		log.Printf("Failed to update user: %v", err)
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to update user", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to update user")
 		return
 	}
 
@@ -141,17 +183,17 @@ func (c *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 // DeleteUser handles user deletion requests
 func (c *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from URL
-	userID := r.URL.Query().Get("id")
+	userID := param(r, "id")
 	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
 		return
 	}
 
 	// Delete user
 	err := c.UserRepo.Delete(r.Context(), userID)
 	if err != nil {
-		log.Printf("Failed to delete user: %v", err)
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to delete user", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to delete user")
 		return
 	}
 
@@ -164,25 +206,23 @@ func (c *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 func (c *UserController) RegisterFirebaseToken(w http.ResponseWriter, r *http.Request) {
 	// Ensure this endpoint only accepts POST requests
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	// Get user ID from URL path
-	userID := r.URL.Query().Get("id")
+	userID := param(r, "id")
 	if userID == "" {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
 		return
 	}
 
 	// Parse request body
-	var reqBody struct {
-		Token string `json:"token"`
-	}
+	var reqBody firebaseTokenRequest
 
 	err := json.NewDecoder(r.Body).Decode(&reqBody)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
@@ -190,15 +230,15 @@ func (c *UserController) RegisterFirebaseToken(w http.ResponseWriter, r *http.Re
 	_, err = c.UserRepo.GetByID(r.Context(), userID)
 	if err != nil {
 		slog.ErrorContext(r.Context(), fmt.Sprintf("User not found: %s", userID))
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeError(w, r, err, CodeUserNotFound, "User not found")
 		return
 	}
 
 	// Update user's Firebase token (can be empty to unset/remove token)
 	err = c.UserRepo.UpdateFirebaseID(r.Context(), userID, reqBody.Token)
 	if err != nil {
-		log.Printf("Failed to update Firebase token: %v", err)
-		http.Error(w, "Failed to update Firebase token", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to update Firebase token", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to update Firebase token")
 		return
 	}
 
@@ -206,3 +246,101 @@ func (c *UserController) RegisterFirebaseToken(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message":"Firebase token registered successfully"}`))
 }
+
+// RegisterEmail handles setting or removing a user's email address
+func (c *UserController) RegisterEmail(w http.ResponseWriter, r *http.Request) {
+	// Ensure this endpoint only accepts POST requests
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Get user ID from URL
+	userID := param(r, "id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
+		return
+	}
+
+	// Parse request body
+	var reqBody emailRequest
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	// An empty email removes the address
+	if reqBody.Email != "" && !isValidEmail(reqBody.Email) {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Invalid email address")
+		return
+	}
+
+	token, err := services.GenerateUnsubscribeToken()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate unsubscribe token", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "Failed to update email")
+		return
+	}
+
+	err = c.UserRepo.UpdateEmail(r.Context(), userID, reqBody.Email, token)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to update email", "error", err)
+		writeError(w, r, err, CodeUserNotFound, "User not found")
+		return
+	}
+
+	// Return the address, which the user's JSON leaves out
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(emailResponse{
+		Message: "Email registered successfully",
+		UserID:  userID,
+		Email:   reqBody.Email,
+	})
+}
+
+// Unsubscribe handles unsubscribe links from notification emails.
+// GET, used when the link is followed in a browser or fetched by a mail scanner,
+// only shows a confirmation form. POST, sent by that form and by one-click
+// unsubscribe from mail clients, unsubscribes.
+func (c *UserController) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Get token from URL
+	token := param(r, "token")
+	if token == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Token is required")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `<!DOCTYPE html><html><body><form method="post" action="?token=%s"><p>Stop receiving emails from Simple Split?</p><button type="submit">Unsubscribe</button></form></body></html>`, html.EscapeString(url.QueryEscape(token)))
+		return
+	}
+
+	user, err := c.UserRepo.UnsubscribeEmail(r.Context(), token)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to unsubscribe", "error", err)
+		writeError(w, r, err, CodeNotFound, "Invalid unsubscribe link")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "User unsubscribed from email", "user_id", user.UserID)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "<!DOCTYPE html><html><body><p>%s, you will no longer receive emails from Simple Split.</p></body></html>", html.EscapeString(user.Name))
+}
+
+// isValidEmail reports whether the value is a bare email address
+func isValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
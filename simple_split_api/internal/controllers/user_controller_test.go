@@ -0,0 +1,140 @@
+package controllers_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/pkg/client"
+	"github.com/google/uuid"
+)
+
+func TestCreateUserWithClientID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		userID := uuid.Must(uuid.NewV7()).String()
+		body := map[string]string{"user_id": userID, "name": "Ann", "email": "ann@example.com"}
+
+		status, first := h.Do(http.MethodPost, "/api/v2/users", body)
+		if status != http.StatusOK {
+			t.Fatalf("first create: status %d: %s", status, first)
+		}
+		status, second := h.Do(http.MethodPost, "/api/users/create", body)
+		if status != http.StatusOK || string(second) != string(first) {
+			t.Errorf("resent create: status %d: %s, want the stored user %s", status, second, first)
+		}
+
+		body["email"] = "other@example.com"
+		if status, resp := h.Do(http.MethodPost, "/api/v2/users", body); status != http.StatusConflict {
+			t.Errorf("ID reused with another email: status %d: %s", status, resp)
+		}
+
+		// Server-chosen IDs are still generated when none is sent
+		other, err := h.Client.CreateUser(context.Background(), "Bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other.UserID == "" || other.UserID == userID {
+			t.Errorf("created user %q without an ID", other.UserID)
+		}
+		if _, err := h.Client.CreateUserWithID(context.Background(), userID, "Bob"); !client.IsConflict(err) {
+			t.Errorf("ID reused for another user: got %v, want a conflict", err)
+		}
+	})
+}
+
+func TestEmailIsPrivate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend)
+		ann := h.CreateUser("Ann")
+
+		status, body := h.Do(http.MethodPost, "/api/v2/users/"+ann.UserID+"/email", map[string]string{"email": "ann@example.com"})
+		if status != http.StatusOK || !strings.Contains(string(body), `"email":"ann@example.com"`) {
+			t.Errorf("register email: status %d: %s, want the address", status, body)
+		}
+
+		for _, path := range []string{"/api/v2/users/" + ann.UserID, "/api/users/get?id=" + ann.UserID} {
+			status, body := h.Do(http.MethodGet, path, nil)
+			if status != http.StatusOK || strings.Contains(string(body), "ann@example.com") {
+				t.Errorf("GET %s: status %d: %s, want no address", path, status, body)
+			}
+		}
+	})
+}
+
+func TestEmailUnsubscribe(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		smtp := apitest.NewFakeSMTP(t, false)
+		h := apitest.New(t, backend, apitest.WithSMTP(smtp))
+		ctx := context.Background()
+		ann := h.CreateUser("Ann")
+		if err := h.Client.RegisterEmail(ctx, ann.UserID, "ann@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		group := h.CreateGroup("Trip")
+
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		messages := smtp.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("got %d emails, want 1", len(messages))
+		}
+		if to := messages[0].Header.Get("To"); to != "ann@example.com" {
+			t.Errorf("email sent to %q", to)
+		}
+		link, err := url.Parse(strings.Trim(messages[0].Header.Get("List-Unsubscribe"), "<>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Following the link only shows the confirmation form
+		status, body := h.Do(http.MethodGet, link.RequestURI(), nil)
+		if status != http.StatusOK || !strings.Contains(string(body), `method="post"`) {
+			t.Errorf("GET unsubscribe link: status %d: %s", status, body)
+		}
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		if got := len(smtp.Messages()); got != 2 {
+			t.Fatalf("got %d emails after following the link, want 2", got)
+		}
+
+		status, body = h.Do(http.MethodPost, link.RequestURI(), nil)
+		if status != http.StatusOK {
+			t.Fatalf("POST unsubscribe link: status %d: %s", status, body)
+		}
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		if got := len(smtp.Messages()); got != 2 {
+			t.Errorf("got %d emails after unsubscribing, want 2", got)
+		}
+
+		status, _ = h.Do(http.MethodPost, "/api/users/unsubscribe?token=unknown", nil)
+		if status != http.StatusNotFound {
+			t.Errorf("unknown token: status %d, want 404", status)
+		}
+	})
+}
+
+func TestEmailStalledServer(t *testing.T) {
+	smtp := apitest.NewFakeSMTP(t, true)
+	h := apitest.New(t, config.BackendMemory, apitest.WithSMTP(smtp), func(cfg *config.Config) {
+		cfg.SMTP.Timeout = config.Duration(100 * time.Millisecond)
+	})
+	ann := h.CreateUser("Ann")
+	if err := h.Client.RegisterEmail(context.Background(), ann.UserID, "ann@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	group := h.CreateGroup("Trip")
+
+	start := time.Now()
+	h.CreateExpense(group, ann, ann)
+	h.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("email to a stalled server took %s", elapsed)
+	}
+}
@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// secretRotationGrace is how long the previous secret keeps signing deliveries after a rotation
+const secretRotationGrace = 24 * time.Hour
+
+// WebhookController handles HTTP requests related to group webhooks
+type WebhookController struct {
+	WebhookRepo    repository.WebhookStore
+	EventRepo      repository.EventStore
+	GroupRepo      repository.GroupStore
+	WebhookService *services.WebhookService
+	Background     *util.Background
+}
+
+// NewWebhookController creates a new webhook controller
+func NewWebhookController(
+	webhookRepo repository.WebhookStore,
+	eventRepo repository.EventStore,
+	groupRepo repository.GroupStore,
+	webhookService *services.WebhookService,
+	background *util.Background,
+) *WebhookController {
+	return &WebhookController{
+		WebhookRepo:    webhookRepo,
+		EventRepo:      eventRepo,
+		GroupRepo:      groupRepo,
+		WebhookService: webhookService,
+		Background:     background,
+	}
+}
+
+// webhookWithSecret is returned when the signing secret is created or rotated
+type webhookWithSecret struct {
+	*domain.Webhook
+	Secret string `json:"secret"`
+}
+
+// isGroupOwner reports whether userID is the user of the group's GROUP_CREATED
+// event. It is not authorization: user IDs are not secret, anyone who can read the
+// group's events can pass it. It only keeps members from managing the webhooks of
+// groups they did not create. Groups without a GROUP_CREATED event have no owner.
+func (c *WebhookController) isGroupOwner(ctx context.Context, groupID string, userID string) bool {
+	created, err := c.EventRepo.GetFirstByGroupAndType(ctx, groupID, util.GroupCreate)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get group owner", "error", err)
+		return false
+	}
+	return created.UserID == userID
+}
+
+// CreateWebhook handles webhook registration requests
+func (c *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var reqBody createWebhookRequest
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	// v2 routes name the group in the path
+	groupID, ok := bodyParam(r, "group_id", reqBody.GroupID)
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "Group ID in the body does not match the path")
+		return
+	}
+	reqBody.GroupID = groupID
+
+	// Validate request
+	if reqBody.GroupID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
+		return
+	}
+	if reqBody.UserID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
+		return
+	}
+	target, err := url.Parse(reqBody.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "A valid http or https URL is required")
+		return
+	}
+	if err := c.WebhookService.CheckTarget(r.Context(), target); err != nil {
+		slog.WarnContext(r.Context(), "Refused webhook URL", "url", target.Redacted(), "error", err)
+		detail := "Webhook URL host could not be resolved"
+		if errors.Is(err, services.ErrPrivateWebhookTarget) {
+			detail = "Webhook URL must not point at a loopback, private or link-local address"
+		}
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, detail)
+		return
+	}
+
+	// Check if group exists
+	_, err = c.GroupRepo.GetByID(r.Context(), reqBody.GroupID)
+	if err != nil {
+		writeError(w, r, err, CodeGroupNotFound, "Group not found")
+		return
+	}
+
+	if !c.isGroupOwner(r.Context(), reqBody.GroupID, reqBody.UserID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "Only the group owner can manage webhooks")
+		return
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate webhook secret", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to create webhook")
+		return
+	}
+
+	// Create webhook
+	webhook := domain.NewWebhook(reqBody.GroupID, reqBody.UserID, target.String(), secret)
+	err = c.WebhookRepo.Create(r.Context(), webhook)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create webhook", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to create webhook")
+		return
+	}
+
+	// Return created webhook including its secret
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhookWithSecret{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// GetWebhooksByGroup handles requests to list the webhooks of a group
+func (c *WebhookController) GetWebhooksByGroup(w http.ResponseWriter, r *http.Request) {
+	// Get group and user ID from URL
+	groupID := param(r, "group_id")
+	if groupID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Group ID is required")
+		return
+	}
+	userID := param(r, "user_id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
+		return
+	}
+
+	if !c.isGroupOwner(r.Context(), groupID, userID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "Only the group owner can manage webhooks")
+		return
+	}
+
+	// Get webhooks
+	webhooks, err := c.WebhookRepo.GetByGroupID(r.Context(), groupID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get webhooks", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to get webhooks")
+		return
+	}
+
+	// Return webhooks
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// DeleteWebhook handles webhook deletion requests
+func (c *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := c.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	// Delete webhook
+	err := c.WebhookRepo.Delete(r.Context(), webhook.WebhookID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete webhook", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to delete webhook")
+		return
+	}
+
+	// Return success
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Webhook deleted successfully"}`))
+}
+
+// RotateWebhookSecret handles requests to replace a webhook's signing secret
+func (c *WebhookController) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := c.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to generate webhook secret", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to rotate webhook secret")
+		return
+	}
+
+	// The old secret keeps signing deliveries during the grace period so receivers can switch over
+	err = c.WebhookRepo.RotateSecret(r.Context(), webhook.WebhookID, secret, time.Now().Add(secretRotationGrace))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to rotate webhook secret", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to rotate webhook secret")
+		return
+	}
+	webhook.Secret = secret
+
+	// Return webhook including its new secret
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhookWithSecret{Webhook: webhook, Secret: secret})
+}
+
+// SetWebhookActive handles requests to turn deliveries to a webhook on or off,
+// such as turning a webhook deactivated after repeated failures back on
+func (c *WebhookController) SetWebhookActive(w http.ResponseWriter, r *http.Request) {
+	var reqBody setWebhookActiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if reqBody.Active == nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Active is required")
+		return
+	}
+
+	webhook, ok := c.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.WebhookRepo.SetActive(r.Context(), webhook.WebhookID, *reqBody.Active)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to update webhook", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Failed to update webhook")
+		return
+	}
+	webhook.Active = *reqBody.Active
+
+	// Return the updated webhook
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// GetWebhookDeliveries handles requests for a webhook's delivery log
+func (c *WebhookController) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := c.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	// Get the latest deliveries (limit to 100)
+	deliveries, err := c.WebhookRepo.GetDeliveriesByWebhookID(r.Context(), webhook.WebhookID, 100)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get webhook deliveries", "error", err)
+		writeError(w, r, err, CodeWebhookDeliveryNotFound, "Failed to get webhook deliveries")
+		return
+	}
+
+	// Return deliveries
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReplayWebhookDelivery handles requests to send an earlier delivery again
+func (c *WebhookController) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	// Get delivery and user ID from URL
+	deliveryID := param(r, "delivery_id")
+	if deliveryID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Delivery ID is required")
+		return
+	}
+	userID := param(r, "user_id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
+		return
+	}
+
+	original, err := c.WebhookRepo.GetDeliveryByID(r.Context(), deliveryID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get webhook delivery", "error", err)
+		writeError(w, r, err, CodeWebhookDeliveryNotFound, "Webhook delivery not found")
+		return
+	}
+
+	webhook, err := c.WebhookRepo.GetByID(r.Context(), original.WebhookID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get webhook", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+
+	if !c.isGroupOwner(r.Context(), webhook.GroupID, userID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "Only the group owner can manage webhooks")
+		return
+	}
+
+	delivery, err := c.WebhookService.Replay(r.Context(), original)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to replay webhook delivery", "error", err)
+		writeError(w, r, err, CodeWebhookDeliveryNotFound, "Failed to replay webhook delivery")
+		return
+	}
+
+	// Return the new delivery, before delivering updates it
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+
+	// Deliver in the background to not block the response
+	c.Background.Go(func(ctx context.Context) {
+		c.WebhookService.Deliver(ctx, webhook, delivery)
+	})
+}
+
+// ownedWebhook loads the webhook named by the id query parameter and checks that
+// the user_id query parameter belongs to the group owner. It writes the error
+// response itself and returns false when the request should not continue.
+func (c *WebhookController) ownedWebhook(w http.ResponseWriter, r *http.Request) (*domain.Webhook, bool) {
+	// Get webhook and user ID from URL
+	webhookID := param(r, "id")
+	if webhookID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "Webhook ID is required")
+		return nil, false
+	}
+	userID := param(r, "user_id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeMissingParameter, "User ID is required")
+		return nil, false
+	}
+
+	webhook, err := c.WebhookRepo.GetByID(r.Context(), webhookID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get webhook", "error", err)
+		writeError(w, r, err, CodeWebhookNotFound, "Webhook not found")
+		return nil, false
+	}
+
+	if !c.isGroupOwner(r.Context(), webhook.GroupID, userID) {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "Only the group owner can manage webhooks")
+		return nil, false
+	}
+
+	return webhook, true
+}
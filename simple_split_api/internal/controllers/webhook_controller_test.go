@@ -0,0 +1,266 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/apitest"
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/services"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+func TestWebhookPrivateTargets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		h := apitest.New(t, backend, apitest.WithWebhooks())
+		ann := h.CreateUser("Ann")
+		group := h.CreateGroup("Trip")
+		h.CreateEvent(group, ann, util.GroupCreate, events.GroupCreated{Name: "Trip", DateTime: time.Now()})
+
+		for _, target := range []string{
+			"http://127.0.0.1:8080/hook",
+			"http://localhost/hook",
+			"http://[::1]/hook",
+			"http://10.0.0.1/hook",
+			"http://192.168.1.10/hook",
+			"http://169.254.169.254/latest/meta-data",
+			"http://0.0.0.0/hook",
+		} {
+			status, body := h.Do(http.MethodPost, "/api/v2/groups/"+group.GroupID+"/webhooks", map[string]any{
+				"user_id": ann.UserID,
+				"url":     target,
+			})
+			if status != http.StatusBadRequest {
+				t.Errorf("%s: status %d: %s", target, status, body)
+			}
+		}
+
+		// Webhooks stored with a private target are refused when the delivery connects
+		received := make(chan struct{}, 1)
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- struct{}{}
+		}))
+		t.Cleanup(receiver.Close)
+		webhook := domain.NewWebhook(group.GroupID, ann.UserID, receiver.URL, "secret")
+		if err := h.Stores.Webhooks.Create(context.Background(), webhook); err != nil {
+			t.Fatal(err)
+		}
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		select {
+		case <-received:
+			t.Error("delivery reached a loopback address")
+		default:
+		}
+		deliveries, err := h.Stores.Webhooks.GetDeliveriesByWebhookID(context.Background(), webhook.WebhookID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Status != domain.DeliveryFailed || deliveries[0].Attempts != 1 {
+			t.Errorf("got deliveries %+v, want one failed attempt", deliveries)
+		}
+	})
+}
+
+func TestWebhookDeactivatedAfterFailures(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		t.Cleanup(receiver.Close)
+
+		h := apitest.New(t, backend, apitest.WithWebhooks(), func(cfg *config.Config) {
+			cfg.Webhooks.AllowPrivateTargets = true
+			cfg.Webhooks.DisableAfterFailures = 2
+		})
+		ann := h.CreateUser("Ann")
+		group := h.CreateGroup("Trip")
+		h.CreateEvent(group, ann, util.GroupCreate, events.GroupCreated{Name: "Trip", DateTime: time.Now()})
+
+		status, body := h.Do(http.MethodPost, "/api/v2/groups/"+group.GroupID+"/webhooks", map[string]any{
+			"user_id": ann.UserID,
+			"url":     receiver.URL,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("create webhook: status %d: %s", status, body)
+		}
+		var webhook domain.Webhook
+		json.Unmarshal(body, &webhook)
+
+		active := func() bool {
+			stored, err := h.Stores.Webhooks.GetByID(context.Background(), webhook.WebhookID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return stored.Active
+		}
+
+		// Client errors are not retried, so every event makes one failed delivery
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		if !active() {
+			t.Fatal("webhook deactivated after one failure")
+		}
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		if active() {
+			t.Fatal("webhook still active after two failures")
+		}
+
+		// Deactivated webhooks get no deliveries
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		deliveries, err := h.Stores.Webhooks.GetDeliveriesByWebhookID(context.Background(), webhook.WebhookID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 2 {
+			t.Errorf("got %d deliveries, want 2", len(deliveries))
+		}
+
+		status, body = h.Do(http.MethodPut, "/api/v2/webhooks/"+webhook.WebhookID+"/active?user_id="+ann.UserID, map[string]any{"active": true})
+		if status != http.StatusOK || !active() {
+			t.Errorf("reactivate: status %d: %s", status, body)
+		}
+	})
+}
+
+// webhookReceiver records the webhook requests it receives
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+}
+
+func (rec *webhookReceiver) received() []receivedWebhook {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return slices.Clone(rec.requests)
+}
+
+// signatures returns the signatures a request should carry for secrets, in order
+func signatures(request receivedWebhook, secrets ...string) string {
+	timestamp, _ := strconv.ParseInt(request.header.Get(services.WebhookTimestampHeader), 10, 64)
+	var signed []string
+	for _, secret := range secrets {
+		signed = append(signed, "sha256="+services.SignWebhookPayload(secret, timestamp, request.body))
+	}
+	return strings.Join(signed, ",")
+}
+
+func TestWebhookSignaturesAndReplay(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		receiver := &webhookReceiver{}
+		server := httptest.NewServer(receiver)
+		t.Cleanup(server.Close)
+
+		h := apitest.New(t, backend, apitest.WithWebhooks(), func(cfg *config.Config) {
+			cfg.Webhooks.AllowPrivateTargets = true
+		})
+		ann := h.CreateUser("Ann")
+		group := h.CreateGroup("Trip")
+		h.CreateEvent(group, ann, util.GroupCreate, events.GroupCreated{Name: "Trip", DateTime: time.Now()})
+
+		status, body := h.Do(http.MethodPost, "/api/v2/groups/"+group.GroupID+"/webhooks", map[string]any{
+			"user_id": ann.UserID,
+			"url":     server.URL,
+		})
+		if status != http.StatusOK {
+			t.Fatalf("create webhook: status %d: %s", status, body)
+		}
+		var created struct {
+			WebhookID string `json:"webhook_id"`
+			Secret    string `json:"secret"`
+		}
+		json.Unmarshal(body, &created)
+
+		first := h.CreateExpense(group, ann, ann)
+		h.Wait()
+		requests := receiver.received()
+		if len(requests) != 1 {
+			t.Fatalf("received %d requests, want 1", len(requests))
+		}
+		if got, want := requests[0].header.Get(services.WebhookSignatureHeader), signatures(requests[0], created.Secret); got != want {
+			t.Errorf("signature %q, want %q", got, want)
+		}
+
+		// During a rotation the new and the previous secret both sign
+		status, body = h.Do(http.MethodPost, "/api/v2/webhooks/"+created.WebhookID+"/rotate-secret?user_id="+ann.UserID, nil)
+		if status != http.StatusOK {
+			t.Fatalf("rotate secret: status %d: %s", status, body)
+		}
+		var rotated struct {
+			Secret string `json:"secret"`
+		}
+		json.Unmarshal(body, &rotated)
+		if rotated.Secret == "" || rotated.Secret == created.Secret {
+			t.Fatalf("rotation returned secret %q", rotated.Secret)
+		}
+		h.CreateExpense(group, ann, ann)
+		h.Wait()
+		requests = receiver.received()
+		if len(requests) != 2 {
+			t.Fatalf("received %d requests, want 2", len(requests))
+		}
+		if got, want := requests[1].header.Get(services.WebhookSignatureHeader), signatures(requests[1], rotated.Secret, created.Secret); got != want {
+			t.Errorf("signature during rotation %q, want %q", got, want)
+		}
+
+		// A replay sends the original payload again as a new delivery
+		deliveries, err := h.Stores.Webhooks.GetDeliveriesByWebhookID(context.Background(), created.WebhookID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(deliveries, func(d *domain.WebhookDelivery) bool { return d.EventID == first.EventID })
+		if i < 0 {
+			t.Fatalf("no delivery of event %s in %+v", first.EventID, deliveries)
+		}
+		original := deliveries[i]
+		status, body = h.Do(http.MethodPost, "/api/v2/webhook-deliveries/"+original.DeliveryID+"/replay?user_id="+ann.UserID, nil)
+		if status != http.StatusAccepted {
+			t.Fatalf("replay: status %d: %s", status, body)
+		}
+		var replay struct {
+			DeliveryID string `json:"delivery_id"`
+			ReplayOf   string `json:"replay_of"`
+		}
+		json.Unmarshal(body, &replay)
+		if replay.DeliveryID == "" || replay.DeliveryID == original.DeliveryID || replay.ReplayOf != original.DeliveryID {
+			t.Errorf("replay returned %s, want a new delivery replaying %s", body, original.DeliveryID)
+		}
+		h.Wait()
+		requests = receiver.received()
+		if len(requests) != 3 {
+			t.Fatalf("received %d requests, want 3", len(requests))
+		}
+		replayed := requests[2]
+		if replayed.header.Get(services.WebhookDeliveryHeader) != replay.DeliveryID || string(replayed.body) != string(requests[0].body) {
+			t.Errorf("replay delivered %s as %s, want the first payload as %s", replayed.body, replayed.header.Get(services.WebhookDeliveryHeader), replay.DeliveryID)
+		}
+		if got, want := replayed.header.Get(services.WebhookSignatureHeader), signatures(replayed, rotated.Secret, created.Secret); got != want {
+			t.Errorf("replay signature %q, want %q", got, want)
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
 )
 
 // Event represents an event in the system
@@ -15,7 +16,10 @@ type Event struct {
 	UserID        string          `json:"user_id"`
 	EventType     util.EventType  `json:"event_type"`
 	Payload       json.RawMessage `json:"payload"`
-	CreatedAt     time.Time       `json:"created_at"`
+	// HLC is when the event was recorded, by the client's hybrid logical clock or
+	// the server's if the client sent none. Events are replayed in HLC order.
+	HLC       hlc.HLC   `json:"hlc"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // NewEvent creates a new event
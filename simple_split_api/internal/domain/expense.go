@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+)
+
+// ExpenseStatus tells whether an expense counts towards the balances of its group
+type ExpenseStatus string
+
+const (
+	ExpenseActive  ExpenseStatus = "active"
+	ExpenseDeleted ExpenseStatus = "deleted"
+)
+
+// Expense is the current state of an expense, folded from its EXPENSE_CREATED
+// event and the events linked to it
+type Expense struct {
+	// ExpenseID is the ID of the EXPENSE_CREATED event
+	ExpenseID string        `json:"expense_id"`
+	GroupID   string        `json:"group_id"`
+	Status    ExpenseStatus `json:"status"`
+	// Payload is the expense as last edited
+	Payload json.RawMessage `json:"payload"`
+	// UserID, HLC and CreatedAt are those of the EXPENSE_CREATED event
+	UserID    string    `json:"user_id"`
+	HLC       hlc.HLC   `json:"hlc"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExpenseHistory is the revision chain of an expense: its EXPENSE_CREATED event
+// and the events linked to it, in HLC order
+type ExpenseHistory struct {
+	// ExpenseID is the ID of the EXPENSE_CREATED event
+	ExpenseID string `json:"expense_id"`
+	GroupID   string `json:"group_id"`
+	Deleted   bool   `json:"deleted"`
+	// Expense is the current state of the expense
+	Expense   json.RawMessage   `json:"expense"`
+	Revisions []ExpenseRevision `json:"revisions"`
+}
+
+// ExpenseRevision is one event of an expense's history
+type ExpenseRevision struct {
+	EventID   string         `json:"event_id"`
+	EventType util.EventType `json:"event_type"`
+	UserID    string         `json:"user_id"`
+	HLC       hlc.HLC        `json:"hlc"`
+	CreatedAt time.Time      `json:"created_at"`
+	// Expense is the state of the expense after the revision
+	Expense json.RawMessage `json:"expense"`
+	// Changes lists the fields an EXPENSE_UPDATED event changed
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange is a field of an expense changed by a revision
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
@@ -6,8 +6,11 @@ import (
 
 // Group represents a group in the system
 type Group struct {
-	GroupID   string    `json:"group_id"`
-	Name      string    `json:"name"`
+	GroupID string `json:"group_id"`
+	Name    string `json:"name"`
+	// Version counts the events written to the group. Clients send the version
+	// they last saw with an edit to have it refused if the group changed since.
+	Version   int64     `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
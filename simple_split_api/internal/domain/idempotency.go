@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// IdempotencyKey is a key sent in the Idempotency-Key header of a request, with the
+// response to replay when the request is retried
+type IdempotencyKey struct {
+	Key string
+	// ClaimID identifies the request holding the key. A retry taking over a key
+	// whose lease has passed holds it with a new claim.
+	ClaimID string
+	// Fingerprint is a hash of the method, URL and body of the request
+	Fingerprint string
+	// ResponseStatus is zero while the request is being handled
+	ResponseStatus      int
+	ResponseContentType string
+	ResponseBody        []byte
+	CreatedAt           time.Time
+	ExpiresAt           time.Time
+	// LeaseUntil is when an incomplete key is given up, e.g. after the server handling
+	// its request crashed, and can be claimed again
+	LeaseUntil time.Time
+}
+
+// Completed reports whether the response to the request has been stored
+func (k *IdempotencyKey) Completed() bool {
+	return k.ResponseStatus != 0
+}
@@ -8,16 +8,23 @@ import (
 
 // User represents a user in the system
 type User struct {
-	UserID      string         `json:"user_id"`
-	Name        string         `json:"name"`
-	FirebaseID  sql.NullString `json:"-"` // Use sql.NullString to handle null values
-	CreatedAt   time.Time      `json:"created_at"`
+	UserID     string         `json:"user_id"`
+	Name       string         `json:"name"`
+	FirebaseID sql.NullString `json:"-" openapi:"firebase_id"` // Use sql.NullString to handle null values
+	// Email is left out of the JSON of users, which anyone can list. Only the
+	// response to registering it carries it back.
+	Email sql.NullString `json:"-"`
+	// EmailUnsubscribed is set once the user follows an unsubscribe link
+	EmailUnsubscribed bool `json:"email_unsubscribed"`
+	// UnsubscribeToken identifies the user in unsubscribe links without exposing the user ID
+	UnsubscribeToken sql.NullString `json:"-"`
+	CreatedAt        time.Time      `json:"created_at"`
 }
 
 // MarshalJSON is a custom JSON marshaler to handle the sql.NullString
 func (u User) MarshalJSON() ([]byte, error) {
 	type UserAlias User
-	
+
 	var firebaseID *string
 	if u.FirebaseID.Valid {
 		firebaseID = &u.FirebaseID.String
@@ -32,6 +39,32 @@ func (u User) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// UnmarshalJSON reads the representation written by MarshalJSON
+func (u *User) UnmarshalJSON(data []byte) error {
+	type UserAlias User
+
+	aux := struct {
+		*UserAlias
+		FirebaseID *string `json:"firebase_id"`
+	}{
+		UserAlias: (*UserAlias)(u),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	u.FirebaseID = sql.NullString{}
+	if aux.FirebaseID != nil {
+		u.FirebaseID = sql.NullString{String: *aux.FirebaseID, Valid: true}
+	}
+	return nil
+}
+
+// CanReceiveEmail reports whether the user has an email address and has not unsubscribed
+func (u *User) CanReceiveEmail() bool {
+	return u.Email.Valid && u.Email.String != "" && !u.EmailUnsubscribed
+}
+
 // NewUser creates a new user with the given name
 func NewUser(name string) *User {
 	return &User{
@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "PENDING"
+	DeliverySucceeded = "SUCCEEDED"
+	DeliveryFailed    = "FAILED"
+)
+
+// Webhook represents an outgoing webhook registered for a group
+type Webhook struct {
+	WebhookID string `json:"webhook_id"`
+	GroupID   string `json:"group_id"`
+	CreatedBy string `json:"created_by"`
+	URL       string `json:"url"`
+	// Secret is only ever returned when a webhook is created or its secret is rotated
+	Secret string `json:"-"`
+	// PreviousSecret is still used for signing until PreviousSecretExpiresAt
+	PreviousSecret          sql.NullString `json:"-"`
+	PreviousSecretExpiresAt sql.NullTime   `json:"-"`
+	Active                  bool           `json:"active"`
+	CreatedAt               time.Time      `json:"created_at"`
+}
+
+// NewWebhook creates a new active webhook for a group
+func NewWebhook(groupID string, createdBy string, url string, secret string) *Webhook {
+	return &Webhook{
+		GroupID:   groupID,
+		CreatedBy: createdBy,
+		URL:       url,
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+}
+
+// SigningSecrets returns the secrets a delivery should be signed with at the given time
+func (w *Webhook) SigningSecrets(now time.Time) []string {
+	secrets := []string{w.Secret}
+	if w.PreviousSecret.Valid && w.PreviousSecretExpiresAt.Valid && now.Before(w.PreviousSecretExpiresAt.Time) {
+		secrets = append(secrets, w.PreviousSecret.String)
+	}
+	return secrets
+}
+
+// WebhookDelivery represents a single delivery of an event to a webhook
+type WebhookDelivery struct {
+	DeliveryID     string          `json:"delivery_id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus sql.NullInt32   `json:"-" openapi:"response_status"`
+	LastError      sql.NullString  `json:"-" openapi:"last_error"`
+	ReplayOf       sql.NullString  `json:"-" openapi:"replay_of"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// MarshalJSON is a custom JSON marshaler to handle the nullable columns
+func (d WebhookDelivery) MarshalJSON() ([]byte, error) {
+	type DeliveryAlias WebhookDelivery
+
+	var responseStatus *int32
+	if d.ResponseStatus.Valid {
+		responseStatus = &d.ResponseStatus.Int32
+	}
+	var lastError *string
+	if d.LastError.Valid {
+		lastError = &d.LastError.String
+	}
+	var replayOf *string
+	if d.ReplayOf.Valid {
+		replayOf = &d.ReplayOf.String
+	}
+
+	return json.Marshal(&struct {
+		DeliveryAlias
+		ResponseStatus *int32  `json:"response_status,omitempty"`
+		LastError      *string `json:"last_error,omitempty"`
+		ReplayOf       *string `json:"replay_of,omitempty"`
+	}{
+		DeliveryAlias:  DeliveryAlias(d),
+		ResponseStatus: responseStatus,
+		LastError:      lastError,
+		ReplayOf:       replayOf,
+	})
+}
+
+// NewWebhookDelivery creates a new pending delivery of an event payload
+func NewWebhookDelivery(webhookID string, eventID string, payload json.RawMessage) *WebhookDelivery {
+	now := time.Now()
+	return &WebhookDelivery{
+		WebhookID: webhookID,
+		EventID:   eventID,
+		Payload:   payload,
+		Status:    DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
@@ -1,15 +1,16 @@
-CREATE TABLE groups (
+-- Base schema. IF NOT EXISTS keeps this safe on databases created from the old database.sql
+CREATE TABLE IF NOT EXISTS groups (
                         group_id      UUID PRIMARY KEY DEFAULT uuidv7(),
                         name          TEXT NOT NULL,
                         created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
 );
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
                        user_id      UUID PRIMARY KEY DEFAULT uuidv7(),
                        name          TEXT NOT NULL,
                        created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
-CREATE TABLE events (
+CREATE TABLE IF NOT EXISTS events (
                         event_id     UUID PRIMARY KEY NOT NULL,
                         linked_event_id     UUID  NULL,
 
@@ -22,12 +23,4 @@ CREATE TABLE events (
                         created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
-CREATE INDEX idx_events_group_eventid ON events(group_id, event_id);
-
-
--- Add firebase_id column to users table
-ALTER TABLE users ADD COLUMN firebase_id TEXT DEFAULT NULL;
-
--- Create an index on firebase_id for faster lookups
-CREATE INDEX idx_users_firebase_id ON users(firebase_id);
-
+CREATE INDEX IF NOT EXISTS idx_events_group_eventid ON events(group_id, event_id);
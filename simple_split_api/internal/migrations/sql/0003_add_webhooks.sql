@@ -0,0 +1,31 @@
+-- Outgoing webhooks registered by group owners
+CREATE TABLE IF NOT EXISTS webhooks (
+                          webhook_id                  UUID PRIMARY KEY DEFAULT uuidv7(),
+                          group_id                    UUID NOT NULL REFERENCES groups(group_id) ON DELETE CASCADE,
+                          created_by                  UUID NOT NULL REFERENCES users(user_id),
+                          url                         TEXT NOT NULL,
+                          secret                      TEXT NOT NULL,
+                          previous_secret             TEXT NULL,
+                          previous_secret_expires_at  TIMESTAMPTZ NULL,
+                          active                      BOOLEAN NOT NULL DEFAULT TRUE,
+                          created_at                  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_group_id ON webhooks(group_id);
+
+-- Delivery log, one row per event per webhook (and per replay)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+                                    delivery_id      UUID PRIMARY KEY DEFAULT uuidv7(),
+                                    webhook_id       UUID NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+                                    event_id         UUID NOT NULL,
+                                    payload          JSONB NOT NULL,
+                                    status           TEXT NOT NULL,
+                                    attempts         INT NOT NULL DEFAULT 0,
+                                    response_status  INT NULL,
+                                    last_error       TEXT NULL,
+                                    replay_of        UUID NULL,
+                                    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries(webhook_id, created_at);
@@ -0,0 +1,7 @@
+-- Optional email address for the SMTP notification channel
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT DEFAULT NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_unsubscribed BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS unsubscribe_token TEXT DEFAULT NULL;
+
+-- Unsubscribe links look users up by token
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_unsubscribe_token_unique ON users(unsubscribe_token) WHERE unsubscribe_token IS NOT NULL;
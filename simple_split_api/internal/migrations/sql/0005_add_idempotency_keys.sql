@@ -0,0 +1,12 @@
+-- Responses to requests sent with an Idempotency-Key header, replayed when they are retried
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+                                  idempotency_key        TEXT PRIMARY KEY,
+                                  fingerprint            TEXT NOT NULL,
+                                  response_status        INT NOT NULL DEFAULT 0,
+                                  response_content_type  TEXT NOT NULL DEFAULT '',
+                                  response_body          BYTEA NULL,
+                                  created_at             TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                  expires_at             TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
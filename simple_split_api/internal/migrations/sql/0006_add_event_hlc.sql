@@ -0,0 +1,7 @@
+-- Hybrid logical clock timestamp of each event, the order events are replayed in.
+-- Events recorded before clients sent one are stamped with their creation time.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS hlc TEXT NULL;
+UPDATE events SET hlc = lpad(floor(extract(epoch FROM created_at) * 1000)::bigint::text, 15, '0') || '-00000' WHERE hlc IS NULL;
+ALTER TABLE events ALTER COLUMN hlc SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_group_hlc ON events(group_id, hlc, created_at, event_id);
@@ -0,0 +1,6 @@
+-- Version of each group, counting the events written to it. Clients send the
+-- version they last saw with an edit, so edits based on stale state are refused.
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
+UPDATE groups g SET version = (SELECT count(*) FROM events e WHERE e.group_id = g.group_id);
+
+CREATE INDEX IF NOT EXISTS idx_events_linked_event_id ON events(linked_event_id, created_at, event_id);
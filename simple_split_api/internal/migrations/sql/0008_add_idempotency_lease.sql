@@ -0,0 +1,5 @@
+-- Lease of each idempotency key. A key whose request never completed, e.g. because
+-- the server crashed, can be claimed again once its lease has passed.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lease_until TIMESTAMPTZ NULL;
+UPDATE idempotency_keys SET lease_until = created_at WHERE lease_until IS NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN lease_until SET NOT NULL;
@@ -0,0 +1,28 @@
+-- Base schema. SQLite has no UUID or JSONB types: IDs are UUIDv7 text generated by
+-- the server, payloads are JSON text and timestamps are UTC text that sorts in order.
+CREATE TABLE IF NOT EXISTS groups (
+                        group_id      TEXT PRIMARY KEY NOT NULL,
+                        name          TEXT NOT NULL,
+                        created_at    TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+                       user_id       TEXT PRIMARY KEY NOT NULL,
+                       name          TEXT NOT NULL,
+                       created_at    TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS events (
+                        event_id     TEXT PRIMARY KEY NOT NULL,
+                        linked_event_id     TEXT  NULL,
+
+                        group_id     TEXT NOT NULL ,
+                        user_id      TEXT NOT NULL REFERENCES users(user_id),
+
+                        event_type   TEXT NOT NULL,
+                        payload      TEXT NOT NULL CHECK (json_valid(payload)),
+
+                        created_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_group_eventid ON events(group_id, event_id);
+CREATE INDEX IF NOT EXISTS idx_events_group_created ON events(group_id, created_at);
@@ -0,0 +1,5 @@
+-- Add firebase_id column to users table
+ALTER TABLE users ADD COLUMN firebase_id TEXT DEFAULT NULL;
+
+-- Make sure the index is unique when firebase_id is not null
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_firebase_id_unique ON users(firebase_id) WHERE firebase_id IS NOT NULL;
@@ -0,0 +1,31 @@
+-- Outgoing webhooks registered by group owners
+CREATE TABLE IF NOT EXISTS webhooks (
+                          webhook_id                  TEXT PRIMARY KEY NOT NULL,
+                          group_id                    TEXT NOT NULL REFERENCES groups(group_id) ON DELETE CASCADE,
+                          created_by                  TEXT NOT NULL REFERENCES users(user_id),
+                          url                         TEXT NOT NULL,
+                          secret                      TEXT NOT NULL,
+                          previous_secret             TEXT NULL,
+                          previous_secret_expires_at  TIMESTAMP NULL,
+                          active                      BOOLEAN NOT NULL DEFAULT TRUE,
+                          created_at                  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_group_id ON webhooks(group_id);
+
+-- Delivery log, one row per event per webhook (and per replay)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+                                    delivery_id      TEXT PRIMARY KEY NOT NULL,
+                                    webhook_id       TEXT NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+                                    event_id         TEXT NOT NULL,
+                                    payload          TEXT NOT NULL CHECK (json_valid(payload)),
+                                    status           TEXT NOT NULL,
+                                    attempts         INTEGER NOT NULL DEFAULT 0,
+                                    response_status  INTEGER NULL,
+                                    last_error       TEXT NULL,
+                                    replay_of        TEXT NULL,
+                                    created_at       TIMESTAMP NOT NULL,
+                                    updated_at       TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries(webhook_id, created_at);
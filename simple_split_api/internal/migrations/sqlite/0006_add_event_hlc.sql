@@ -0,0 +1,8 @@
+-- Hybrid logical clock timestamp of each event, the order events are replayed in.
+-- Events recorded before clients sent one are stamped with their creation time.
+ALTER TABLE events ADD COLUMN hlc TEXT NOT NULL DEFAULT '';
+UPDATE events
+SET hlc = printf('%015d-00000', CAST(strftime('%s', created_at) AS INTEGER) * 1000 + CAST(substr(strftime('%f', created_at), 4) AS INTEGER))
+WHERE hlc = '';
+
+CREATE INDEX IF NOT EXISTS idx_events_group_hlc ON events(group_id, hlc, created_at, event_id);
@@ -0,0 +1,4 @@
+-- Lease of each idempotency key. A key whose request never completed, e.g. because
+-- the server crashed, can be claimed again once its lease has passed.
+ALTER TABLE idempotency_keys ADD COLUMN lease_until TIMESTAMP NULL;
+UPDATE idempotency_keys SET lease_until = created_at;
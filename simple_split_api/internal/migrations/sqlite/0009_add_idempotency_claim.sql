@@ -0,0 +1,3 @@
+-- Claim holding each idempotency key. A request that outlived its lease must not
+-- store its response in, or release, the claim of the retry that took the key over.
+ALTER TABLE idempotency_keys ADD COLUMN claim_id TEXT NOT NULL DEFAULT '';
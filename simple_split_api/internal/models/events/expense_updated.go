@@ -0,0 +1,6 @@
+package events
+
+// ExpenseUpdated is the payload of EXPENSE_UPDATED events. The event links to the
+// EXPENSE_CREATED event of the expense through linked_event_id and replaces every
+// field of the expense, so it carries the whole expense as edited.
+type ExpenseUpdated ExpenseCreated
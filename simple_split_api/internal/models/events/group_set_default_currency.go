@@ -0,0 +1,10 @@
+package events
+
+import (
+	"time"
+)
+
+type GroupSetDefaultCurrency struct {
+	Currency string    `json:"currency"`
+	DateTime time.Time `json:"date_time"`
+}
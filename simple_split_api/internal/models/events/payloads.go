@@ -0,0 +1,20 @@
+package events
+
+import "github.com/RealZimboGuy/budgetApp/internal/util"
+
+// Payloads maps each event type to the type of its payload. USER_NAME_CHANGED
+// payloads have no fixed shape, so it maps to nil.
+// EXPENSE_DELETED and EXPENSE_RESTORED events carry a copy of the payload of the
+// expense they delete or restore.
+var Payloads = map[util.EventType]any{
+	util.GroupCreate:             GroupCreated{},
+	util.GroupAddCurrency:        GroupAddCurrency{},
+	util.GroupRemoveCurrency:     GroupRemoveCurrency{},
+	util.GroupSetDefaultCurrency: GroupSetDefaultCurrency{},
+	util.GroupUserJoined:         GroupUserJoin{},
+	util.UserNameChanged:         nil,
+	util.ExpenseCreated:          ExpenseCreated{},
+	util.ExpenseUpdated:          ExpenseUpdated{},
+	util.ExpenseDeleted:          ExpenseCreated{},
+	util.ExpenseRestored:         ExpenseCreated{},
+}
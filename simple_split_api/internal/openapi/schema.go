@@ -0,0 +1,189 @@
+package openapi
+
+import (
+	"database/sql"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// textMarshalerType is implemented by types encoding/json writes as strings
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// knownTypes maps types with custom JSON encodings to their schemas. The sql.Null*
+// types only appear in the JSON of types that marshal them by hand as optional values.
+var knownTypes = map[reflect.Type]Schema{
+	reflect.TypeOf(time.Time{}):         {Type: "string", Format: "date-time"},
+	reflect.TypeOf(json.RawMessage{}):   {},
+	reflect.TypeOf(sql.NullString{}):    {Type: "string"},
+	reflect.TypeOf(sql.NullInt32{}):     {Type: "integer", Format: "int32"},
+	reflect.TypeOf(sql.NullInt64{}):     {Type: "integer", Format: "int64"},
+	reflect.TypeOf(sql.NullBool{}):      {Type: "boolean"},
+	reflect.TypeOf(sql.NullTime{}):      {Type: "string", Format: "date-time"},
+	reflect.TypeOf(sql.NullFloat64{}):   {Type: "number", Format: "double"},
+	reflect.TypeOf(map[string]any(nil)): {Type: "object"},
+}
+
+// Generator derives schemas from Go types by reflection. Named structs become
+// components and are referenced wherever they are used.
+//
+// Fields follow the encoding/json rules. Fields tagged json:"-" are left out unless
+// they carry an openapi:"name" tag, which documents fields that a MarshalJSON
+// method writes under that name.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// NewGenerator creates a generator with no components
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// Schema returns the schema of the type of value
+func (g *Generator) Schema(value any) *Schema {
+	if value == nil {
+		return &Schema{}
+	}
+	return g.schemaOf(reflect.TypeOf(value))
+}
+
+// Define adds a component with the given name and returns a reference to it
+func (g *Generator) Define(name string, schema *Schema) *Schema {
+	g.schemas[name] = schema
+	return Ref(name)
+}
+
+// Component returns the named component, or nil when it has not been generated
+func (g *Generator) Component(name string) *Schema {
+	return g.schemas[name]
+}
+
+// Components returns every component generated so far
+func (g *Generator) Components() Components {
+	return Components{Schemas: g.schemas}
+}
+
+func (g *Generator) schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	if known, ok := knownTypes[t]; ok {
+		known.Nullable = nullable && known.Type != ""
+		return &known
+	}
+	if t.Implements(textMarshalerType) {
+		// encoding/json writes types with a text form as strings
+		return &Schema{Type: "string", Nullable: nullable}
+	}
+
+	var schema *Schema
+	switch t.Kind() {
+	case reflect.String:
+		schema = &Schema{Type: "string"}
+	case reflect.Bool:
+		schema = &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		schema = &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		schema = &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		schema = &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		schema = &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64
+			schema = &Schema{Type: "string", Format: "byte"}
+		} else {
+			schema = &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+		}
+	case reflect.Map:
+		schema = &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			schema = g.structSchema(t)
+			break
+		}
+		// Pointers to structs are references, which can't be marked nullable in OpenAPI 3.0
+		return Ref(g.component(t))
+	default:
+		// Interfaces and anything else accept any JSON value
+		return &Schema{}
+	}
+
+	schema.Nullable = nullable
+	return schema
+}
+
+// component generates the component of a named struct and returns its name
+func (g *Generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.schemas[name]; taken {
+		// Another package has a type with the same name
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// Registered before the fields are generated so recursive types terminate
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t, false)
+	return schema
+}
+
+// addFields adds the JSON fields of struct type t to schema, flattening embedded structs.
+// Like encoding/json, fields of the outer struct win over promoted ones.
+func (g *Generator) addFields(schema *Schema, t reflect.Type, promoted bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			name = field.Tag.Get("openapi")
+			if name == "" {
+				continue
+			}
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded, true)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if _, exists := schema.Properties[name]; exists && promoted {
+			continue
+		}
+		schema.Properties[name] = g.schemaOf(field.Type)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors returned by repositories, wrapped with details. Check them with errors.Is.
+var (
+	// ErrNotFound means the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the write clashes with an existing row
+	ErrConflict = errors.New("conflict")
+	// ErrValidation means the database rejected the values given, e.g. a malformed ID or a missing reference
+	ErrValidation = errors.New("validation failed")
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation           = "23505"
+	pgForeignKeyViolation       = "23503"
+	pgNotNullViolation          = "23502"
+	pgCheckViolation            = "23514"
+	pgInvalidTextRepresentation = "22P02"
+	pgStringDataRightTruncation = "22001"
+)
+
+// classify tags database errors caused by the values in the request with
+// ErrConflict or ErrValidation, other errors are returned unchanged
+func classify(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case pgForeignKeyViolation, pgNotNullViolation, pgCheckViolation, pgInvalidTextRepresentation, pgStringDataRightTruncation:
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	return err
+}
+
+// NewID returns a time-ordered UUIDv7, for backends without the uuidv7() column default
+func NewID() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// IDOrNew returns id normalised by ParseID, or a new ID when it is empty
+func IDOrNew(kind string, id string) (string, error) {
+	if id == "" {
+		return NewID(), nil
+	}
+	return ParseID(kind, id)
+}
+
+// ParseID normalises an ID the way a UUID column does, rejecting malformed ones
+// with ErrValidation. kind names the ID in the error, e.g. "user".
+func ParseID(kind string, id string) (string, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid %s ID %q", ErrValidation, kind, id)
+	}
+	return parsed.String(), nil
+}
@@ -11,6 +11,20 @@ import (
 	"github.com/RealZimboGuy/budgetApp/internal/util"
 )
 
+// expenseEventTypes lists the event types of an expense's revision chain in SQL,
+// those for which util.EventType.IsExpense is true
+const expenseEventTypes = `('EXPENSE_CREATED', 'EXPENSE_UPDATED', 'EXPENSE_DELETED', 'EXPENSE_RESTORED')`
+
+// currencyEventTypes lists the event types that change a group's currencies in SQL,
+// those for which util.EventType.IsCurrencyChange is true
+const currencyEventTypes = `('GROUP_ADD_CURRENCY', 'GROUP_REMOVE_CURRENCY', 'GROUP_SET_DEFAULT_CURRENCY')`
+
+// currencyEventsFilter selects the events GetCurrencyEvents returns of the group $1
+const currencyEventsFilter = `
+	group_id = $1 AND (event_type IN ` + currencyEventTypes + ` OR (
+		event_type IN ('EXPENSE_CREATED', 'EXPENSE_UPDATED') AND hlc < (
+			SELECT MIN(hlc) FROM events WHERE group_id = $1 AND event_type IN ` + currencyEventTypes + `)))`
+
 // EventRepository handles database operations for events
 type EventRepository struct {
 	DB *util.Database
@@ -23,12 +37,14 @@ func NewEventRepository(db *util.Database) *EventRepository {
 	}
 }
 
-// Create adds a new event to the database
+// Create adds a new event to the database, setting its creation time to the stored
+// one, and its HLC to that time unless one is set
 func (r *EventRepository) Create(ctx context.Context, event *domain.Event) error {
 	query := `
-		INSERT INTO events (event_id, linked_event_id, group_id, user_id, event_type, payload)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO events (event_id, linked_event_id, group_id, user_id, event_type, payload, hlc)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, lpad(floor(extract(epoch FROM now()) * 1000)::bigint::text, 15, '0') || '-00000'))
 		ON CONFLICT (event_id) DO NOTHING
+		RETURNING hlc, created_at
 	`
 
 	var linkedEventID interface{}
@@ -38,7 +54,7 @@ func (r *EventRepository) Create(ctx context.Context, event *domain.Event) error
 		linkedEventID = event.LinkedEventID
 	}
 
-	result, err := r.DB.DB.ExecContext(
+	err := r.DB.QueryRowContext(
 		ctx,
 		query,
 		event.EventID,
@@ -47,18 +63,16 @@ func (r *EventRepository) Create(ctx context.Context, event *domain.Event) error
 		event.UserID,
 		string(event.EventType),
 		event.Payload,
-	)
+		event.HLC,
+	).Scan(&event.HLC, &event.CreatedAt)
 	if err != nil {
+		// Nothing is returned when the event already existed
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.Info("Event already existed", "eventID", event.EventID)
+			return fmt.Errorf("event %w: %s already exists", ErrConflict, event.EventID)
+		}
 		slog.Error("Error creating Event", "error", err)
-		return fmt.Errorf("failed to create event: %w", err)
-	}
-
-	// Optional: detect duplicate insert (no-op)
-	rowsAffected, err := result.RowsAffected()
-	if err == nil && rowsAffected == 0 {
-		// event already existed — not an error
-		slog.Info("Event already existed", "eventID", event.EventID)
-		return fmt.Errorf("event already existed: %w", err)
+		return fmt.Errorf("failed to create event: %w", classify(err))
 	}
 
 	return nil
@@ -67,45 +81,51 @@ func (r *EventRepository) Create(ctx context.Context, event *domain.Event) error
 // GetByID retrieves an event by ID
 func (r *EventRepository) GetByID(ctx context.Context, eventID string) (*domain.Event, error) {
 	query := `
-		SELECT event_id, group_id, user_id, event_type, payload, created_at
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
 		FROM events
 		WHERE event_id = $1
 	`
 
 	event := &domain.Event{}
 	var eventTypeStr string
-	err := r.DB.DB.QueryRowContext(ctx, query, eventID).Scan(
+	var linkedEventID sql.NullString
+	err := r.DB.QueryRowContext(ctx, query, eventID).Scan(
 		&event.EventID,
+		&linkedEventID,
 		&event.GroupID,
 		&event.UserID,
 		&eventTypeStr,
 		&event.Payload,
+		&event.HLC,
 		&event.CreatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("event not found: %s", eventID)
+			return nil, fmt.Errorf("event %w: %s", ErrNotFound, eventID)
 		}
-		return nil, fmt.Errorf("failed to get event: %w", err)
+		return nil, fmt.Errorf("failed to get event: %w", classify(err))
 	}
 
+	if linkedEventID.Valid {
+		event.LinkedEventID = linkedEventID.String
+	}
 	event.EventType = util.EventType(eventTypeStr)
 	return event, nil
 }
 
-// GetByGroupID retrieves all events for a group
+// GetByGroupID retrieves all events for a group, latest HLC first
 func (r *EventRepository) GetByGroupID(ctx context.Context, groupID string) ([]*domain.Event, error) {
 	query := `
-		SELECT event_id, group_id, user_id, event_type, payload, created_at
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
 		FROM events
 		WHERE group_id = $1
-		ORDER BY created_at DESC
+		ORDER BY hlc DESC, created_at DESC, event_id DESC
 	`
 
-	rows, err := r.DB.DB.QueryContext(ctx, query, groupID)
+	rows, err := r.DB.QueryContext(ctx, query, groupID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query events: %w", err)
+		return nil, fmt.Errorf("failed to query events: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -113,16 +133,22 @@ func (r *EventRepository) GetByGroupID(ctx context.Context, groupID string) ([]*
 	for rows.Next() {
 		event := &domain.Event{}
 		var eventTypeStr string
+		var linkedEventID sql.NullString
 		if err := rows.Scan(
 			&event.EventID,
+			&linkedEventID,
 			&event.GroupID,
 			&event.UserID,
 			&eventTypeStr,
 			&event.Payload,
+			&event.HLC,
 			&event.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan event row: %w", err)
 		}
+		if linkedEventID.Valid {
+			event.LinkedEventID = linkedEventID.String
+		}
 		event.EventType = util.EventType(eventTypeStr)
 		events = append(events, event)
 	}
@@ -136,7 +162,8 @@ func (r *EventRepository) GetByGroupID(ctx context.Context, groupID string) ([]*
 
 // GetEventsByGroupAfterID retrieves events for a group with pagination support
 // If afterEventID is "0", it returns the first batch of events
-// Results are ordered chronologically (ascending by created_at)
+// Results are ordered by arrival (ascending by created_at), so events uploaded after
+// a page was read are on a later page even when their HLC is older
 func (r *EventRepository) GetEventsByGroupAfterID(ctx context.Context, groupID string, afterEventID string, limit int) ([]*domain.Event, error) {
 	var query string
 	var rows *sql.Rows
@@ -145,17 +172,17 @@ func (r *EventRepository) GetEventsByGroupAfterID(ctx context.Context, groupID s
 	if afterEventID == "0" {
 		// If afterEventID is "0", get the first batch of events
 		query = `
-			SELECT event_id, linked_event_id,group_id, user_id, event_type, payload, created_at
+			SELECT event_id, linked_event_id,group_id, user_id, event_type, payload, hlc, created_at
 			FROM events
 			WHERE group_id = $1
 			ORDER BY created_at ASC
 			LIMIT $2
 		`
-		rows, err = r.DB.DB.QueryContext(ctx, query, groupID, limit)
+		rows, err = r.DB.QueryContext(ctx, query, groupID, limit)
 	} else {
 		// Otherwise, get events after the specified event ID
 		query = `
-			SELECT e.event_id,e.linked_event_id, e.group_id, e.user_id, e.event_type, e.payload, e.created_at
+			SELECT e.event_id,e.linked_event_id, e.group_id, e.user_id, e.event_type, e.payload, e.hlc, e.created_at
 			FROM events e
 			JOIN events after_event ON after_event.event_id = $2
 			WHERE e.group_id = $1
@@ -163,11 +190,11 @@ func (r *EventRepository) GetEventsByGroupAfterID(ctx context.Context, groupID s
 			ORDER BY e.created_at ASC
 			LIMIT $3
 		`
-		rows, err = r.DB.DB.QueryContext(ctx, query, groupID, afterEventID, limit)
+		rows, err = r.DB.QueryContext(ctx, query, groupID, afterEventID, limit)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to query events: %w", err)
+		return nil, fmt.Errorf("failed to query events: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -183,6 +210,181 @@ func (r *EventRepository) GetEventsByGroupAfterID(ctx context.Context, groupID s
 			&event.UserID,
 			&eventTypeStr,
 			&event.Payload,
+			&event.HLC,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		if linkedEventID.Valid {
+			event.LinkedEventID = linkedEventID.String
+		}
+		event.EventType = util.EventType(eventTypeStr)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// GetFirstByGroupAndType retrieves the earliest event of a given type in a group
+func (r *EventRepository) GetFirstByGroupAndType(ctx context.Context, groupID string, eventType util.EventType) (*domain.Event, error) {
+	query := `
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
+		FROM events
+		WHERE group_id = $1 AND event_type = $2
+		ORDER BY created_at ASC
+		LIMIT 1
+	`
+
+	event := &domain.Event{}
+	var eventTypeStr string
+	var linkedEventID sql.NullString
+	err := r.DB.QueryRowContext(ctx, query, groupID, string(eventType)).Scan(
+		&event.EventID,
+		&linkedEventID,
+		&event.GroupID,
+		&event.UserID,
+		&eventTypeStr,
+		&event.Payload,
+		&event.HLC,
+		&event.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s event %w for group: %s", eventType, ErrNotFound, groupID)
+		}
+		return nil, fmt.Errorf("failed to get event: %w", classify(err))
+	}
+
+	if linkedEventID.Valid {
+		event.LinkedEventID = linkedEventID.String
+	}
+	event.EventType = util.EventType(eventTypeStr)
+	return event, nil
+}
+
+// GetChain retrieves the EXPENSE_* events of a group that are eventID or link to
+// it, in arrival order. It returns ErrNotFound when there are none.
+func (r *EventRepository) GetChain(ctx context.Context, groupID string, eventID string) ([]*domain.Event, error) {
+	query := `
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
+		FROM events
+		WHERE group_id = $1 AND (event_id = $2 OR linked_event_id = $2) AND event_type IN ` + expenseEventTypes + `
+		ORDER BY created_at ASC, event_id ASC
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, groupID, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var events []*domain.Event
+	for rows.Next() {
+		event := &domain.Event{}
+		var eventTypeStr string
+		var linkedEventID sql.NullString
+		if err := rows.Scan(
+			&event.EventID,
+			&linkedEventID,
+			&event.GroupID,
+			&event.UserID,
+			&eventTypeStr,
+			&event.Payload,
+			&event.HLC,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		if linkedEventID.Valid {
+			event.LinkedEventID = linkedEventID.String
+		}
+		event.EventType = util.EventType(eventTypeStr)
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event %w: %s", ErrNotFound, eventID)
+	}
+	return events, nil
+}
+
+// GetChainHead retrieves the latest event of the chain GetChain returns. Events
+// are ordered by arrival, so the head is the last edit the server accepted.
+func (r *EventRepository) GetChainHead(ctx context.Context, groupID string, eventID string) (*domain.Event, error) {
+	query := `
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
+		FROM events
+		WHERE group_id = $1 AND (event_id = $2 OR linked_event_id = $2) AND event_type IN ` + expenseEventTypes + `
+		ORDER BY created_at DESC, event_id DESC
+		LIMIT 1
+	`
+
+	event := &domain.Event{}
+	var eventTypeStr string
+	var linkedEventID sql.NullString
+	err := r.DB.QueryRowContext(ctx, query, groupID, eventID).Scan(
+		&event.EventID,
+		&linkedEventID,
+		&event.GroupID,
+		&event.UserID,
+		&eventTypeStr,
+		&event.Payload,
+		&event.HLC,
+		&event.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("event %w: %s", ErrNotFound, eventID)
+		}
+		return nil, fmt.Errorf("failed to get event: %w", classify(err))
+	}
+
+	if linkedEventID.Valid {
+		event.LinkedEventID = linkedEventID.String
+	}
+	event.EventType = util.EventType(eventTypeStr)
+	return event, nil
+}
+
+// GetCurrencyEvents retrieves the currency events of a group, and the EXPENSE_CREATED
+// and EXPENSE_UPDATED events ordered before the first of them by HLC, latest HLC first
+func (r *EventRepository) GetCurrencyEvents(ctx context.Context, groupID string) ([]*domain.Event, error) {
+	query := `
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
+		FROM events
+		WHERE ` + currencyEventsFilter + `
+		ORDER BY hlc DESC, created_at DESC, event_id DESC
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var events []*domain.Event
+	for rows.Next() {
+		event := &domain.Event{}
+		var eventTypeStr string
+		var linkedEventID sql.NullString
+		if err := rows.Scan(
+			&event.EventID,
+			&linkedEventID,
+			&event.GroupID,
+			&event.UserID,
+			&eventTypeStr,
+			&event.Payload,
+			&event.HLC,
 			&event.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan event row: %w", err)
@@ -204,14 +406,14 @@ func (r *EventRepository) GetEventsByGroupAfterID(ctx context.Context, groupID s
 // GetAll retrieves all events
 func (r *EventRepository) GetAll(ctx context.Context) ([]*domain.Event, error) {
 	query := `
-		SELECT event_id, group_id, user_id, event_type, payload, created_at
+		SELECT event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at
 		FROM events
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.DB.DB.QueryContext(ctx, query)
+	rows, err := r.DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query events: %w", err)
+		return nil, fmt.Errorf("failed to query events: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -219,16 +421,22 @@ func (r *EventRepository) GetAll(ctx context.Context) ([]*domain.Event, error) {
 	for rows.Next() {
 		event := &domain.Event{}
 		var eventTypeStr string
+		var linkedEventID sql.NullString
 		if err := rows.Scan(
 			&event.EventID,
+			&linkedEventID,
 			&event.GroupID,
 			&event.UserID,
 			&eventTypeStr,
 			&event.Payload,
+			&event.HLC,
 			&event.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan event row: %w", err)
 		}
+		if linkedEventID.Valid {
+			event.LinkedEventID = linkedEventID.String
+		}
 		event.EventType = util.EventType(eventTypeStr)
 		events = append(events, event)
 	}
@@ -248,7 +456,7 @@ func (r *EventRepository) Update(ctx context.Context, event *domain.Event) error
 		WHERE event_id = $5
 	`
 
-	result, err := r.DB.DB.ExecContext(ctx,
+	result, err := r.DB.ExecContext(ctx,
 		query,
 		event.GroupID,
 		event.UserID,
@@ -257,7 +465,7 @@ func (r *EventRepository) Update(ctx context.Context, event *domain.Event) error
 		event.EventID,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update event: %w", err)
+		return fmt.Errorf("failed to update event: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -266,7 +474,7 @@ func (r *EventRepository) Update(ctx context.Context, event *domain.Event) error
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("event not found: %s", event.EventID)
+		return fmt.Errorf("event %w: %s", ErrNotFound, event.EventID)
 	}
 
 	return nil
@@ -279,9 +487,9 @@ func (r *EventRepository) Delete(ctx context.Context, eventID string) error {
 		WHERE event_id = $1
 	`
 
-	result, err := r.DB.DB.ExecContext(ctx, query, eventID)
+	result, err := r.DB.ExecContext(ctx, query, eventID)
 	if err != nil {
-		return fmt.Errorf("failed to delete event: %w", err)
+		return fmt.Errorf("failed to delete event: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -290,7 +498,7 @@ func (r *EventRepository) Delete(ctx context.Context, eventID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("event not found: %s", eventID)
+		return fmt.Errorf("event %w: %s", ErrNotFound, eventID)
 	}
 
 	return nil
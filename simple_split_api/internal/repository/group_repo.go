@@ -22,17 +22,18 @@ func NewGroupRepository(db *util.Database) *GroupRepository {
 	}
 }
 
-// Create adds a new group to the database
+// Create adds a new group to the database, generating its ID unless one is set.
+// A group with the same ID is a conflict.
 func (r *GroupRepository) Create(ctx context.Context, group *domain.Group) error {
 	query := `
-		INSERT INTO groups (name)
-		VALUES ($1)
-		RETURNING group_id, created_at
+		INSERT INTO groups (group_id, name)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, uuidv7()), $2)
+		RETURNING group_id, version, created_at
 	`
 
-	err := r.DB.DB.QueryRowContext(ctx, query, group.Name).Scan(&group.GroupID, &group.CreatedAt)
+	err := r.DB.QueryRowContext(ctx, query, group.GroupID, group.Name).Scan(&group.GroupID, &group.Version, &group.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create group: %w", err)
+		return fmt.Errorf("failed to create group: %w", classify(err))
 	}
 
 	return nil
@@ -41,39 +42,88 @@ func (r *GroupRepository) Create(ctx context.Context, group *domain.Group) error
 // GetByID retrieves a group by ID
 func (r *GroupRepository) GetByID(ctx context.Context, groupID string) (*domain.Group, error) {
 	query := `
-		SELECT group_id, name, created_at
+		SELECT group_id, name, version, created_at
 		FROM groups
 		WHERE group_id = $1
 	`
 
 	group := &domain.Group{}
-	err := r.DB.DB.QueryRowContext(ctx, query, groupID).Scan(
+	err := r.DB.QueryRowContext(ctx, query, groupID).Scan(
 		&group.GroupID,
 		&group.Name,
+		&group.Version,
 		&group.CreatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("group not found: %s", groupID)
+			return nil, fmt.Errorf("group %w: %s", ErrNotFound, groupID)
 		}
-		return nil, fmt.Errorf("failed to get group: %w", err)
+		return nil, fmt.Errorf("failed to get group: %w", classify(err))
 	}
 
 	return group, nil
 }
 
+// GetForUpdate retrieves a group, locking its row until the transaction in ctx ends
+func (r *GroupRepository) GetForUpdate(ctx context.Context, groupID string) (*domain.Group, error) {
+	query := `
+		SELECT group_id, name, version, created_at
+		FROM groups
+		WHERE group_id = $1
+		FOR UPDATE
+	`
+
+	group := &domain.Group{}
+	err := r.DB.QueryRowContext(ctx, query, groupID).Scan(
+		&group.GroupID,
+		&group.Name,
+		&group.Version,
+		&group.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("group %w: %s", ErrNotFound, groupID)
+		}
+		return nil, fmt.Errorf("failed to get group: %w", classify(err))
+	}
+
+	return group, nil
+}
+
+// IncrementVersion records a change to a group and returns its new version
+func (r *GroupRepository) IncrementVersion(ctx context.Context, groupID string) (int64, error) {
+	query := `
+		UPDATE groups
+		SET version = version + 1
+		WHERE group_id = $1
+		RETURNING version
+	`
+
+	var version int64
+	err := r.DB.QueryRowContext(ctx, query, groupID).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("group %w: %s", ErrNotFound, groupID)
+		}
+		return 0, fmt.Errorf("failed to increment group version: %w", classify(err))
+	}
+
+	return version, nil
+}
+
 // GetAll retrieves all groups
 func (r *GroupRepository) GetAll(ctx context.Context) ([]*domain.Group, error) {
 	query := `
-		SELECT group_id, name, created_at
+		SELECT group_id, name, version, created_at
 		FROM groups
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.DB.DB.QueryContext(ctx, query)
+	rows, err := r.DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query groups: %w", err)
+		return nil, fmt.Errorf("failed to query groups: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -83,6 +133,7 @@ func (r *GroupRepository) GetAll(ctx context.Context) ([]*domain.Group, error) {
 		if err := rows.Scan(
 			&group.GroupID,
 			&group.Name,
+			&group.Version,
 			&group.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan group row: %w", err)
@@ -105,9 +156,9 @@ func (r *GroupRepository) Update(ctx context.Context, group *domain.Group) error
 		WHERE group_id = $2
 	`
 
-	result, err := r.DB.DB.ExecContext(ctx, query, group.Name, group.GroupID)
+	result, err := r.DB.ExecContext(ctx, query, group.Name, group.GroupID)
 	if err != nil {
-		return fmt.Errorf("failed to update group: %w", err)
+		return fmt.Errorf("failed to update group: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -116,7 +167,7 @@ func (r *GroupRepository) Update(ctx context.Context, group *domain.Group) error
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("group not found: %s", group.GroupID)
+		return fmt.Errorf("group %w: %s", ErrNotFound, group.GroupID)
 	}
 
 	return nil
@@ -129,9 +180,9 @@ func (r *GroupRepository) Delete(ctx context.Context, groupID string) error {
 		WHERE group_id = $1
 	`
 
-	result, err := r.DB.DB.ExecContext(ctx, query, groupID)
+	result, err := r.DB.ExecContext(ctx, query, groupID)
 	if err != nil {
-		return fmt.Errorf("failed to delete group: %w", err)
+		return fmt.Errorf("failed to delete group: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -140,7 +191,7 @@ func (r *GroupRepository) Delete(ctx context.Context, groupID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("group not found: %s", groupID)
+		return fmt.Errorf("group %w: %s", ErrNotFound, groupID)
 	}
 
 	return nil
@@ -149,7 +200,7 @@ func (r *GroupRepository) Delete(ctx context.Context, groupID string) error {
 // GetByUserID retrieves all groups associated with a user
 func (r *GroupRepository) GetByUserID(ctx context.Context, userID string) ([]*domain.Group, error) {
 	query := `
-		SELECT g.group_id, g.name, g.created_at
+		SELECT g.group_id, g.name, g.version, g.created_at
 		FROM groups g
 		INNER JOIN (
 			SELECT DISTINCT group_id
@@ -159,9 +210,9 @@ func (r *GroupRepository) GetByUserID(ctx context.Context, userID string) ([]*do
 		ORDER BY g.created_at DESC
 	`
 
-	rows, err := r.DB.DB.QueryContext(ctx, query, userID)
+	rows, err := r.DB.QueryContext(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query groups by user ID: %w", err)
+		return nil, fmt.Errorf("failed to query groups by user ID: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -171,6 +222,7 @@ func (r *GroupRepository) GetByUserID(ctx context.Context, userID string) ([]*do
 		if err := rows.Scan(
 			&group.GroupID,
 			&group.Name,
+			&group.Version,
 			&group.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan group row: %w", err)
@@ -184,3 +236,79 @@ func (r *GroupRepository) GetByUserID(ctx context.Context, userID string) ([]*do
 
 	return groups, nil
 }
+
+// Search returns the groups whose ID matches text exactly or whose name contains it
+func (r *GroupRepository) Search(ctx context.Context, text string, limit int) ([]*domain.Group, error) {
+	query := `
+		SELECT group_id, name, version, created_at
+		FROM groups
+		WHERE group_id::text = $1 OR name ILIKE '%' || $1 || '%'
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search groups: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var groups []*domain.Group
+	for rows.Next() {
+		group := &domain.Group{}
+		if err := rows.Scan(
+			&group.GroupID,
+			&group.Name,
+			&group.Version,
+			&group.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan group row: %w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating group rows: %w", err)
+	}
+
+	return groups, nil
+}
+
+// DeleteWithEvents removes a group together with its events in one transaction and
+// returns the number of events deleted. Webhooks and their deliveries go with the group.
+func (r *GroupRepository) DeleteWithEvents(ctx context.Context, groupID string) (int64, error) {
+	tx, err := r.DB.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM events WHERE group_id = $1`, groupID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete events: %w", classify(err))
+	}
+	eventsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	result, err = tx.ExecContext(ctx, `DELETE FROM groups WHERE group_id = $1`, groupID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete group: %w", classify(err))
+	}
+	groupsDeleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	// Events of a group whose row is already gone are still cleaned up
+	if groupsDeleted == 0 && eventsDeleted == 0 {
+		return 0, fmt.Errorf("group %w: %s", ErrNotFound, groupID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit group deletion: %w", classify(err))
+	}
+
+	return eventsDeleted, nil
+}
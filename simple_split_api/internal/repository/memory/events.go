@@ -0,0 +1,329 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+)
+
+// EventStore keeps events in memory
+type EventStore struct {
+	s *Store
+}
+
+var _ repository.EventStore = (*EventStore)(nil)
+
+// Create adds a new event, setting its creation time, and its HLC to that time
+// unless one is set. An event with the same ID is a conflict and leaves the
+// stored event unchanged.
+func (r *EventStore) Create(ctx context.Context, event *domain.Event) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, err := r.validate(event)
+	if err != nil {
+		return fmt.Errorf("failed to create event: %w", err)
+	}
+	if _, ok := r.s.events[stored.EventID]; ok {
+		return fmt.Errorf("event %w: %s already exists", repository.ErrConflict, event.EventID)
+	}
+
+	stored.CreatedAt = r.s.now()
+	if stored.HLC.IsZero() {
+		stored.HLC = hlc.HLC{Wall: stored.CreatedAt.UnixMilli()}
+	}
+	r.s.events[stored.EventID] = stored
+	event.HLC, event.CreatedAt = stored.HLC, stored.CreatedAt
+	return nil
+}
+
+// GetByID retrieves an event by ID
+func (r *EventStore) GetByID(ctx context.Context, eventID string) (*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	id, err := repository.ParseID("event", eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+	event, ok := r.s.events[id]
+	if !ok {
+		return nil, fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+	}
+	return copyEvent(event), nil
+}
+
+// GetByGroupID retrieves all events for a group, latest HLC first
+func (r *EventStore) GetByGroupID(ctx context.Context, groupID string) ([]*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	events, err := r.groupEvents(groupID)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(events, func(a, b *domain.Event) int {
+		if c := b.HLC.Compare(a.HLC); c != 0 {
+			return c
+		}
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.EventID, a.EventID)
+	})
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return events, nil
+}
+
+// GetCurrencyEvents retrieves the currency events of a group, and the EXPENSE_CREATED
+// and EXPENSE_UPDATED events ordered before the first of them by HLC, latest HLC first
+func (r *EventStore) GetCurrencyEvents(ctx context.Context, groupID string) ([]*domain.Event, error) {
+	events, err := r.GetByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var first *domain.Event
+	for _, event := range events {
+		if event.EventType.IsCurrencyChange() && (first == nil || event.HLC.Before(first.HLC)) {
+			first = event
+		}
+	}
+	return slices.DeleteFunc(events, func(e *domain.Event) bool {
+		if e.EventType.IsCurrencyChange() {
+			return false
+		}
+		isExpense := e.EventType == util.ExpenseCreated || e.EventType == util.ExpenseUpdated
+		return !isExpense || first == nil || !e.HLC.Before(first.HLC)
+	}), nil
+}
+
+// GetEventsByGroupAfterID retrieves events for a group with pagination support
+// If afterEventID is "0", it returns the first batch of events
+// Results are ordered by arrival (ascending by created_at), so events uploaded after
+// a page was read are on a later page even when their HLC is older
+func (r *EventStore) GetEventsByGroupAfterID(ctx context.Context, groupID string, afterEventID string, limit int) ([]*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	events, err := r.groupEvents(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if afterEventID != "0" {
+		id, err := repository.ParseID("event", afterEventID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query events: %w", err)
+		}
+		after, ok := r.s.events[id]
+		if !ok {
+			return make([]*domain.Event, 0), nil
+		}
+		events = slices.DeleteFunc(events, func(e *domain.Event) bool {
+			return !e.CreatedAt.After(after.CreatedAt)
+		})
+	}
+
+	return limitRows(events, limit), nil
+}
+
+// GetFirstByGroupAndType retrieves the earliest event of a given type in a group
+func (r *EventStore) GetFirstByGroupAndType(ctx context.Context, groupID string, eventType util.EventType) (*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	events, err := r.groupEvents(groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if event.EventType == eventType {
+			return event, nil
+		}
+	}
+	return nil, fmt.Errorf("%s event %w for group: %s", eventType, repository.ErrNotFound, groupID)
+}
+
+// GetChain retrieves the EXPENSE_* events of a group that are eventID or link to
+// it, in arrival order. It returns ErrNotFound when there are none.
+func (r *EventStore) GetChain(ctx context.Context, groupID string, eventID string) ([]*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	gid, id, err := chainIDs(groupID, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	var events []*domain.Event
+	for _, event := range r.s.events {
+		if inChain(event, gid, id) {
+			events = append(events, copyEvent(event))
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+	}
+	sortByCreatedAt(events, func(e *domain.Event) time.Time { return e.CreatedAt }, true)
+	return events, nil
+}
+
+// GetChainHead retrieves the latest event of the chain GetChain returns. Events
+// are ordered by arrival, so the head is the last edit the server accepted.
+func (r *EventStore) GetChainHead(ctx context.Context, groupID string, eventID string) (*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	gid, id, err := chainIDs(groupID, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	var head *domain.Event
+	for _, event := range r.s.events {
+		if !inChain(event, gid, id) {
+			continue
+		}
+		if head == nil || event.CreatedAt.After(head.CreatedAt) ||
+			event.CreatedAt.Equal(head.CreatedAt) && event.EventID > head.EventID {
+			head = event
+		}
+	}
+	if head == nil {
+		return nil, fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+	}
+	return copyEvent(head), nil
+}
+
+// chainIDs parses the group and event IDs naming a chain
+func chainIDs(groupID string, eventID string) (string, string, error) {
+	gid, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return "", "", err
+	}
+	id, err := repository.ParseID("event", eventID)
+	if err != nil {
+		return "", "", err
+	}
+	return gid, id, nil
+}
+
+// inChain reports whether event belongs to the chain of the expense eventID in group groupID
+func inChain(event *domain.Event, groupID string, eventID string) bool {
+	return event.GroupID == groupID && (event.EventID == eventID || event.LinkedEventID == eventID) && event.EventType.IsExpense()
+}
+
+// GetAll retrieves all events, newest first
+func (r *EventStore) GetAll(ctx context.Context) ([]*domain.Event, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var events []*domain.Event
+	for _, event := range r.s.events {
+		events = append(events, copyEvent(event))
+	}
+	sortByCreatedAt(events, func(e *domain.Event) time.Time { return e.CreatedAt }, false)
+	return events, nil
+}
+
+// Update updates an event's group, user, type and payload
+func (r *EventStore) Update(ctx context.Context, event *domain.Event) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	updated, err := r.validate(event)
+	if err != nil {
+		return fmt.Errorf("failed to update event: %w", err)
+	}
+	stored, ok := r.s.events[updated.EventID]
+	if !ok {
+		return fmt.Errorf("event %w: %s", repository.ErrNotFound, event.EventID)
+	}
+
+	stored.GroupID, stored.UserID = updated.GroupID, updated.UserID
+	stored.EventType, stored.Payload = updated.EventType, updated.Payload
+	return nil
+}
+
+// Delete removes an event
+func (r *EventStore) Delete(ctx context.Context, eventID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	id, err := repository.ParseID("event", eventID)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	if _, ok := r.s.events[id]; !ok {
+		return fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+	}
+	delete(r.s.events, id)
+	return nil
+}
+
+// groupEvents returns copies of the events of a group, oldest first. The caller
+// must hold the lock.
+func (r *EventStore) groupEvents(groupID string) ([]*domain.Event, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	events := make([]*domain.Event, 0)
+	for _, event := range r.s.events {
+		if event.GroupID == id {
+			events = append(events, copyEvent(event))
+		}
+	}
+	sortByCreatedAt(events, func(e *domain.Event) time.Time { return e.CreatedAt }, true)
+	return events, nil
+}
+
+// validate applies the column types and foreign keys of the events table and
+// returns the event as it would be stored. The caller must hold the lock.
+func (r *EventStore) validate(event *domain.Event) (*domain.Event, error) {
+	stored := copyEvent(event)
+
+	var err error
+	if stored.EventID, err = repository.ParseID("event", event.EventID); err != nil {
+		return nil, err
+	}
+	if stored.GroupID, err = repository.ParseID("group", event.GroupID); err != nil {
+		return nil, err
+	}
+	if stored.UserID, err = repository.ParseID("user", event.UserID); err != nil {
+		return nil, err
+	}
+	if event.LinkedEventID != "" {
+		if stored.LinkedEventID, err = repository.ParseID("linked event", event.LinkedEventID); err != nil {
+			return nil, err
+		}
+	}
+	if event.EventType == "" {
+		return nil, fmt.Errorf("%w: event type is required", repository.ErrValidation)
+	}
+	if !json.Valid(event.Payload) {
+		return nil, fmt.Errorf("%w: payload is not valid JSON", repository.ErrValidation)
+	}
+	if _, ok := r.s.users[stored.UserID]; !ok {
+		return nil, fmt.Errorf("%w: user %s does not exist", repository.ErrValidation, event.UserID)
+	}
+	return stored, nil
+}
+
+func copyEvent(event *domain.Event) *domain.Event {
+	copied := *event
+	copied.Payload = slices.Clone(event.Payload)
+	return &copied
+}
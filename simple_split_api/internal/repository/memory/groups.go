@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+// GroupStore keeps groups in memory
+type GroupStore struct {
+	s *Store
+}
+
+var _ repository.GroupStore = (*GroupStore)(nil)
+
+// Create adds a new group, setting its creation time and its ID unless one is set.
+// A group with the same ID is a conflict.
+func (r *GroupStore) Create(ctx context.Context, group *domain.Group) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	groupID, err := repository.IDOrNew("group", group.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	if _, ok := r.s.groups[groupID]; ok {
+		return fmt.Errorf("group %w: %s already exists", repository.ErrConflict, groupID)
+	}
+
+	stored := &domain.Group{
+		GroupID:   groupID,
+		Name:      group.Name,
+		CreatedAt: r.s.now(),
+	}
+	r.s.groups[stored.GroupID] = stored
+	group.GroupID, group.Version, group.CreatedAt = stored.GroupID, stored.Version, stored.CreatedAt
+	return nil
+}
+
+// GetByID retrieves a group by ID
+func (r *GroupStore) GetByID(ctx context.Context, groupID string) (*domain.Group, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	group, err := r.get(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return copyGroup(group), nil
+}
+
+// GetForUpdate retrieves a group. Units of work do not interleave, so the group
+// is already held against concurrent writes.
+func (r *GroupStore) GetForUpdate(ctx context.Context, groupID string) (*domain.Group, error) {
+	return r.GetByID(ctx, groupID)
+}
+
+// IncrementVersion records a change to a group and returns its new version
+func (r *GroupStore) IncrementVersion(ctx context.Context, groupID string) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	group, err := r.get(groupID)
+	if err != nil {
+		return 0, err
+	}
+	group.Version++
+	return group.Version, nil
+}
+
+// GetAll retrieves all groups, newest first
+func (r *GroupStore) GetAll(ctx context.Context) ([]*domain.Group, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var groups []*domain.Group
+	for _, group := range r.s.groups {
+		groups = append(groups, copyGroup(group))
+	}
+	sortByCreatedAt(groups, func(g *domain.Group) time.Time { return g.CreatedAt }, false)
+	return groups, nil
+}
+
+// Update updates a group's name
+func (r *GroupStore) Update(ctx context.Context, group *domain.Group) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, err := r.get(group.GroupID)
+	if err != nil {
+		return err
+	}
+	stored.Name = group.Name
+	return nil
+}
+
+// Delete removes a group with its webhooks. Its events are kept, as in Postgres
+// where events do not reference groups.
+func (r *GroupStore) Delete(ctx context.Context, groupID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	group, err := r.get(groupID)
+	if err != nil {
+		return err
+	}
+
+	for webhookID, webhook := range r.s.webhooks {
+		if webhook.GroupID == group.GroupID {
+			deleteWebhook(r.s, webhookID)
+		}
+	}
+	delete(r.s.groups, group.GroupID)
+	return nil
+}
+
+// GetByUserID retrieves the groups a user has created events in, newest first
+func (r *GroupStore) GetByUserID(ctx context.Context, userID string) ([]*domain.Group, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	id, err := repository.ParseID("user", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups by user ID: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var groups []*domain.Group
+	for _, event := range r.s.events {
+		group, ok := r.s.groups[event.GroupID]
+		if event.UserID != id || !ok || seen[group.GroupID] {
+			continue
+		}
+		seen[group.GroupID] = true
+		groups = append(groups, copyGroup(group))
+	}
+	sortByCreatedAt(groups, func(g *domain.Group) time.Time { return g.CreatedAt }, false)
+	return groups, nil
+}
+
+// get returns the stored group, the caller must hold the lock
+func (r *GroupStore) get(groupID string) (*domain.Group, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	group, ok := r.s.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("group %w: %s", repository.ErrNotFound, groupID)
+	}
+	return group, nil
+}
+
+func copyGroup(group *domain.Group) *domain.Group {
+	copied := *group
+	return &copied
+}
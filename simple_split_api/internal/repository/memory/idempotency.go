@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+// IdempotencyStore keeps idempotency keys in memory
+type IdempotencyStore struct {
+	s *Store
+}
+
+var _ repository.IdempotencyStore = (*IdempotencyStore)(nil)
+
+// Create claims an idempotency key, removing expired and abandoned keys first
+func (r *IdempotencyStore) Create(ctx context.Context, key *domain.IdempotencyKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	for k, stored := range r.s.idempotencyKeys {
+		if !stored.ExpiresAt.After(now) || (!stored.Completed() && !stored.LeaseUntil.After(now)) {
+			delete(r.s.idempotencyKeys, k)
+		}
+	}
+
+	if _, ok := r.s.idempotencyKeys[key.Key]; ok {
+		return fmt.Errorf("idempotency key %w: %s already exists", repository.ErrConflict, key.Key)
+	}
+
+	stored := &domain.IdempotencyKey{
+		Key:         key.Key,
+		ClaimID:     key.ClaimID,
+		Fingerprint: key.Fingerprint,
+		CreatedAt:   r.s.now(),
+		ExpiresAt:   key.ExpiresAt,
+		LeaseUntil:  key.LeaseUntil,
+	}
+	r.s.idempotencyKeys[stored.Key] = stored
+	key.CreatedAt = stored.CreatedAt
+	return nil
+}
+
+// GetByKey retrieves an idempotency key with its stored response
+func (r *IdempotencyStore) GetByKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	stored, ok := r.s.idempotencyKeys[key]
+	if !ok {
+		return nil, fmt.Errorf("idempotency key %w: %s", repository.ErrNotFound, key)
+	}
+
+	found := *stored
+	found.ResponseBody = slices.Clone(stored.ResponseBody)
+	return &found, nil
+}
+
+// Complete stores the response to the request that claimed the key, unless another
+// claim has taken the key over since
+func (r *IdempotencyStore) Complete(ctx context.Context, key *domain.IdempotencyKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.idempotencyKeys[key.Key]
+	if !ok || stored.ClaimID != key.ClaimID {
+		return fmt.Errorf("idempotency key %w: %s", repository.ErrNotFound, key.Key)
+	}
+
+	stored.ResponseStatus = key.ResponseStatus
+	stored.ResponseContentType = key.ResponseContentType
+	stored.ResponseBody = slices.Clone(key.ResponseBody)
+	return nil
+}
+
+// Delete releases an idempotency key held by the claim of key
+func (r *IdempotencyStore) Delete(ctx context.Context, key *domain.IdempotencyKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if stored, ok := r.s.idempotencyKeys[key.Key]; !ok || stored.ClaimID != key.ClaimID {
+		return fmt.Errorf("idempotency key %w: %s", repository.ErrNotFound, key.Key)
+	}
+
+	delete(r.s.idempotencyKeys, key.Key)
+	return nil
+}
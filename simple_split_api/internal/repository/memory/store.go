@@ -0,0 +1,100 @@
+// Package memory keeps users, groups, events and webhooks in process memory. It
+// follows the behaviour of the Postgres repositories, including their errors,
+// ordering and foreign keys, so it can stand in for them in tests and in the
+// zero-dependency development mode. Nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+// Store holds the data shared by the stores it hands out. It is safe for concurrent use.
+type Store struct {
+	mu         sync.RWMutex
+	users      map[string]*domain.User
+	groups     map[string]*domain.Group
+	events     map[string]*domain.Event
+	webhooks   map[string]*domain.Webhook
+	deliveries map[string]*domain.WebhookDelivery
+	// idempotencyKeys is keyed by the Idempotency-Key header value
+	idempotencyKeys map[string]*domain.IdempotencyKey
+	// lastTime is the most recent timestamp handed out by now
+	lastTime time.Time
+	// tx is held by the unit of work running in InTx
+	tx sync.Mutex
+}
+
+// txKey marks the context of a unit of work running in InTx
+type txKey struct {
+	s *Store
+}
+
+// New creates an empty store
+func New() *Store {
+	return &Store{
+		users:           make(map[string]*domain.User),
+		groups:          make(map[string]*domain.Group),
+		events:          make(map[string]*domain.Event),
+		webhooks:        make(map[string]*domain.Webhook),
+		deliveries:      make(map[string]*domain.WebhookDelivery),
+		idempotencyKeys: make(map[string]*domain.IdempotencyKey),
+	}
+}
+
+// Stores returns the user, group, event and webhook stores backed by s
+func (s *Store) Stores() repository.Stores {
+	return repository.Stores{
+		Users:       &UserStore{s},
+		Groups:      &GroupStore{s},
+		Events:      &EventStore{s},
+		Webhooks:    &WebhookStore{s},
+		Idempotency: &IdempotencyStore{s},
+		Tx:          s,
+	}
+}
+
+// InTx runs fn after any other unit of work has finished, so units of work do not
+// interleave. Calls made outside of one are not held back. There is no rollback:
+// the writes fn made before failing are kept.
+func (s *Store) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{s}) != nil {
+		return fn(ctx)
+	}
+
+	s.tx.Lock()
+	defer s.tx.Unlock()
+	return fn(context.WithValue(ctx, txKey{s}, true))
+}
+
+// now returns the current time at the microsecond precision of Postgres. Every
+// call returns a later time than the one before, so rows ordered by creation
+// time keep their insertion order. The caller must hold the write lock.
+func (s *Store) now() time.Time {
+	now := time.Now().Truncate(time.Microsecond)
+	if !now.After(s.lastTime) {
+		now = s.lastTime.Add(time.Microsecond)
+	}
+	s.lastTime = now
+	return now
+}
+
+// sortByCreatedAt orders rows by creation time, oldest first when ascending
+func sortByCreatedAt[T any](rows []T, createdAt func(T) time.Time, ascending bool) {
+	slices.SortStableFunc(rows, func(a, b T) int {
+		if ascending {
+			return createdAt(a).Compare(createdAt(b))
+		}
+		return createdAt(b).Compare(createdAt(a))
+	})
+}
+
+// limitRows returns at most n rows
+func limitRows[T any](rows []T, n int) []T {
+	return rows[:min(len(rows), max(n, 0))]
+}
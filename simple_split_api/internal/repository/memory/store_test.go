@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/repository/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repository.Stores {
+		return New().Stores()
+	})
+}
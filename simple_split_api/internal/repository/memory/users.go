@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+// UserStore keeps users in memory
+type UserStore struct {
+	s *Store
+}
+
+var _ repository.UserStore = (*UserStore)(nil)
+
+// Create adds a new user, setting its creation time and its ID unless one is set.
+// A user with the same ID is a conflict.
+func (r *UserStore) Create(ctx context.Context, user *domain.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	userID, err := repository.IDOrNew("user", user.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	if _, ok := r.s.users[userID]; ok {
+		return fmt.Errorf("user %w: %s already exists", repository.ErrConflict, userID)
+	}
+
+	stored := &domain.User{
+		UserID:           userID,
+		Name:             user.Name,
+		FirebaseID:       user.FirebaseID,
+		Email:            user.Email,
+		UnsubscribeToken: user.UnsubscribeToken,
+	}
+	if err := r.checkUnique(stored); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	stored.CreatedAt = r.s.now()
+	r.s.users[stored.UserID] = stored
+	user.UserID, user.CreatedAt = stored.UserID, stored.CreatedAt
+	return nil
+}
+
+// GetByID retrieves a user by ID
+func (r *UserStore) GetByID(ctx context.Context, userID string) (*domain.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	user, err := r.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return copyUser(user), nil
+}
+
+// GetAll retrieves all users, newest first
+func (r *UserStore) GetAll(ctx context.Context) ([]*domain.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	var users []*domain.User
+	for _, user := range r.s.users {
+		users = append(users, copyUser(user))
+	}
+	sortByCreatedAt(users, func(u *domain.User) time.Time { return u.CreatedAt }, false)
+	return users, nil
+}
+
+// Update updates a user's name and Firebase ID
+func (r *UserStore) Update(ctx context.Context, user *domain.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, err := r.get(user.UserID)
+	if err != nil {
+		return err
+	}
+
+	updated := *stored
+	updated.Name, updated.FirebaseID = user.Name, user.FirebaseID
+	if err := r.checkUnique(&updated); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	*stored = updated
+	return nil
+}
+
+// GetByFirebaseID retrieves a user by their Firebase ID
+func (r *UserStore) GetByFirebaseID(ctx context.Context, firebaseID string) (*domain.User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	for _, user := range r.s.users {
+		if user.FirebaseID.Valid && user.FirebaseID.String == firebaseID {
+			return copyUser(user), nil
+		}
+	}
+	return nil, fmt.Errorf("user with firebase ID %w: %s", repository.ErrNotFound, firebaseID)
+}
+
+// UpdateFirebaseID updates only a user's Firebase ID, an empty ID clears it
+func (r *UserStore) UpdateFirebaseID(ctx context.Context, userID string, firebaseID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, err := r.get(userID)
+	if err != nil {
+		return err
+	}
+
+	updated := *stored
+	updated.FirebaseID = sql.NullString{String: firebaseID, Valid: firebaseID != ""}
+	if err := r.checkUnique(&updated); err != nil {
+		return fmt.Errorf("failed to update firebase ID: %w", err)
+	}
+	*stored = updated
+	return nil
+}
+
+// UpdateEmail sets a user's email address together with a fresh unsubscribe token.
+// Setting an address re-subscribes the user; an empty address removes it.
+func (r *UserStore) UpdateEmail(ctx context.Context, userID string, email string, unsubscribeToken string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, err := r.get(userID)
+	if err != nil {
+		return err
+	}
+
+	updated := *stored
+	updated.Email = sql.NullString{String: email, Valid: email != ""}
+	updated.UnsubscribeToken = sql.NullString{String: unsubscribeToken, Valid: email != ""}
+	updated.EmailUnsubscribed = false
+	if err := r.checkUnique(&updated); err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+	*stored = updated
+	return nil
+}
+
+// UnsubscribeEmail stops email notifications for the user owning the unsubscribe token
+func (r *UserStore) UnsubscribeEmail(ctx context.Context, unsubscribeToken string) (*domain.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.users {
+		if user.UnsubscribeToken.Valid && user.UnsubscribeToken.String == unsubscribeToken {
+			user.EmailUnsubscribed = true
+			return copyUser(user), nil
+		}
+	}
+	return nil, fmt.Errorf("unsubscribe token %w", repository.ErrNotFound)
+}
+
+// Delete removes a user. Users referenced by events or webhooks are kept.
+func (r *UserStore) Delete(ctx context.Context, userID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, err := r.get(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range r.s.events {
+		if event.UserID == user.UserID {
+			return fmt.Errorf("failed to delete user: %w: user %s is referenced by event %s", repository.ErrValidation, user.UserID, event.EventID)
+		}
+	}
+	for _, webhook := range r.s.webhooks {
+		if webhook.CreatedBy == user.UserID {
+			return fmt.Errorf("failed to delete user: %w: user %s is referenced by webhook %s", repository.ErrValidation, user.UserID, webhook.WebhookID)
+		}
+	}
+
+	delete(r.s.users, user.UserID)
+	return nil
+}
+
+// get returns the stored user, the caller must hold the lock
+func (r *UserStore) get(userID string) (*domain.User, error) {
+	id, err := repository.ParseID("user", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	user, ok := r.s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user %w: %s", repository.ErrNotFound, userID)
+	}
+	return user, nil
+}
+
+// checkUnique enforces the unique Firebase ID and unsubscribe token indexes,
+// the caller must hold the lock
+func (r *UserStore) checkUnique(user *domain.User) error {
+	for _, other := range r.s.users {
+		if other.UserID == user.UserID {
+			continue
+		}
+		if user.FirebaseID.Valid && other.FirebaseID.Valid && user.FirebaseID.String == other.FirebaseID.String {
+			return fmt.Errorf("%w: firebase ID is already used by user %s", repository.ErrConflict, other.UserID)
+		}
+		if user.UnsubscribeToken.Valid && other.UnsubscribeToken.Valid && user.UnsubscribeToken.String == other.UnsubscribeToken.String {
+			return fmt.Errorf("%w: unsubscribe token is already used by user %s", repository.ErrConflict, other.UserID)
+		}
+	}
+	return nil
+}
+
+func copyUser(user *domain.User) *domain.User {
+	copied := *user
+	return &copied
+}
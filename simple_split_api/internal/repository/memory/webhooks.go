@@ -0,0 +1,260 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+// WebhookStore keeps webhooks and their deliveries in memory
+type WebhookStore struct {
+	s *Store
+}
+
+var _ repository.WebhookStore = (*WebhookStore)(nil)
+
+// Create adds a new webhook, setting its ID and creation time
+func (r *WebhookStore) Create(ctx context.Context, webhook *domain.Webhook) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	groupID, err := repository.ParseID("group", webhook.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	createdBy, err := repository.ParseID("user", webhook.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	if _, ok := r.s.groups[groupID]; !ok {
+		return fmt.Errorf("failed to create webhook: %w: group %s does not exist", repository.ErrValidation, webhook.GroupID)
+	}
+	if _, ok := r.s.users[createdBy]; !ok {
+		return fmt.Errorf("failed to create webhook: %w: user %s does not exist", repository.ErrValidation, webhook.CreatedBy)
+	}
+
+	stored := &domain.Webhook{
+		WebhookID: repository.NewID(),
+		GroupID:   groupID,
+		CreatedBy: createdBy,
+		URL:       webhook.URL,
+		Secret:    webhook.Secret,
+		Active:    webhook.Active,
+		CreatedAt: r.s.now(),
+	}
+	r.s.webhooks[stored.WebhookID] = stored
+	webhook.WebhookID, webhook.CreatedAt = stored.WebhookID, stored.CreatedAt
+	return nil
+}
+
+// GetByID retrieves a webhook by ID
+func (r *WebhookStore) GetByID(ctx context.Context, webhookID string) (*domain.Webhook, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	webhook, err := r.get(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return copyWebhook(webhook), nil
+}
+
+// GetByGroupID retrieves the webhooks of a group, oldest first
+func (r *WebhookStore) GetByGroupID(ctx context.Context, groupID string) ([]*domain.Webhook, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+
+	webhooks := make([]*domain.Webhook, 0)
+	for _, webhook := range r.s.webhooks {
+		if webhook.GroupID == id {
+			webhooks = append(webhooks, copyWebhook(webhook))
+		}
+	}
+	sortByCreatedAt(webhooks, func(w *domain.Webhook) time.Time { return w.CreatedAt }, true)
+	return webhooks, nil
+}
+
+// RotateSecret replaces the signing secret, keeping the previous one valid until graceUntil
+func (r *WebhookStore) RotateSecret(ctx context.Context, webhookID string, secret string, graceUntil time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	webhook, err := r.get(webhookID)
+	if err != nil {
+		return err
+	}
+	webhook.PreviousSecret = sql.NullString{String: webhook.Secret, Valid: true}
+	webhook.PreviousSecretExpiresAt = sql.NullTime{Time: graceUntil, Valid: true}
+	webhook.Secret = secret
+	return nil
+}
+
+// SetActive turns deliveries to a webhook on or off
+func (r *WebhookStore) SetActive(ctx context.Context, webhookID string, active bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	webhook, err := r.get(webhookID)
+	if err != nil {
+		return err
+	}
+	webhook.Active = active
+	return nil
+}
+
+// Delete removes a webhook with its deliveries
+func (r *WebhookStore) Delete(ctx context.Context, webhookID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	webhook, err := r.get(webhookID)
+	if err != nil {
+		return err
+	}
+	deleteWebhook(r.s, webhook.WebhookID)
+	return nil
+}
+
+// CreateDelivery records a delivery of an event to a webhook, setting its ID and timestamps
+func (r *WebhookStore) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	webhookID, err := repository.ParseID("webhook", delivery.WebhookID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	eventID, err := repository.ParseID("event", delivery.EventID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	if _, ok := r.s.webhooks[webhookID]; !ok {
+		return fmt.Errorf("failed to create webhook delivery: %w: webhook %s does not exist", repository.ErrValidation, delivery.WebhookID)
+	}
+	if !json.Valid(delivery.Payload) {
+		return fmt.Errorf("failed to create webhook delivery: %w: payload is not valid JSON", repository.ErrValidation)
+	}
+
+	now := r.s.now()
+	stored := &domain.WebhookDelivery{
+		DeliveryID: repository.NewID(),
+		WebhookID:  webhookID,
+		EventID:    eventID,
+		Payload:    slices.Clone(delivery.Payload),
+		Status:     delivery.Status,
+		ReplayOf:   delivery.ReplayOf,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	r.s.deliveries[stored.DeliveryID] = stored
+	delivery.DeliveryID, delivery.CreatedAt, delivery.UpdatedAt = stored.DeliveryID, now, now
+	return nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *WebhookStore) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, err := r.getDelivery(delivery.DeliveryID)
+	if err != nil {
+		return err
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.ResponseStatus = delivery.ResponseStatus
+	stored.LastError = delivery.LastError
+	stored.UpdatedAt = r.s.now()
+	return nil
+}
+
+// GetDeliveryByID retrieves a webhook delivery by ID
+func (r *WebhookStore) GetDeliveryByID(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	delivery, err := r.getDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	return copyDelivery(delivery), nil
+}
+
+// GetDeliveriesByWebhookID retrieves the latest deliveries of a webhook, newest first
+func (r *WebhookStore) GetDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	for _, delivery := range r.s.deliveries {
+		if delivery.WebhookID == id {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+	sortByCreatedAt(deliveries, func(d *domain.WebhookDelivery) time.Time { return d.CreatedAt }, false)
+	return limitRows(deliveries, limit), nil
+}
+
+// get returns the stored webhook, the caller must hold the lock
+func (r *WebhookStore) get(webhookID string) (*domain.Webhook, error) {
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	webhook, ok := r.s.webhooks[id]
+	if !ok {
+		return nil, fmt.Errorf("webhook %w: %s", repository.ErrNotFound, webhookID)
+	}
+	return webhook, nil
+}
+
+// getDelivery returns the stored delivery, the caller must hold the lock
+func (r *WebhookStore) getDelivery(deliveryID string) (*domain.WebhookDelivery, error) {
+	id, err := repository.ParseID("webhook delivery", deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	delivery, ok := r.s.deliveries[id]
+	if !ok {
+		return nil, fmt.Errorf("webhook delivery %w: %s", repository.ErrNotFound, deliveryID)
+	}
+	return delivery, nil
+}
+
+// deleteWebhook removes a webhook and cascades to its deliveries, the caller
+// must hold the write lock
+func deleteWebhook(s *Store, webhookID string) {
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+	delete(s.webhooks, webhookID)
+}
+
+func copyWebhook(webhook *domain.Webhook) *domain.Webhook {
+	copied := *webhook
+	return &copied
+}
+
+func copyDelivery(delivery *domain.WebhookDelivery) *domain.WebhookDelivery {
+	copied := *delivery
+	copied.Payload = slices.Clone(delivery.Payload)
+	return &copied
+}
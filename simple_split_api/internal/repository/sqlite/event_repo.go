@@ -0,0 +1,376 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+)
+
+// eventColumns are the columns scanned by scanEvent
+const eventColumns = `event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at`
+
+// expenseEventTypes lists the event types of an expense's revision chain in SQL,
+// those for which util.EventType.IsExpense is true
+const expenseEventTypes = `('EXPENSE_CREATED', 'EXPENSE_UPDATED', 'EXPENSE_DELETED', 'EXPENSE_RESTORED')`
+
+// currencyEventTypes lists the event types that change a group's currencies in SQL,
+// those for which util.EventType.IsCurrencyChange is true
+const currencyEventTypes = `('GROUP_ADD_CURRENCY', 'GROUP_REMOVE_CURRENCY', 'GROUP_SET_DEFAULT_CURRENCY')`
+
+// currencyEventsFilter selects the events GetCurrencyEvents returns of the group $1
+const currencyEventsFilter = `
+	group_id = $1 AND (event_type IN ` + currencyEventTypes + ` OR (
+		event_type IN ('EXPENSE_CREATED', 'EXPENSE_UPDATED') AND hlc < (
+			SELECT MIN(hlc) FROM events WHERE group_id = $1 AND event_type IN ` + currencyEventTypes + `)))`
+
+// EventRepository handles SQLite operations for events
+type EventRepository struct {
+	DB *util.Database
+}
+
+var _ repository.EventStore = (*EventRepository)(nil)
+
+// NewEventRepository creates a new event repository
+func NewEventRepository(db *util.Database) *EventRepository {
+	return &EventRepository{
+		DB: db,
+	}
+}
+
+// Create adds a new event, setting its creation time, and its HLC to that time
+// unless one is set. An event with the same ID is a conflict and leaves the
+// stored event unchanged.
+func (r *EventRepository) Create(ctx context.Context, event *domain.Event) error {
+	args, err := eventArgs(event)
+	if err != nil {
+		return fmt.Errorf("failed to create event: %w", err)
+	}
+
+	query := `
+		INSERT INTO events (event_id, linked_event_id, group_id, user_id, event_type, payload, hlc, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id) DO NOTHING
+	`
+
+	createdAt := now()
+	stamp := event.HLC
+	if stamp.IsZero() {
+		stamp = hlc.HLC{Wall: createdAt.UnixMilli()}
+	}
+	result, err := r.DB.ExecContext(ctx, query, append(args, stamp.String(), createdAt)...)
+	if err != nil {
+		return fmt.Errorf("failed to create event: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return fmt.Errorf("event %w: %s already exists", repository.ErrConflict, event.EventID)
+	}
+
+	event.HLC, event.CreatedAt = stamp, createdAt
+	return nil
+}
+
+// GetByID retrieves an event by ID
+func (r *EventRepository) GetByID(ctx context.Context, eventID string) (*domain.Event, error) {
+	id, err := repository.ParseID("event", eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events WHERE event_id = $1`
+	event, err := scanEvent(r.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+		}
+		return nil, fmt.Errorf("failed to get event: %w", classify(err))
+	}
+
+	return event, nil
+}
+
+// GetByGroupID retrieves all events for a group, latest HLC first
+func (r *EventRepository) GetByGroupID(ctx context.Context, groupID string) ([]*domain.Event, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events WHERE group_id = $1 ORDER BY hlc DESC, created_at DESC, event_id DESC`
+	return r.query(ctx, query, id)
+}
+
+// GetEventsByGroupAfterID retrieves events for a group with pagination support
+// If afterEventID is "0", it returns the first batch of events
+// Results are ordered by arrival (ascending by created_at), so events uploaded after
+// a page was read are on a later page even when their HLC is older
+func (r *EventRepository) GetEventsByGroupAfterID(ctx context.Context, groupID string, afterEventID string, limit int) ([]*domain.Event, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	var events []*domain.Event
+	if afterEventID == "0" {
+		query := `
+			SELECT ` + eventColumns + `
+			FROM events
+			WHERE group_id = $1
+			ORDER BY created_at ASC
+			LIMIT $2
+		`
+		events, err = r.query(ctx, query, id, limit)
+	} else {
+		var afterID string
+		if afterID, err = repository.ParseID("event", afterEventID); err != nil {
+			return nil, fmt.Errorf("failed to query events: %w", err)
+		}
+
+		query := `
+			SELECT e.event_id, e.linked_event_id, e.group_id, e.user_id, e.event_type, e.payload, e.hlc, e.created_at
+			FROM events e
+			JOIN events after_event ON after_event.event_id = $2
+			WHERE e.group_id = $1
+			  AND e.created_at > after_event.created_at
+			ORDER BY e.created_at ASC
+			LIMIT $3
+		`
+		events, err = r.query(ctx, query, id, afterID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if events == nil {
+		events = make([]*domain.Event, 0)
+	}
+	return events, nil
+}
+
+// GetFirstByGroupAndType retrieves the earliest event of a given type in a group
+func (r *EventRepository) GetFirstByGroupAndType(ctx context.Context, groupID string, eventType util.EventType) (*domain.Event, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE group_id = $1 AND event_type = $2
+		ORDER BY created_at ASC
+		LIMIT 1
+	`
+
+	event, err := scanEvent(r.DB.QueryRowContext(ctx, query, id, string(eventType)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s event %w for group: %s", eventType, repository.ErrNotFound, groupID)
+		}
+		return nil, fmt.Errorf("failed to get event: %w", classify(err))
+	}
+
+	return event, nil
+}
+
+// GetChain retrieves the EXPENSE_* events of a group that are eventID or link to
+// it, in arrival order. It returns ErrNotFound when there are none.
+func (r *EventRepository) GetChain(ctx context.Context, groupID string, eventID string) ([]*domain.Event, error) {
+	gid, id, err := chainIDs(groupID, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE group_id = $1 AND (event_id = $2 OR linked_event_id = $2) AND event_type IN ` + expenseEventTypes + `
+		ORDER BY created_at ASC, event_id ASC
+	`
+
+	events, err := r.query(ctx, query, gid, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+	}
+	return events, nil
+}
+
+// GetChainHead retrieves the latest event of the chain GetChain returns. Events
+// are ordered by arrival, so the head is the last edit the server accepted.
+func (r *EventRepository) GetChainHead(ctx context.Context, groupID string, eventID string) (*domain.Event, error) {
+	gid, id, err := chainIDs(groupID, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE group_id = $1 AND (event_id = $2 OR linked_event_id = $2) AND event_type IN ` + expenseEventTypes + `
+		ORDER BY created_at DESC, event_id DESC
+		LIMIT 1
+	`
+
+	event, err := scanEvent(r.DB.QueryRowContext(ctx, query, gid, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("event %w: %s", repository.ErrNotFound, eventID)
+		}
+		return nil, fmt.Errorf("failed to get event: %w", classify(err))
+	}
+
+	return event, nil
+}
+
+// GetCurrencyEvents retrieves the currency events of a group, and the EXPENSE_CREATED
+// and EXPENSE_UPDATED events ordered before the first of them by HLC, latest HLC first
+func (r *EventRepository) GetCurrencyEvents(ctx context.Context, groupID string) ([]*domain.Event, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+
+	query := `SELECT ` + eventColumns + ` FROM events WHERE ` + currencyEventsFilter + ` ORDER BY hlc DESC, created_at DESC, event_id DESC`
+	return r.query(ctx, query, id)
+}
+
+// chainIDs parses the group and event IDs naming a chain
+func chainIDs(groupID string, eventID string) (string, string, error) {
+	gid, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return "", "", err
+	}
+	id, err := repository.ParseID("event", eventID)
+	if err != nil {
+		return "", "", err
+	}
+	return gid, id, nil
+}
+
+// GetAll retrieves all events, newest first
+func (r *EventRepository) GetAll(ctx context.Context) ([]*domain.Event, error) {
+	return r.query(ctx, `SELECT `+eventColumns+` FROM events ORDER BY created_at DESC`)
+}
+
+// Update updates an event's group, user, type and payload
+func (r *EventRepository) Update(ctx context.Context, event *domain.Event) error {
+	args, err := eventArgs(event)
+	if err != nil {
+		return fmt.Errorf("failed to update event: %w", err)
+	}
+
+	query := `
+		UPDATE events
+		SET group_id = $1, user_id = $2, event_type = $3, payload = $4
+		WHERE event_id = $5
+	`
+
+	// The linked event ID is not updated
+	result, err := r.DB.ExecContext(ctx, query, args[2], args[3], args[4], args[5], args[0])
+	if err != nil {
+		return fmt.Errorf("failed to update event: %w", classify(err))
+	}
+
+	return requireRow(result, "event", event.EventID)
+}
+
+// Delete removes an event
+func (r *EventRepository) Delete(ctx context.Context, eventID string) error {
+	id, err := repository.ParseID("event", eventID)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM events WHERE event_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", classify(err))
+	}
+
+	return requireRow(result, "event", eventID)
+}
+
+// query runs a query returning event rows
+func (r *EventRepository) query(ctx context.Context, query string, args ...interface{}) ([]*domain.Event, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var events []*domain.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// eventArgs returns the event ID, linked event ID, group ID, user ID, type and
+// payload of an event as statement parameters, checking the IDs are UUIDs
+func eventArgs(event *domain.Event) ([]interface{}, error) {
+	eventID, err := repository.ParseID("event", event.EventID)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := repository.ParseID("group", event.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := repository.ParseID("user", event.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var linkedEventID interface{}
+	if event.LinkedEventID != "" {
+		if linkedEventID, err = repository.ParseID("linked event", event.LinkedEventID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Payloads are stored as text, a []byte would be stored as a BLOB
+	return []interface{}{eventID, linkedEventID, groupID, userID, string(event.EventType), string(event.Payload)}, nil
+}
+
+func scanEvent(row scanner) (*domain.Event, error) {
+	event := &domain.Event{}
+	var linkedEventID sql.NullString
+	var eventType string
+	var payload []byte
+	err := row.Scan(
+		&event.EventID,
+		&linkedEventID,
+		&event.GroupID,
+		&event.UserID,
+		&eventType,
+		&payload,
+		&event.HLC,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	event.LinkedEventID = linkedEventID.String
+	event.EventType = util.EventType(eventType)
+	event.Payload = payload
+	return event, nil
+}
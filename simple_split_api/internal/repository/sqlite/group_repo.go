@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// GroupRepository handles SQLite operations for groups
+type GroupRepository struct {
+	DB *util.Database
+}
+
+var _ repository.GroupStore = (*GroupRepository)(nil)
+
+// NewGroupRepository creates a new group repository
+func NewGroupRepository(db *util.Database) *GroupRepository {
+	return &GroupRepository{
+		DB: db,
+	}
+}
+
+// Create adds a new group, setting its creation time and its ID unless one is set.
+// A group with the same ID is a conflict.
+func (r *GroupRepository) Create(ctx context.Context, group *domain.Group) error {
+	query := `
+		INSERT INTO groups (group_id, name, created_at)
+		VALUES ($1, $2, $3)
+	`
+
+	groupID, err := repository.IDOrNew("group", group.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+
+	createdAt := now()
+	if _, err = r.DB.ExecContext(ctx, query, groupID, group.Name, createdAt); err != nil {
+		return fmt.Errorf("failed to create group: %w", classify(err))
+	}
+
+	group.GroupID, group.Version, group.CreatedAt = groupID, 0, createdAt
+	return nil
+}
+
+// GetByID retrieves a group by ID
+func (r *GroupRepository) GetByID(ctx context.Context, groupID string) (*domain.Group, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	query := `
+		SELECT group_id, name, version, created_at
+		FROM groups
+		WHERE group_id = $1
+	`
+
+	group := &domain.Group{}
+	err = r.DB.QueryRowContext(ctx, query, id).Scan(&group.GroupID, &group.Name, &group.Version, &group.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("group %w: %s", repository.ErrNotFound, groupID)
+		}
+		return nil, fmt.Errorf("failed to get group: %w", classify(err))
+	}
+
+	return group, nil
+}
+
+// GetForUpdate retrieves a group. Units of work take the database write lock
+// when they begin, so the group is already held against concurrent writes.
+func (r *GroupRepository) GetForUpdate(ctx context.Context, groupID string) (*domain.Group, error) {
+	return r.GetByID(ctx, groupID)
+}
+
+// IncrementVersion records a change to a group and returns its new version
+func (r *GroupRepository) IncrementVersion(ctx context.Context, groupID string) (int64, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment group version: %w", err)
+	}
+
+	var version int64
+	err = r.DB.QueryRowContext(ctx, `UPDATE groups SET version = version + 1 WHERE group_id = $1 RETURNING version`, id).Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("group %w: %s", repository.ErrNotFound, groupID)
+		}
+		return 0, fmt.Errorf("failed to increment group version: %w", classify(err))
+	}
+
+	return version, nil
+}
+
+// GetAll retrieves all groups, newest first
+func (r *GroupRepository) GetAll(ctx context.Context) ([]*domain.Group, error) {
+	query := `
+		SELECT group_id, name, version, created_at
+		FROM groups
+		ORDER BY created_at DESC
+	`
+
+	return r.query(ctx, "failed to query groups", query)
+}
+
+// Update updates a group's name
+func (r *GroupRepository) Update(ctx context.Context, group *domain.Group) error {
+	id, err := repository.ParseID("group", group.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `UPDATE groups SET name = $1 WHERE group_id = $2`, group.Name, id)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", classify(err))
+	}
+
+	return requireRow(result, "group", group.GroupID)
+}
+
+// Delete removes a group with its webhooks. Its events are kept, as in Postgres
+// where events do not reference groups.
+func (r *GroupRepository) Delete(ctx context.Context, groupID string) error {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM groups WHERE group_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", classify(err))
+	}
+
+	return requireRow(result, "group", groupID)
+}
+
+// GetByUserID retrieves the groups a user has created events in, newest first
+func (r *GroupRepository) GetByUserID(ctx context.Context, userID string) ([]*domain.Group, error) {
+	id, err := repository.ParseID("user", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups by user ID: %w", err)
+	}
+
+	query := `
+		SELECT g.group_id, g.name, g.version, g.created_at
+		FROM groups g
+		INNER JOIN (
+			SELECT DISTINCT group_id
+			FROM events
+			WHERE user_id = $1
+		) e ON g.group_id = e.group_id
+		ORDER BY g.created_at DESC
+	`
+
+	return r.query(ctx, "failed to query groups by user ID", query, id)
+}
+
+// query runs a query returning group rows
+func (r *GroupRepository) query(ctx context.Context, failure string, query string, args ...interface{}) ([]*domain.Group, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", failure, classify(err))
+	}
+	defer rows.Close()
+
+	var groups []*domain.Group
+	for rows.Next() {
+		group := &domain.Group{}
+		if err := rows.Scan(&group.GroupID, &group.Name, &group.Version, &group.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group row: %w", err)
+		}
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating group rows: %w", err)
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// IdempotencyRepository handles SQLite operations for idempotency keys
+type IdempotencyRepository struct {
+	DB *util.Database
+}
+
+var _ repository.IdempotencyStore = (*IdempotencyRepository)(nil)
+
+// NewIdempotencyRepository creates a new idempotency key repository
+func NewIdempotencyRepository(db *util.Database) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		DB: db,
+	}
+}
+
+// Create claims an idempotency key, removing expired and abandoned keys first
+func (r *IdempotencyRepository) Create(ctx context.Context, key *domain.IdempotencyKey) error {
+	createdAt := now()
+	_, err := r.DB.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= $1 OR (response_status = 0 AND lease_until <= $1)
+	`, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", classify(err))
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (idempotency_key, claim_id, fingerprint, created_at, expires_at, lease_until)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (idempotency_key) DO NOTHING
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, key.Key, key.ClaimID, key.Fingerprint, createdAt, key.ExpiresAt.UTC(), key.LeaseUntil.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency key: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		return fmt.Errorf("idempotency key %w: %s already exists", repository.ErrConflict, key.Key)
+	}
+
+	key.CreatedAt = createdAt
+	return nil
+}
+
+// GetByKey retrieves an idempotency key with its stored response
+func (r *IdempotencyRepository) GetByKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	query := `
+		SELECT idempotency_key, claim_id, fingerprint, response_status, response_content_type, response_body, created_at, expires_at, lease_until
+		FROM idempotency_keys
+		WHERE idempotency_key = $1
+	`
+
+	stored := &domain.IdempotencyKey{}
+	err := r.DB.QueryRowContext(ctx, query, key).Scan(
+		&stored.Key,
+		&stored.ClaimID,
+		&stored.Fingerprint,
+		&stored.ResponseStatus,
+		&stored.ResponseContentType,
+		&stored.ResponseBody,
+		&stored.CreatedAt,
+		&stored.ExpiresAt,
+		&stored.LeaseUntil,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("idempotency key %w: %s", repository.ErrNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", classify(err))
+	}
+
+	return stored, nil
+}
+
+// Complete stores the response to the request that claimed the key, unless another
+// claim has taken the key over since
+func (r *IdempotencyRepository) Complete(ctx context.Context, key *domain.IdempotencyKey) error {
+	query := `
+		UPDATE idempotency_keys
+		SET response_status = $1, response_content_type = $2, response_body = $3
+		WHERE idempotency_key = $4 AND claim_id = $5
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, key.ResponseStatus, key.ResponseContentType, key.ResponseBody, key.Key, key.ClaimID)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", classify(err))
+	}
+
+	return requireRow(result, "idempotency key", key.Key)
+}
+
+// Delete releases an idempotency key held by the claim of key
+func (r *IdempotencyRepository) Delete(ctx context.Context, key *domain.IdempotencyKey) error {
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE idempotency_key = $1 AND claim_id = $2`, key.Key, key.ClaimID)
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", classify(err))
+	}
+
+	return requireRow(result, "idempotency key", key.Key)
+}
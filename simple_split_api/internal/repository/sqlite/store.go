@@ -0,0 +1,85 @@
+// Package sqlite stores users, groups, events and webhooks in a SQLite file, for
+// self-hosting without Postgres. The schema in internal/migrations/sqlite mirrors
+// the Postgres one; since SQLite has no uuidv7() or now() column defaults, IDs and
+// timestamps are generated here, and payloads are kept as JSON text.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// System names SQLite in query spans
+const System = "sqlite"
+
+// Open opens the database file at path, creating it if needed. Foreign keys are
+// enforced, and write-ahead logging with a busy timeout lets readers carry on
+// while a write is in progress.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	// Take the write lock when a transaction begins rather than failing halfway through it
+	params.Set("_txlock", "immediate")
+	// Store timestamps in the format SQLite's date functions understand
+	params.Set("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// NewStores returns the stores backed by the SQLite database
+func NewStores(db *util.Database) repository.Stores {
+	return repository.Stores{
+		Users:       NewUserRepository(db),
+		Groups:      NewGroupRepository(db),
+		Events:      NewEventRepository(db),
+		Webhooks:    NewWebhookRepository(db),
+		Idempotency: NewIdempotencyRepository(db),
+		// The connection takes the write lock when a transaction begins, so units of work are serializable
+		Tx: db,
+	}
+}
+
+// now returns the current time in UTC at microsecond precision, like a Postgres
+// TIMESTAMPTZ. Stored as text in a single zone, timestamps sort in time order.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// nullable stores an empty string as NULL
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// classify tags SQLite errors caused by the values in the request with
+// ErrConflict or ErrValidation, other errors are returned unchanged
+func classify(err error) error {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return fmt.Errorf("%w: %w", repository.ErrConflict, err)
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY, sqlite3.SQLITE_CONSTRAINT_NOTNULL, sqlite3.SQLITE_CONSTRAINT_CHECK:
+		return fmt.Errorf("%w: %w", repository.ErrValidation, err)
+	}
+	return err
+}
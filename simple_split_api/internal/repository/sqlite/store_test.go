@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/migrations"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/repository/storetest"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// newStores returns stores on a fresh, migrated database file
+func newStores(t *testing.T) repository.Stores {
+	db, err := Open(filepath.Join(t.TempDir(), "simplesplit.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, migrations.SQLite)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), false); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return NewStores(&util.Database{DB: db, System: System})
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, newStores)
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	stores := newStores(t)
+	errFailed := errors.New("failed")
+
+	rolledBack := domain.NewGroup("Rolled back")
+	err := stores.Tx.InTx(ctx, func(ctx context.Context) error {
+		if err := stores.Groups.Create(ctx, rolledBack); err != nil {
+			return err
+		}
+		// Reads in the unit of work see its writes
+		if _, err := stores.Groups.GetByID(ctx, rolledBack.GroupID); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("InTx returned %v, want the error of fn", err)
+	}
+	if _, err := stores.Groups.GetByID(ctx, rolledBack.GroupID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("group written by a failed unit of work: got %v, want ErrNotFound", err)
+	}
+
+	// A nested unit of work joins the outer one and commits with it
+	committed := domain.NewGroup("Committed")
+	err = stores.Tx.InTx(ctx, func(ctx context.Context) error {
+		return stores.Tx.InTx(ctx, func(ctx context.Context) error {
+			return stores.Groups.Create(ctx, committed)
+		})
+	})
+	if err != nil {
+		t.Fatalf("InTx: %v", err)
+	}
+	if _, err := stores.Groups.GetByID(ctx, committed.GroupID); err != nil {
+		t.Errorf("group written by a committed unit of work: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// userColumns are the columns scanned by scanUser
+const userColumns = `user_id, name, firebase_id, email, email_unsubscribed, unsubscribe_token, created_at`
+
+// UserRepository handles SQLite operations for users
+type UserRepository struct {
+	DB *util.Database
+}
+
+var _ repository.UserStore = (*UserRepository)(nil)
+
+// NewUserRepository creates a new user repository
+func NewUserRepository(db *util.Database) *UserRepository {
+	return &UserRepository{
+		DB: db,
+	}
+}
+
+// Create adds a new user, setting its creation time and its ID unless one is set.
+// A user with the same ID is a conflict.
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+	query := `
+		INSERT INTO users (user_id, name, firebase_id, email, unsubscribe_token, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	userID, err := repository.IDOrNew("user", user.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	createdAt := now()
+	_, err = r.DB.ExecContext(ctx, query, userID, user.Name, user.FirebaseID, user.Email, user.UnsubscribeToken, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", classify(err))
+	}
+
+	user.UserID, user.CreatedAt = userID, createdAt
+	return nil
+}
+
+// GetByID retrieves a user by ID
+func (r *UserRepository) GetByID(ctx context.Context, userID string) (*domain.User, error) {
+	id, err := repository.ParseID("user", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE user_id = $1`
+	user, err := scanUser(r.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user %w: %s", repository.ErrNotFound, userID)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", classify(err))
+	}
+
+	return user, nil
+}
+
+// GetAll retrieves all users, newest first
+func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY created_at DESC`
+
+	rows, err := r.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// Update updates a user's name and Firebase ID
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+	query := `
+		UPDATE users
+		SET name = $1, firebase_id = $2
+		WHERE user_id = $3
+	`
+
+	return r.update(ctx, "failed to update user", user.UserID, query, user.Name, user.FirebaseID)
+}
+
+// GetByFirebaseID retrieves a user by their Firebase ID
+func (r *UserRepository) GetByFirebaseID(ctx context.Context, firebaseID string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE firebase_id = $1`
+
+	user, err := scanUser(r.DB.QueryRowContext(ctx, query, firebaseID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("user with firebase ID %w: %s", repository.ErrNotFound, firebaseID)
+		}
+		return nil, fmt.Errorf("failed to get user by firebase ID: %w", classify(err))
+	}
+
+	return user, nil
+}
+
+// UpdateFirebaseID updates only a user's Firebase ID, an empty ID clears it
+func (r *UserRepository) UpdateFirebaseID(ctx context.Context, userID string, firebaseID string) error {
+	query := `
+		UPDATE users
+		SET firebase_id = $1
+		WHERE user_id = $2
+	`
+
+	return r.update(ctx, "failed to update firebase ID", userID, query, nullable(firebaseID))
+}
+
+// UpdateEmail sets a user's email address together with a fresh unsubscribe token.
+// Setting an address re-subscribes the user; an empty address removes it.
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID string, email string, unsubscribeToken string) error {
+	var token interface{}
+	if email != "" {
+		token = unsubscribeToken
+	}
+
+	query := `
+		UPDATE users
+		SET email = $1, unsubscribe_token = $2, email_unsubscribed = FALSE
+		WHERE user_id = $3
+	`
+
+	return r.update(ctx, "failed to update email", userID, query, nullable(email), token)
+}
+
+// UnsubscribeEmail stops email notifications for the user owning the unsubscribe token
+func (r *UserRepository) UnsubscribeEmail(ctx context.Context, unsubscribeToken string) (*domain.User, error) {
+	query := `
+		UPDATE users
+		SET email_unsubscribed = TRUE
+		WHERE unsubscribe_token = $1
+		RETURNING ` + userColumns
+
+	user, err := scanUser(r.DB.QueryRowContext(ctx, query, unsubscribeToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("unsubscribe token %w", repository.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to unsubscribe email: %w", classify(err))
+	}
+
+	return user, nil
+}
+
+// Delete removes a user. Users referenced by events or webhooks are kept.
+func (r *UserRepository) Delete(ctx context.Context, userID string) error {
+	id, err := repository.ParseID("user", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM users WHERE user_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", classify(err))
+	}
+
+	return requireRow(result, "user", userID)
+}
+
+// update runs an UPDATE of a single user whose last parameter is the user ID
+func (r *UserRepository) update(ctx context.Context, failure string, userID string, query string, args ...interface{}) error {
+	id, err := repository.ParseID("user", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", failure, err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, query, append(args, id)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", failure, classify(err))
+	}
+
+	return requireRow(result, "user", userID)
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*domain.User, error) {
+	user := &domain.User{}
+	err := row.Scan(
+		&user.UserID,
+		&user.Name,
+		&user.FirebaseID,
+		&user.Email,
+		&user.EmailUnsubscribed,
+		&user.UnsubscribeToken,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// requireRow reports ErrNotFound when a statement changed no rows
+func requireRow(result sql.Result, kind string, id string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s %w: %s", kind, repository.ErrNotFound, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+const (
+	// webhookColumns are the columns scanned by scanWebhook
+	webhookColumns = `webhook_id, group_id, created_by, url, secret, previous_secret, previous_secret_expires_at, active, created_at`
+	// deliveryColumns are the columns scanned by scanDelivery
+	deliveryColumns = `delivery_id, webhook_id, event_id, payload, status, attempts, response_status, last_error, replay_of, created_at, updated_at`
+)
+
+// WebhookRepository handles SQLite operations for webhooks and their deliveries
+type WebhookRepository struct {
+	DB *util.Database
+}
+
+var _ repository.WebhookStore = (*WebhookRepository)(nil)
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *util.Database) *WebhookRepository {
+	return &WebhookRepository{
+		DB: db,
+	}
+}
+
+// Create adds a new webhook, setting its ID and creation time
+func (r *WebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	groupID, err := repository.ParseID("group", webhook.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	createdBy, err := repository.ParseID("user", webhook.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhooks (webhook_id, group_id, created_by, url, secret, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	webhookID, createdAt := repository.NewID(), now()
+	_, err = r.DB.ExecContext(ctx, query, webhookID, groupID, createdBy, webhook.URL, webhook.Secret, webhook.Active, createdAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", classify(err))
+	}
+
+	webhook.WebhookID, webhook.CreatedAt = webhookID, createdAt
+	return nil
+}
+
+// GetByID retrieves a webhook by ID
+func (r *WebhookRepository) GetByID(ctx context.Context, webhookID string) (*domain.Webhook, error) {
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE webhook_id = $1`
+	webhook, err := scanWebhook(r.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook %w: %s", repository.ErrNotFound, webhookID)
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", classify(err))
+	}
+
+	return webhook, nil
+}
+
+// GetByGroupID retrieves the webhooks of a group, oldest first
+func (r *WebhookRepository) GetByGroupID(ctx context.Context, groupID string) ([]*domain.Webhook, error) {
+	id, err := repository.ParseID("group", groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE group_id = $1 ORDER BY created_at ASC`
+	rows, err := r.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var webhooks = make([]*domain.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook row: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook rows: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// RotateSecret replaces the signing secret, keeping the previous one valid until graceUntil
+func (r *WebhookRepository) RotateSecret(ctx context.Context, webhookID string, secret string, graceUntil time.Time) error {
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+
+	query := `
+		UPDATE webhooks
+		SET previous_secret = secret, previous_secret_expires_at = $1, secret = $2
+		WHERE webhook_id = $3
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, graceUntil.UTC(), secret, id)
+	if err != nil {
+		return fmt.Errorf("failed to rotate webhook secret: %w", classify(err))
+	}
+
+	return requireRow(result, "webhook", webhookID)
+}
+
+// SetActive turns deliveries to a webhook on or off
+func (r *WebhookRepository) SetActive(ctx context.Context, webhookID string, active bool) error {
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `UPDATE webhooks SET active = $1 WHERE webhook_id = $2`, active, id)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", classify(err))
+	}
+
+	return requireRow(result, "webhook", webhookID)
+}
+
+// Delete removes a webhook with its deliveries
+func (r *WebhookRepository) Delete(ctx context.Context, webhookID string) error {
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	result, err := r.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE webhook_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", classify(err))
+	}
+
+	return requireRow(result, "webhook", webhookID)
+}
+
+// CreateDelivery records a delivery of an event to a webhook, setting its ID and timestamps
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	webhookID, err := repository.ParseID("webhook", delivery.WebhookID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	eventID, err := repository.ParseID("event", delivery.EventID)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (delivery_id, webhook_id, event_id, payload, status, replay_of, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`
+
+	deliveryID, createdAt := repository.NewID(), now()
+	_, err = r.DB.ExecContext(ctx, query,
+		deliveryID,
+		webhookID,
+		eventID,
+		string(delivery.Payload),
+		delivery.Status,
+		delivery.ReplayOf,
+		createdAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", classify(err))
+	}
+
+	delivery.DeliveryID, delivery.CreatedAt, delivery.UpdatedAt = deliveryID, createdAt, createdAt
+	return nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	id, err := repository.ParseID("webhook delivery", delivery.DeliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, last_error = $4, updated_at = $5
+		WHERE delivery_id = $6
+	`
+
+	result, err := r.DB.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		now(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", classify(err))
+	}
+
+	return requireRow(result, "webhook delivery", delivery.DeliveryID)
+}
+
+// GetDeliveryByID retrieves a webhook delivery by ID
+func (r *WebhookRepository) GetDeliveryByID(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	id, err := repository.ParseID("webhook delivery", deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE delivery_id = $1`
+	delivery, err := scanDelivery(r.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook delivery %w: %s", repository.ErrNotFound, deliveryID)
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", classify(err))
+	}
+
+	return delivery, nil
+}
+
+// GetDeliveriesByWebhookID retrieves the latest deliveries of a webhook, newest first
+func (r *WebhookRepository) GetDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	id, err := repository.ParseID("webhook", webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var deliveries = make([]*domain.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery row: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook delivery rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func scanWebhook(row scanner) (*domain.Webhook, error) {
+	webhook := &domain.Webhook{}
+	err := row.Scan(
+		&webhook.WebhookID,
+		&webhook.GroupID,
+		&webhook.CreatedBy,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.PreviousSecret,
+		&webhook.PreviousSecretExpiresAt,
+		&webhook.Active,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func scanDelivery(row scanner) (*domain.WebhookDelivery, error) {
+	delivery := &domain.WebhookDelivery{}
+	var payload []byte
+	err := row.Scan(
+		&delivery.DeliveryID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.ReplayOf,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return delivery, nil
+}
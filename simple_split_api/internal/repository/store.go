@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// UserStore persists users. Implementations return errors wrapping ErrNotFound,
+// ErrConflict and ErrValidation the way the Postgres repositories do.
+type UserStore interface {
+	Create(ctx context.Context, user *domain.User) error
+	GetByID(ctx context.Context, userID string) (*domain.User, error)
+	GetAll(ctx context.Context) ([]*domain.User, error)
+	Update(ctx context.Context, user *domain.User) error
+	Delete(ctx context.Context, userID string) error
+	GetByFirebaseID(ctx context.Context, firebaseID string) (*domain.User, error)
+	UpdateFirebaseID(ctx context.Context, userID string, firebaseID string) error
+	UpdateEmail(ctx context.Context, userID string, email string, unsubscribeToken string) error
+	UnsubscribeEmail(ctx context.Context, unsubscribeToken string) (*domain.User, error)
+}
+
+// GroupStore persists groups
+type GroupStore interface {
+	Create(ctx context.Context, group *domain.Group) error
+	GetByID(ctx context.Context, groupID string) (*domain.Group, error)
+	GetAll(ctx context.Context) ([]*domain.Group, error)
+	Update(ctx context.Context, group *domain.Group) error
+	Delete(ctx context.Context, groupID string) error
+	GetByUserID(ctx context.Context, userID string) ([]*domain.Group, error)
+	// GetForUpdate retrieves a group, holding it against concurrent writes
+	// until the unit of work it is called in ends
+	GetForUpdate(ctx context.Context, groupID string) (*domain.Group, error)
+	// IncrementVersion records a change to the group and returns its new version
+	IncrementVersion(ctx context.Context, groupID string) (int64, error)
+}
+
+// EventStore persists the event log of every group
+type EventStore interface {
+	Create(ctx context.Context, event *domain.Event) error
+	GetByID(ctx context.Context, eventID string) (*domain.Event, error)
+	GetByGroupID(ctx context.Context, groupID string) ([]*domain.Event, error)
+	GetEventsByGroupAfterID(ctx context.Context, groupID string, afterEventID string, limit int) ([]*domain.Event, error)
+	GetFirstByGroupAndType(ctx context.Context, groupID string, eventType util.EventType) (*domain.Event, error)
+	// GetChain retrieves the revision chain of an expense: the EXPENSE_* events of
+	// groupID that are eventID or link to it, in arrival order
+	GetChain(ctx context.Context, groupID string, eventID string) ([]*domain.Event, error)
+	// GetChainHead retrieves the latest event of the chain GetChain returns
+	GetChainHead(ctx context.Context, groupID string, eventID string) (*domain.Event, error)
+	// GetCurrencyEvents retrieves the events of a group services.FoldCurrencies needs:
+	// its currency events, and the EXPENSE_CREATED and EXPENSE_UPDATED events ordered
+	// before the first of them by HLC, latest HLC first
+	GetCurrencyEvents(ctx context.Context, groupID string) ([]*domain.Event, error)
+	GetAll(ctx context.Context) ([]*domain.Event, error)
+	Update(ctx context.Context, event *domain.Event) error
+	Delete(ctx context.Context, eventID string) error
+}
+
+// WebhookStore persists webhooks and their deliveries
+type WebhookStore interface {
+	Create(ctx context.Context, webhook *domain.Webhook) error
+	GetByID(ctx context.Context, webhookID string) (*domain.Webhook, error)
+	GetByGroupID(ctx context.Context, groupID string) ([]*domain.Webhook, error)
+	RotateSecret(ctx context.Context, webhookID string, secret string, graceUntil time.Time) error
+	SetActive(ctx context.Context, webhookID string, active bool) error
+	Delete(ctx context.Context, webhookID string) error
+	CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error
+	GetDeliveryByID(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error)
+	GetDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+// IdempotencyStore keeps the responses to requests sent with an Idempotency-Key header
+type IdempotencyStore interface {
+	// Create claims a key, setting its creation time. Expired keys and incomplete keys
+	// past their lease are removed first, a key that is still held is a conflict.
+	Create(ctx context.Context, key *domain.IdempotencyKey) error
+	GetByKey(ctx context.Context, key string) (*domain.IdempotencyKey, error)
+	// Complete stores the response to the request that claimed the key. It returns
+	// an error wrapping ErrNotFound when the key is no longer held by that claim.
+	Complete(ctx context.Context, key *domain.IdempotencyKey) error
+	// Delete releases a key held by the claim of key, so the request can be sent again
+	Delete(ctx context.Context, key *domain.IdempotencyKey) error
+}
+
+// Transactor runs units of work. The store calls made with the context passed to fn
+// are isolated from concurrent units of work and are committed together when fn
+// returns nil. *util.Database is the Transactor of the database backed stores.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Stores bundles one store of each kind sharing the same backend
+type Stores struct {
+	Users    UserStore
+	Groups   GroupStore
+	Events   EventStore
+	Webhooks WebhookStore
+	// Idempotency keeps the responses to requests that may be retried
+	Idempotency IdempotencyStore
+	// Tx runs units of work over the stores
+	Tx Transactor
+}
+
+// NewPostgresStores returns the stores backed by the Postgres repositories
+func NewPostgresStores(db *util.Database) Stores {
+	return Stores{
+		Users:       NewUserRepository(db),
+		Groups:      NewGroupRepository(db),
+		Events:      NewEventRepository(db),
+		Webhooks:    NewWebhookRepository(db),
+		Idempotency: NewIdempotencyRepository(db),
+		Tx:          db,
+	}
+}
+
+var (
+	_ Transactor = (*util.Database)(nil)
+
+	_ UserStore        = (*UserRepository)(nil)
+	_ GroupStore       = (*GroupRepository)(nil)
+	_ EventStore       = (*EventRepository)(nil)
+	_ WebhookStore     = (*WebhookRepository)(nil)
+	_ IdempotencyStore = (*IdempotencyRepository)(nil)
+)
@@ -0,0 +1,513 @@
+// Package storetest checks that a store implementation behaves like the Postgres
+// repositories: the same ordering, foreign keys and errors.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+	"github.com/google/uuid"
+)
+
+// Run runs every check against fresh stores returned by newStores
+func Run(t *testing.T, newStores func(t *testing.T) repository.Stores) {
+	checks := []struct {
+		name  string
+		check func(t *testing.T, stores repository.Stores)
+	}{
+		{"EventPaging", testEventPaging},
+		{"ErrorsMatchPostgres", testErrorsMatchPostgres},
+		{"GroupDeleteCascadesToWebhooks", testGroupDeleteCascadesToWebhooks},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"EventHLCOrder", testEventHLCOrder},
+		{"GroupVersionAndChainHead", testGroupVersionAndChainHead},
+		{"LinkedEventID", testLinkedEventID},
+		{"CurrencyEvents", testCurrencyEvents},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			c.check(t, newStores(t))
+		})
+	}
+}
+
+// setup adds one user and one group to the stores
+func setup(t *testing.T, stores repository.Stores) (*domain.User, *domain.Group) {
+	t.Helper()
+	ctx := context.Background()
+
+	user := domain.NewUser("Ann")
+	if err := stores.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	group := domain.NewGroup("Trip")
+	if err := stores.Groups.Create(ctx, group); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	return user, group
+}
+
+func newEvent(group *domain.Group, user *domain.User) *domain.Event {
+	return domain.NewEvent(uuid.NewString(), "", group.GroupID, user.UserID, util.ExpenseCreated, json.RawMessage(`{}`))
+}
+
+func testEventPaging(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	var ids []string
+	for range 5 {
+		event := newEvent(group, user)
+		if err := stores.Events.Create(ctx, event); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+		ids = append(ids, event.EventID)
+	}
+
+	first, err := stores.Events.GetEventsByGroupAfterID(ctx, group.GroupID, "0", 2)
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	next, err := stores.Events.GetEventsByGroupAfterID(ctx, group.GroupID, first[len(first)-1].EventID, 10)
+	if err != nil {
+		t.Fatalf("next page: %v", err)
+	}
+
+	var paged []string
+	for _, event := range append(first, next...) {
+		paged = append(paged, event.EventID)
+	}
+	if len(first) != 2 || len(paged) != len(ids) {
+		t.Fatalf("paged %d then %d events, want 2 then %d", len(first), len(next), len(ids)-2)
+	}
+	for i := range ids {
+		if paged[i] != ids[i] {
+			t.Errorf("event %d is %s, want %s", i, paged[i], ids[i])
+		}
+	}
+
+	latest, err := stores.Events.GetByGroupID(ctx, group.GroupID)
+	if err != nil {
+		t.Fatalf("get events: %v", err)
+	}
+	if latest[0].EventID != ids[len(ids)-1] {
+		t.Errorf("GetByGroupID starts with %s, want the newest event %s", latest[0].EventID, ids[len(ids)-1])
+	}
+}
+
+func testErrorsMatchPostgres(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	event := newEvent(group, user)
+	if err := stores.Events.Create(ctx, event); err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+
+	stranger := newEvent(group, user)
+	stranger.UserID = uuid.NewString()
+
+	other := domain.NewUser("Bob")
+	other.FirebaseID.String, other.FirebaseID.Valid = "token", true
+	if err := stores.Users.Create(ctx, other); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	sameGroup := domain.NewGroup("Other trip")
+	sameGroup.GroupID = group.GroupID
+	sameUser := domain.NewUser("Cat")
+	sameUser.UserID = user.UserID
+	malformedGroup := domain.NewGroup("Trip")
+	malformedGroup.GroupID = "nope"
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"duplicate event", stores.Events.Create(ctx, event), repository.ErrConflict},
+		{"duplicate group ID", stores.Groups.Create(ctx, sameGroup), repository.ErrConflict},
+		{"duplicate user ID", stores.Users.Create(ctx, sameUser), repository.ErrConflict},
+		{"malformed group ID", stores.Groups.Create(ctx, malformedGroup), repository.ErrValidation},
+		{"event by unknown user", stores.Events.Create(ctx, stranger), repository.ErrValidation},
+		{"malformed ID", func() error { _, err := stores.Groups.GetByID(ctx, "nope"); return err }(), repository.ErrValidation},
+		{"missing group", func() error { _, err := stores.Groups.GetByID(ctx, uuid.NewString()); return err }(), repository.ErrNotFound},
+		{"taken firebase ID", stores.Users.UpdateFirebaseID(ctx, user.UserID, "token"), repository.ErrConflict},
+		{"user with events", stores.Users.Delete(ctx, user.UserID), repository.ErrValidation},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+func testGroupDeleteCascadesToWebhooks(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	webhook := domain.NewWebhook(group.GroupID, user.UserID, "https://example.com/hook", "secret")
+	if err := stores.Webhooks.Create(ctx, webhook); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	delivery := domain.NewWebhookDelivery(webhook.WebhookID, uuid.NewString(), json.RawMessage(`{}`))
+	if err := stores.Webhooks.CreateDelivery(ctx, delivery); err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+
+	if err := stores.Groups.Delete(ctx, group.GroupID); err != nil {
+		t.Fatalf("delete group: %v", err)
+	}
+	if _, err := stores.Webhooks.GetByID(ctx, webhook.WebhookID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("webhook of a deleted group: got %v, want %v", err, repository.ErrNotFound)
+	}
+	if _, err := stores.Webhooks.GetDeliveryByID(ctx, delivery.DeliveryID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("delivery of a deleted webhook: got %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func testConcurrentWrites(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stores.Events.Create(ctx, newEvent(group, user)); err != nil {
+				t.Errorf("create event: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	events, err := stores.Events.GetByGroupID(ctx, group.GroupID)
+	if err != nil {
+		t.Fatalf("get events: %v", err)
+	}
+	if len(events) != 50 {
+		t.Errorf("got %d events, want 50", len(events))
+	}
+}
+
+// testIdempotencyKeys checks that a key is held until it expires, is released or its
+// lease passes before it completes, and keeps the response stored for it
+func testIdempotencyKeys(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	store := stores.Idempotency
+	newKey := func(key string, fingerprint string, ttl time.Duration, lease time.Duration) *domain.IdempotencyKey {
+		return &domain.IdempotencyKey{
+			Key:         key,
+			ClaimID:     uuid.NewString(),
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(ttl),
+			LeaseUntil:  time.Now().Add(lease),
+		}
+	}
+
+	key := newKey("key-1", "first", time.Hour, time.Minute)
+	if err := store.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if key.CreatedAt.IsZero() {
+		t.Error("Create did not set the creation time")
+	}
+	held := newKey("key-1", "second", time.Hour, time.Minute)
+	if err := store.Create(ctx, held); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Create of a held key: got %v, want ErrConflict", err)
+	}
+
+	key.ResponseStatus = 200
+	key.ResponseContentType = "application/json"
+	key.ResponseBody = []byte(`{"id":"1"}`)
+	if err := store.Complete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetByKey(ctx, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Completed() || stored.ClaimID != key.ClaimID || stored.Fingerprint != "first" || stored.ResponseStatus != 200 ||
+		stored.ResponseContentType != "application/json" || string(stored.ResponseBody) != `{"id":"1"}` {
+		t.Errorf("GetByKey returned %+v", stored)
+	}
+
+	if err := store.Delete(ctx, held); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Delete by another claim: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetByKey(ctx, "key-1"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetByKey of a released key: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Delete of a released key: got %v, want ErrNotFound", err)
+	}
+
+	// An expired key can be claimed again
+	expired := newKey("key-2", "first", -time.Second, time.Minute)
+	if err := store.Create(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(ctx, newKey("key-2", "second", time.Hour, time.Minute)); err != nil {
+		t.Errorf("Create of an expired key: %v", err)
+	}
+
+	// So can an incomplete key past its lease, but not a completed one
+	abandoned := newKey("key-3", "first", time.Hour, -time.Second)
+	if err := store.Create(ctx, abandoned); err != nil {
+		t.Fatal(err)
+	}
+	retry := newKey("key-3", "first", time.Hour, time.Minute)
+	if err := store.Create(ctx, retry); err != nil {
+		t.Errorf("Create of an abandoned key: %v", err)
+	}
+
+	// The request that abandoned it can neither complete nor release the retry's claim
+	abandoned.ResponseStatus = 200
+	if err := store.Complete(ctx, abandoned); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Complete by a claim that was taken over: got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, abandoned); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Delete by a claim that was taken over: got %v, want ErrNotFound", err)
+	}
+	if stored, err := store.GetByKey(ctx, "key-3"); err != nil || stored.ClaimID != retry.ClaimID || stored.Completed() {
+		t.Errorf("key taken over by a retry is %+v, %v, want the retry's incomplete claim", stored, err)
+	}
+	completed := newKey("key-4", "first", time.Hour, -time.Second)
+	if err := store.Create(ctx, completed); err != nil {
+		t.Fatal(err)
+	}
+	completed.ResponseStatus = 200
+	if err := store.Complete(ctx, completed); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(ctx, newKey("key-4", "second", time.Hour, time.Minute)); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Create of a completed key past its lease: got %v, want ErrConflict", err)
+	}
+}
+
+// testEventHLCOrder checks that a group's events are listed by HLC, while paging
+// keeps the order they arrived in
+func testEventHLCOrder(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	online := newEvent(group, user)
+	online.HLC = hlc.HLC{Wall: time.Now().UnixMilli()}
+	offline := newEvent(group, user)
+	offline.HLC = hlc.HLC{Wall: online.HLC.Wall - 60_000, Logical: 3}
+	unstamped := newEvent(group, user)
+	for _, event := range []*domain.Event{online, offline, unstamped} {
+		if err := stores.Events.Create(ctx, event); err != nil {
+			t.Fatalf("create event: %v", err)
+		}
+	}
+	if unstamped.HLC.IsZero() || unstamped.HLC.Wall != unstamped.CreatedAt.UnixMilli() {
+		t.Errorf("event created without an HLC got %s, want its creation time %v", unstamped.HLC, unstamped.CreatedAt)
+	}
+
+	stored, err := stores.Events.GetByID(ctx, offline.EventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.HLC != offline.HLC {
+		t.Errorf("stored HLC %s, want %s", stored.HLC, offline.HLC)
+	}
+
+	var byHLC []string
+	events, err := stores.Events.GetByGroupID(ctx, group.GroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		byHLC = append(byHLC, event.EventID)
+	}
+	if want := []string{unstamped.EventID, online.EventID, offline.EventID}; !slices.Equal(byHLC, want) {
+		t.Errorf("GetByGroupID returned %v, want latest HLC first %v", byHLC, want)
+	}
+
+	var byArrival []string
+	page, err := stores.Events.GetEventsByGroupAfterID(ctx, group.GroupID, "0", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range page {
+		byArrival = append(byArrival, event.EventID)
+	}
+	if want := []string{online.EventID, offline.EventID, unstamped.EventID}; !slices.Equal(byArrival, want) {
+		t.Errorf("GetEventsByGroupAfterID returned %v, want arrival order %v", byArrival, want)
+	}
+}
+
+// testGroupVersionAndChainHead checks that group versions count up from zero, that
+// a chain is an expense event and the expense events of its group linked to it,
+// and that its head is the last of them
+func testGroupVersionAndChainHead(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	for want := int64(1); want <= 2; want++ {
+		version, err := stores.Groups.IncrementVersion(ctx, group.GroupID)
+		if err != nil || version != want {
+			t.Errorf("IncrementVersion returned %d, %v, want %d", version, err, want)
+		}
+	}
+	locked, err := stores.Groups.GetForUpdate(ctx, group.GroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked.Version != 2 {
+		t.Errorf("GetForUpdate returned version %d, want 2", locked.Version)
+	}
+	if _, err := stores.Groups.IncrementVersion(ctx, uuid.NewString()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("IncrementVersion of a missing group: got %v, want ErrNotFound", err)
+	}
+
+	root := newEvent(group, user)
+	if err := stores.Events.Create(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	head, err := stores.Events.GetChainHead(ctx, group.GroupID, root.EventID)
+	if err != nil || head.EventID != root.EventID {
+		t.Errorf("head of an unlinked event is %v, %v, want the event itself", head, err)
+	}
+
+	var last *domain.Event
+	for range 2 {
+		last = newEvent(group, user)
+		last.LinkedEventID = root.EventID
+		if err := stores.Events.Create(ctx, last); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Events of other types or groups linking to the expense are not part of its chain
+	other := domain.NewGroup("Other trip")
+	if err := stores.Groups.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	unrelated := []*domain.Event{newEvent(other, user), newEvent(group, user)}
+	unrelated[1].EventType = util.GroupUserJoined
+	for _, event := range unrelated {
+		event.LinkedEventID = root.EventID
+		if err := stores.Events.Create(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head, err = stores.Events.GetChainHead(ctx, group.GroupID, root.EventID)
+	if err != nil || head.EventID != last.EventID || head.LinkedEventID != root.EventID {
+		t.Errorf("head of the chain is %+v, %v, want the last linked event %s", head, err, last.EventID)
+	}
+	if _, err := stores.Events.GetChainHead(ctx, group.GroupID, uuid.NewString()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetChainHead of a missing event: got %v, want ErrNotFound", err)
+	}
+
+	chain, err := stores.Events.GetChain(ctx, group.GroupID, root.EventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 3 || chain[0].EventID != root.EventID || chain[2].EventID != last.EventID {
+		t.Errorf("GetChain returned %d events, want the event and the 2 linked to it in arrival order", len(chain))
+	}
+	if _, err := stores.Events.GetChain(ctx, group.GroupID, uuid.NewString()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("GetChain of a missing event: got %v, want ErrNotFound", err)
+	}
+	if chain, err := stores.Events.GetChain(ctx, other.GroupID, root.EventID); err != nil || len(chain) != 1 || chain[0].EventID != unrelated[0].EventID {
+		t.Errorf("GetChain in another group returned %d events, %v, want only that group's event", len(chain), err)
+	}
+}
+
+func testLinkedEventID(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	root := newEvent(group, user)
+	if err := stores.Events.Create(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	linked := newEvent(group, user)
+	linked.EventType = util.ExpenseUpdated
+	linked.LinkedEventID = root.EventID
+	if err := stores.Events.Create(ctx, linked); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every read returns the link, and no link for the event without one
+	got, err := stores.Events.GetByID(ctx, linked.EventID)
+	if err != nil || got.LinkedEventID != root.EventID {
+		t.Errorf("GetByID returned %+v, %v, want linked to %s", got, err, root.EventID)
+	}
+	got, err = stores.Events.GetFirstByGroupAndType(ctx, group.GroupID, util.ExpenseUpdated)
+	if err != nil || got.LinkedEventID != root.EventID {
+		t.Errorf("GetFirstByGroupAndType returned %+v, %v, want linked to %s", got, err, root.EventID)
+	}
+	if got, err := stores.Events.GetByID(ctx, root.EventID); err != nil || got.LinkedEventID != "" {
+		t.Errorf("GetByID of an unlinked event returned %+v, %v", got, err)
+	}
+	all, err := stores.Events.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range all {
+		if event.EventID == linked.EventID && event.LinkedEventID != root.EventID {
+			t.Errorf("GetAll returned %+v, want linked to %s", event, root.EventID)
+		}
+	}
+}
+
+// testCurrencyEvents checks GetCurrencyEvents returns the currency events of a group
+// and the expenses created or updated before the first of them, latest HLC first
+func testCurrencyEvents(t *testing.T, stores repository.Stores) {
+	ctx := context.Background()
+	user, group := setup(t, stores)
+
+	record := func(eventType util.EventType, wall int64) *domain.Event {
+		event := newEvent(group, user)
+		event.EventType = eventType
+		event.HLC = hlc.HLC{Wall: wall}
+		if err := stores.Events.Create(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	record(util.ExpenseCreated, 1)
+	if events, err := stores.Events.GetCurrencyEvents(ctx, group.GroupID); err != nil || len(events) != 0 {
+		t.Errorf("GetCurrencyEvents without currency events returned %d events, %v", len(events), err)
+	}
+
+	// Recorded out of HLC order, as offline clients do
+	record(util.GroupAddCurrency, 40)
+	record(util.GroupSetDefaultCurrency, 20)
+	record(util.ExpenseUpdated, 10)
+	record(util.ExpenseDeleted, 5)
+	record(util.GroupUserJoined, 6)
+	record(util.ExpenseCreated, 30)
+	record(util.ExpenseUpdated, 50)
+
+	events, err := stores.Events.GetCurrencyEvents(ctx, group.GroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, event := range events {
+		got = append(got, event.HLC.Wall)
+	}
+	if want := []int64{40, 20, 10, 1}; !slices.Equal(got, want) {
+		t.Errorf("GetCurrencyEvents returned the events at %v, want %v", got, want)
+	}
+}
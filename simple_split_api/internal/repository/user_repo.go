@@ -23,18 +23,19 @@ func NewUserRepository(db *util.Database) *UserRepository {
 	}
 }
 
-// Create adds a new user to the database
+// Create adds a new user to the database, generating its ID unless one is set.
+// A user with the same ID is a conflict.
 func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
-		INSERT INTO users (name, firebase_id)
-		VALUES ($1, $2)
+		INSERT INTO users (user_id, name, firebase_id, email, unsubscribe_token)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, uuidv7()), $2, $3, $4, $5)
 		RETURNING user_id, created_at
 	`
 
-	// FirebaseID is already a sql.NullString, so it will handle NULL values correctly
-	err := r.DB.DB.QueryRowContext(ctx, query, user.Name, user.FirebaseID).Scan(&user.UserID, &user.CreatedAt)
+	// FirebaseID and Email are already sql.NullStrings, so they will handle NULL values correctly
+	err := r.DB.QueryRowContext(ctx, query, user.UserID, user.Name, user.FirebaseID, user.Email, user.UnsubscribeToken).Scan(&user.UserID, &user.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
+		return fmt.Errorf("failed to create user: %w", classify(err))
 	}
 
 	return nil
@@ -43,25 +44,28 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 // GetByID retrieves a user by ID
 func (r *UserRepository) GetByID(ctx context.Context, userID string) (*domain.User, error) {
 	query := `
-		SELECT user_id, name, firebase_id, created_at
+		SELECT user_id, name, firebase_id, email, email_unsubscribed, unsubscribe_token, created_at
 		FROM users
 		WHERE user_id = $1
 	`
 
 	user := &domain.User{}
-	err := r.DB.DB.QueryRowContext(ctx, query, userID).Scan(
+	err := r.DB.QueryRowContext(ctx, query, userID).Scan(
 		&user.UserID,
 		&user.Name,
 		&user.FirebaseID,
+		&user.Email,
+		&user.EmailUnsubscribed,
+		&user.UnsubscribeToken,
 		&user.CreatedAt,
 	)
 
 	if err != nil {
 		slog.Error("Error in getting User", "error", err)
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user not found: %s", userID)
+			return nil, fmt.Errorf("user %w: %s", ErrNotFound, userID)
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", classify(err))
 	}
 
 	return user, nil
@@ -70,14 +74,14 @@ func (r *UserRepository) GetByID(ctx context.Context, userID string) (*domain.Us
 // GetAll retrieves all users
 func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
 	query := `
-		SELECT user_id, name, firebase_id, created_at
+		SELECT user_id, name, firebase_id, email, email_unsubscribed, unsubscribe_token, created_at
 		FROM users
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.DB.DB.QueryContext(ctx, query)
+	rows, err := r.DB.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query users: %w", err)
+		return nil, fmt.Errorf("failed to query users: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -88,6 +92,9 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*domain.User, error) {
 			&user.UserID,
 			&user.Name,
 			&user.FirebaseID,
+			&user.Email,
+			&user.EmailUnsubscribed,
+			&user.UnsubscribeToken,
 			&user.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan user row: %w", err)
@@ -111,9 +118,9 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	`
 
 	// FirebaseID is already a sql.NullString, so it will handle NULL values correctly
-	result, err := r.DB.DB.ExecContext(ctx, query, user.Name, user.FirebaseID, user.UserID)
+	result, err := r.DB.ExecContext(ctx, query, user.Name, user.FirebaseID, user.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to update user: %w", err)
+		return fmt.Errorf("failed to update user: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -122,7 +129,7 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found: %s", user.UserID)
+		return fmt.Errorf("user %w: %s", ErrNotFound, user.UserID)
 	}
 
 	return nil
@@ -131,24 +138,27 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 // GetByFirebaseID retrieves a user by their Firebase ID
 func (r *UserRepository) GetByFirebaseID(ctx context.Context, firebaseID string) (*domain.User, error) {
 	query := `
-		SELECT user_id, name, firebase_id, created_at
+		SELECT user_id, name, firebase_id, email, email_unsubscribed, unsubscribe_token, created_at
 		FROM users
 		WHERE firebase_id = $1
 	`
 
 	user := &domain.User{}
-	err := r.DB.DB.QueryRowContext(ctx, query, firebaseID).Scan(
+	err := r.DB.QueryRowContext(ctx, query, firebaseID).Scan(
 		&user.UserID,
 		&user.Name,
 		&user.FirebaseID,
+		&user.Email,
+		&user.EmailUnsubscribed,
+		&user.UnsubscribeToken,
 		&user.CreatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user with firebase ID not found: %s", firebaseID)
+			return nil, fmt.Errorf("user with firebase ID %w: %s", ErrNotFound, firebaseID)
 		}
-		return nil, fmt.Errorf("failed to get user by firebase ID: %w", err)
+		return nil, fmt.Errorf("failed to get user by firebase ID: %w", classify(err))
 	}
 
 	return user, nil
@@ -169,9 +179,41 @@ func (r *UserRepository) UpdateFirebaseID(ctx context.Context, userID string, fi
 		WHERE user_id = $2
 	`
 
-	result, err := r.DB.DB.ExecContext(ctx, query, firebaseNullString, userID)
+	result, err := r.DB.ExecContext(ctx, query, firebaseNullString, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update firebase ID: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %w: %s", ErrNotFound, userID)
+	}
+
+	return nil
+}
+
+// UpdateEmail sets a user's email address together with a fresh unsubscribe token.
+// Setting an address re-subscribes the user; an empty address removes it.
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID string, email string, unsubscribeToken string) error {
+	var emailNullString, tokenNullString sql.NullString
+	if email != "" {
+		emailNullString = sql.NullString{String: email, Valid: true}
+		tokenNullString = sql.NullString{String: unsubscribeToken, Valid: true}
+	}
+
+	query := `
+		UPDATE users
+		SET email = $1, unsubscribe_token = $2, email_unsubscribed = FALSE
+		WHERE user_id = $3
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, emailNullString, tokenNullString, userID)
 	if err != nil {
-		return fmt.Errorf("failed to update firebase ID: %w", err)
+		return fmt.Errorf("failed to update email: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -180,12 +222,42 @@ func (r *UserRepository) UpdateFirebaseID(ctx context.Context, userID string, fi
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found: %s", userID)
+		return fmt.Errorf("user %w: %s", ErrNotFound, userID)
 	}
 
 	return nil
 }
 
+// UnsubscribeEmail stops email notifications for the user owning the unsubscribe token
+func (r *UserRepository) UnsubscribeEmail(ctx context.Context, unsubscribeToken string) (*domain.User, error) {
+	query := `
+		UPDATE users
+		SET email_unsubscribed = TRUE
+		WHERE unsubscribe_token = $1
+		RETURNING user_id, name, firebase_id, email, email_unsubscribed, unsubscribe_token, created_at
+	`
+
+	user := &domain.User{}
+	err := r.DB.QueryRowContext(ctx, query, unsubscribeToken).Scan(
+		&user.UserID,
+		&user.Name,
+		&user.FirebaseID,
+		&user.Email,
+		&user.EmailUnsubscribed,
+		&user.UnsubscribeToken,
+		&user.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("unsubscribe token %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to unsubscribe email: %w", classify(err))
+	}
+
+	return user, nil
+}
+
 // Delete removes a user from the database
 func (r *UserRepository) Delete(ctx context.Context, userID string) error {
 	query := `
@@ -193,9 +265,9 @@ func (r *UserRepository) Delete(ctx context.Context, userID string) error {
 		WHERE user_id = $1
 	`
 
-	result, err := r.DB.DB.ExecContext(ctx, query, userID)
+	result, err := r.DB.ExecContext(ctx, query, userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+		return fmt.Errorf("failed to delete user: %w", classify(err))
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -204,8 +276,202 @@ func (r *UserRepository) Delete(ctx context.Context, userID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found: %s", userID)
+		return fmt.Errorf("user %w: %s", ErrNotFound, userID)
 	}
 
 	return nil
 }
+
+// Search returns the users whose ID matches text exactly or whose name or email contains it
+func (r *UserRepository) Search(ctx context.Context, text string, limit int) ([]*domain.User, error) {
+	query := `
+		SELECT user_id, name, firebase_id, email, email_unsubscribed, unsubscribe_token, created_at
+		FROM users
+		WHERE user_id::text = $1
+		   OR name ILIKE '%' || $1 || '%'
+		   OR email ILIKE '%' || $1 || '%'
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var users []*domain.User
+	for rows.Next() {
+		user := &domain.User{}
+		if err := rows.Scan(
+			&user.UserID,
+			&user.Name,
+			&user.FirebaseID,
+			&user.Email,
+			&user.EmailUnsubscribed,
+			&user.UnsubscribeToken,
+			&user.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, nil
+}
+
+// MergeResult counts the rows changed by Merge
+type MergeResult struct {
+	// EventsMoved is the number of events the merged user had recorded
+	EventsMoved int64
+	// PayloadsRewritten is the number of event payloads that mentioned the merged user
+	PayloadsRewritten int64
+	// WebhooksMoved is the number of webhooks the merged user had created
+	WebhooksMoved int64
+	// GroupsChanged is the number of groups whose events were rewritten
+	GroupsChanged int64
+}
+
+// Merge folds the user fromUserID into intoUserID, e.g. after someone joined a group
+// again from a new device. Events, references to the user in event payloads and
+// webhooks move to intoUserID, which also takes over the Firebase token and email
+// address when it has none. fromUserID is deleted. It all happens in one unit of
+// work, which locks every group whose events are rewritten and bumps its version
+// like any other change to its events.
+func (r *UserRepository) Merge(ctx context.Context, fromUserID string, intoUserID string) (*MergeResult, error) {
+	if fromUserID == intoUserID {
+		return nil, fmt.Errorf("%w: cannot merge user %s into itself", ErrValidation, fromUserID)
+	}
+
+	result := &MergeResult{}
+	err := r.DB.InTx(ctx, func(ctx context.Context) error {
+		groups := NewGroupRepository(r.DB)
+
+		// Groups are locked before users, in the order event writes take them. Events
+		// of the user can be recorded in another group until its lock is held, so the
+		// groups are looked up again until no new one turns up.
+		locked := map[string]bool{}
+		for {
+			groupIDs, err := r.mergedGroupIDs(ctx, fromUserID)
+			if err != nil {
+				return err
+			}
+			added := false
+			for _, groupID := range groupIDs {
+				if locked[groupID] {
+					continue
+				}
+				if _, err := groups.GetForUpdate(ctx, groupID); err != nil {
+					return err
+				}
+				locked[groupID] = true
+				added = true
+			}
+			if !added {
+				break
+			}
+		}
+
+		// Lock both users so they can't change while they are merged
+		for _, userID := range []string{intoUserID, fromUserID} {
+			var exists bool
+			err := r.DB.QueryRowContext(ctx, `SELECT TRUE FROM users WHERE user_id = $1 FOR UPDATE`, userID).Scan(&exists)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("user %w: %s", ErrNotFound, userID)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to lock user: %w", classify(err))
+			}
+		}
+
+		steps := []struct {
+			query string
+			args  []interface{}
+			count *int64
+		}{
+			{`UPDATE events SET user_id = $2 WHERE user_id = $1`, []interface{}{fromUserID, intoUserID}, &result.EventsMoved},
+			// IDs are UUIDs, so a quoted ID in a payload can only be a reference to the user
+			{`UPDATE events SET payload = replace(payload::text, $1, $2)::jsonb WHERE strpos(payload::text, $1) > 0`,
+				[]interface{}{`"` + fromUserID + `"`, `"` + intoUserID + `"`}, &result.PayloadsRewritten},
+			{`UPDATE webhooks SET created_by = $2 WHERE created_by = $1`, []interface{}{fromUserID, intoUserID}, &result.WebhooksMoved},
+		}
+		for _, step := range steps {
+			res, err := r.DB.ExecContext(ctx, step.query, step.args...)
+			if err != nil {
+				return fmt.Errorf("failed to merge user: %w", classify(err))
+			}
+			if *step.count, err = res.RowsAffected(); err != nil {
+				return fmt.Errorf("failed to get rows affected: %w", err)
+			}
+		}
+
+		// Clients sync by group version, so every group with rewritten events gets a new one
+		for groupID := range locked {
+			if _, err := groups.IncrementVersion(ctx, groupID); err != nil {
+				return err
+			}
+		}
+		result.GroupsChanged = int64(len(locked))
+
+		// The Firebase token and unsubscribe token are unique, so the merged user goes before they move
+		var from domain.User
+		err := r.DB.QueryRowContext(ctx, `
+			DELETE FROM users
+			WHERE user_id = $1
+			RETURNING firebase_id, email, email_unsubscribed, unsubscribe_token
+		`, fromUserID).Scan(&from.FirebaseID, &from.Email, &from.EmailUnsubscribed, &from.UnsubscribeToken)
+		if err != nil {
+			return fmt.Errorf("failed to delete merged user: %w", classify(err))
+		}
+
+		_, err = r.DB.ExecContext(ctx, `
+			UPDATE users
+			SET firebase_id = COALESCE(firebase_id, $2),
+			    unsubscribe_token = CASE WHEN email IS NULL THEN $4 ELSE unsubscribe_token END,
+			    email_unsubscribed = CASE WHEN email IS NULL THEN $5 ELSE email_unsubscribed END,
+			    email = COALESCE(email, $3)
+			WHERE user_id = $1
+		`, intoUserID, from.FirebaseID, from.Email, from.UnsubscribeToken, from.EmailUnsubscribed)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", classify(err))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// mergedGroupIDs returns the groups with events Merge rewrites for the user, in a
+// fixed order so concurrent merges lock them in the same order
+func (r *UserRepository) mergedGroupIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT DISTINCT group_id
+		FROM events
+		WHERE user_id = $1 OR strpos(payload::text, $2) > 0
+		ORDER BY group_id
+	`, userID, `"`+userID+`"`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups of user: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			return nil, fmt.Errorf("failed to scan group ID: %w", err)
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating group IDs: %w", err)
+	}
+	return groupIDs, nil
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// WebhookRepository handles database operations for webhooks and their deliveries
+type WebhookRepository struct {
+	DB *util.Database
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *util.Database) *WebhookRepository {
+	return &WebhookRepository{
+		DB: db,
+	}
+}
+
+// Create adds a new webhook to the database
+func (r *WebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	query := `
+		INSERT INTO webhooks (group_id, created_by, url, secret, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING webhook_id, created_at
+	`
+
+	err := r.DB.QueryRowContext(ctx, query,
+		webhook.GroupID,
+		webhook.CreatedBy,
+		webhook.URL,
+		webhook.Secret,
+		webhook.Active,
+	).Scan(&webhook.WebhookID, &webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", classify(err))
+	}
+
+	return nil
+}
+
+// GetByID retrieves a webhook by ID
+func (r *WebhookRepository) GetByID(ctx context.Context, webhookID string) (*domain.Webhook, error) {
+	query := `
+		SELECT webhook_id, group_id, created_by, url, secret, previous_secret, previous_secret_expires_at, active, created_at
+		FROM webhooks
+		WHERE webhook_id = $1
+	`
+
+	webhook := &domain.Webhook{}
+	err := r.DB.QueryRowContext(ctx, query, webhookID).Scan(
+		&webhook.WebhookID,
+		&webhook.GroupID,
+		&webhook.CreatedBy,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.PreviousSecret,
+		&webhook.PreviousSecretExpiresAt,
+		&webhook.Active,
+		&webhook.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook %w: %s", ErrNotFound, webhookID)
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", classify(err))
+	}
+
+	return webhook, nil
+}
+
+// GetByGroupID retrieves all webhooks registered for a group
+func (r *WebhookRepository) GetByGroupID(ctx context.Context, groupID string) ([]*domain.Webhook, error) {
+	query := `
+		SELECT webhook_id, group_id, created_by, url, secret, previous_secret, previous_secret_expires_at, active, created_at
+		FROM webhooks
+		WHERE group_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var webhooks = make([]*domain.Webhook, 0)
+	for rows.Next() {
+		webhook := &domain.Webhook{}
+		if err := rows.Scan(
+			&webhook.WebhookID,
+			&webhook.GroupID,
+			&webhook.CreatedBy,
+			&webhook.URL,
+			&webhook.Secret,
+			&webhook.PreviousSecret,
+			&webhook.PreviousSecretExpiresAt,
+			&webhook.Active,
+			&webhook.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook row: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook rows: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// RotateSecret replaces a webhook's secret, keeping the old one valid until graceUntil
+func (r *WebhookRepository) RotateSecret(ctx context.Context, webhookID string, secret string, graceUntil time.Time) error {
+	query := `
+		UPDATE webhooks
+		SET previous_secret = secret, previous_secret_expires_at = $1, secret = $2
+		WHERE webhook_id = $3
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, graceUntil, secret, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate webhook secret: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook %w: %s", ErrNotFound, webhookID)
+	}
+
+	return nil
+}
+
+// SetActive turns deliveries to a webhook on or off
+func (r *WebhookRepository) SetActive(ctx context.Context, webhookID string, active bool) error {
+	query := `
+		UPDATE webhooks
+		SET active = $1
+		WHERE webhook_id = $2
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, active, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook %w: %s", ErrNotFound, webhookID)
+	}
+
+	return nil
+}
+
+// Delete removes a webhook and its delivery log from the database
+func (r *WebhookRepository) Delete(ctx context.Context, webhookID string) error {
+	query := `
+		DELETE FROM webhooks
+		WHERE webhook_id = $1
+	`
+
+	result, err := r.DB.ExecContext(ctx, query, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook %w: %s", ErrNotFound, webhookID)
+	}
+
+	return nil
+}
+
+// CreateDelivery adds a new delivery to the delivery log
+func (r *WebhookRepository) CreateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, payload, status, replay_of)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING delivery_id, created_at, updated_at
+	`
+
+	err := r.DB.QueryRowContext(ctx, query,
+		delivery.WebhookID,
+		delivery.EventID,
+		delivery.Payload,
+		delivery.Status,
+		delivery.ReplayOf,
+	).Scan(&delivery.DeliveryID, &delivery.CreatedAt, &delivery.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", classify(err))
+	}
+
+	return nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, last_error = $4, updated_at = now()
+		WHERE delivery_id = $5
+	`
+
+	result, err := r.DB.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.DeliveryID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", classify(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook delivery %w: %s", ErrNotFound, delivery.DeliveryID)
+	}
+
+	return nil
+}
+
+// GetDeliveryByID retrieves a webhook delivery by ID
+func (r *WebhookRepository) GetDeliveryByID(ctx context.Context, deliveryID string) (*domain.WebhookDelivery, error) {
+	query := `
+		SELECT delivery_id, webhook_id, event_id, payload, status, attempts, response_status, last_error, replay_of, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE delivery_id = $1
+	`
+
+	delivery := &domain.WebhookDelivery{}
+	err := r.DB.QueryRowContext(ctx, query, deliveryID).Scan(
+		&delivery.DeliveryID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.LastError,
+		&delivery.ReplayOf,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("webhook delivery %w: %s", ErrNotFound, deliveryID)
+		}
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", classify(err))
+	}
+
+	return delivery, nil
+}
+
+// GetDeliveriesByWebhookID retrieves the most recent deliveries for a webhook
+func (r *WebhookRepository) GetDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		SELECT delivery_id, webhook_id, event_id, payload, status, attempts, response_status, last_error, replay_of, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var deliveries = make([]*domain.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := &domain.WebhookDelivery{}
+		if err := rows.Scan(
+			&delivery.DeliveryID,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+			&delivery.ReplayOf,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery row: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook delivery rows: %w", err)
+	}
+
+	return deliveries, nil
+}
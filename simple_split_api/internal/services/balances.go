@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+)
+
+// balanceEpsilon is the smallest amount treated as an outstanding balance
+const balanceEpsilon = 0.005
+
+// Balances holds every user's net position per currency.
+// A positive amount means the user is owed money, a negative amount means the user owes money.
+type Balances map[string]map[string]float64
+
+// Settlement is a single payment that moves a group towards being settled up
+type Settlement struct {
+	FromUserID string  `json:"from_user_id"`
+	ToUserID   string  `json:"to_user_id"`
+	Currency   string  `json:"currency"`
+	Amount     float64 `json:"amount"`
+}
+
+// ComputeBalances replays a group's events and returns the net balances.
+// Expenses count as last edited, and those that are deleted are ignored, the
+// same way the mobile app projects them.
+func ComputeBalances(groupEvents []*domain.Event) Balances {
+	balances := make(Balances)
+	for _, folded := range FoldExpenses(groupEvents) {
+		if folded.Status == domain.ExpenseDeleted {
+			continue
+		}
+
+		var expense events.ExpenseCreated
+		if err := json.Unmarshal(folded.Payload, &expense); err != nil {
+			continue
+		}
+
+		if balances[expense.Currency] == nil {
+			balances[expense.Currency] = make(map[string]float64)
+		}
+		for _, paid := range expense.PaidBy {
+			balances[expense.Currency][paid.UserID] += paid.Amount
+		}
+		for _, owed := range expense.PaidFor {
+			balances[expense.Currency][owed.UserID] -= owed.Amount
+		}
+	}
+
+	return balances
+}
+
+// Outstanding returns the currencies in which the user has a non zero balance
+func (b Balances) Outstanding(userID string) map[string]float64 {
+	outstanding := make(map[string]float64)
+	for currency, users := range b {
+		if amount := users[userID]; math.Abs(amount) >= balanceEpsilon {
+			outstanding[currency] = roundCents(amount)
+		}
+	}
+	return outstanding
+}
+
+// Settled reports whether every balance in currency is zero
+func (b Balances) Settled(currency string) bool {
+	for _, amount := range b[currency] {
+		if math.Abs(amount) >= balanceEpsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// SettlementPlan returns the payments that settle every balance, matching the
+// largest debtor with the largest creditor until all balances are zero
+func (b Balances) SettlementPlan() []Settlement {
+	currencies := make([]string, 0, len(b))
+	for currency := range b {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var plan []Settlement
+	for _, currency := range currencies {
+		type position struct {
+			userID string
+			amount float64
+		}
+		var creditors, debtors []position
+		for userID, amount := range b[currency] {
+			if amount >= balanceEpsilon {
+				creditors = append(creditors, position{userID, amount})
+			} else if amount <= -balanceEpsilon {
+				debtors = append(debtors, position{userID, -amount})
+			}
+		}
+		byAmount := func(p []position) func(i, j int) bool {
+			return func(i, j int) bool {
+				if p[i].amount == p[j].amount {
+					return p[i].userID < p[j].userID
+				}
+				return p[i].amount > p[j].amount
+			}
+		}
+		sort.Slice(creditors, byAmount(creditors))
+		sort.Slice(debtors, byAmount(debtors))
+
+		for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+			amount := math.Min(debtors[i].amount, creditors[j].amount)
+			plan = append(plan, Settlement{
+				FromUserID: debtors[i].userID,
+				ToUserID:   creditors[j].userID,
+				Currency:   currency,
+				Amount:     roundCents(amount),
+			})
+			debtors[i].amount -= amount
+			creditors[j].amount -= amount
+			if debtors[i].amount < balanceEpsilon {
+				i++
+			}
+			if creditors[j].amount < balanceEpsilon {
+				j++
+			}
+		}
+	}
+
+	return plan
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
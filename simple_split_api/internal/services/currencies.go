@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+)
+
+// Currencies are the currencies enabled for a group
+type Currencies struct {
+	Enabled map[string]bool
+	// Default is the currency new expenses start in, empty until one is set
+	Default string
+	// Configured is false for groups without currency events, created before
+	// currencies were checked, which accept expenses in any currency
+	Configured bool
+}
+
+// FoldCurrencies replays the currency events of a group in HLC order.
+// GROUP_SET_DEFAULT_CURRENCY enables the currency it sets. The first currency event
+// also enables the currencies of the expenses recorded before it, so a group that
+// predates currency events keeps accepting edits of its expenses and can remove
+// their currencies once settled.
+func FoldCurrencies(groupEvents []*domain.Event) Currencies {
+	groupEvents = slices.Clone(groupEvents)
+	slices.SortFunc(groupEvents, compareReplay)
+
+	currencies := Currencies{Enabled: make(map[string]bool)}
+	used := make(map[string]bool)
+	for _, event := range groupEvents {
+		if !event.EventType.IsCurrencyChange() {
+			if !currencies.Configured && (event.EventType == util.ExpenseCreated || event.EventType == util.ExpenseUpdated) {
+				if currency := payloadCurrency(event.Payload); util.IsCurrency(currency) {
+					used[currency] = true
+				}
+			}
+			continue
+		}
+
+		var payload struct {
+			Currency string `json:"currency"`
+		}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			continue
+		}
+
+		if !currencies.Configured {
+			currencies.Configured = true
+			maps.Copy(currencies.Enabled, used)
+		}
+		switch event.EventType {
+		case util.GroupAddCurrency:
+			currencies.Enabled[payload.Currency] = true
+		case util.GroupRemoveCurrency:
+			delete(currencies.Enabled, payload.Currency)
+		case util.GroupSetDefaultCurrency:
+			currencies.Enabled[payload.Currency] = true
+			currencies.Default = payload.Currency
+		}
+	}
+	return currencies
+}
+
+// ConfigureCurrencies returns the currencies of a group without currency events
+// as its first one, recorded at at, finds them: with the currencies of the expenses
+// recorded before it enabled, the way FoldCurrencies replays it
+func ConfigureCurrencies(groupEvents []*domain.Event, at hlc.HLC) Currencies {
+	currencies := Currencies{Enabled: make(map[string]bool), Configured: true}
+	for _, event := range groupEvents {
+		if (event.EventType == util.ExpenseCreated || event.EventType == util.ExpenseUpdated) && event.HLC.Before(at) {
+			if currency := payloadCurrency(event.Payload); util.IsCurrency(currency) {
+				currencies.Enabled[currency] = true
+			}
+		}
+	}
+	return currencies
+}
+
+// payloadCurrency returns the currency an event payload names, empty when it names none
+func payloadCurrency(payload json.RawMessage) string {
+	var fields struct {
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return ""
+	}
+	return fields.Currency
+}
+
+// Accepts reports whether the group takes expenses in currency
+func (c Currencies) Accepts(currency string) bool {
+	return !c.Configured || c.Enabled[currency]
+}
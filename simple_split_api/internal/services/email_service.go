@@ -0,0 +1,388 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/metrics"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+)
+
+// EmailService sends notification emails over SMTP
+type EmailService struct {
+	UserRepo  repository.UserStore
+	GroupRepo repository.GroupStore
+	Config    config.SMTPConfig
+	// PublicBaseURL is the externally reachable address of the API, used for unsubscribe links
+	PublicBaseURL string
+}
+
+// NewEmailService creates a new email service
+func NewEmailService(userRepo repository.UserStore, groupRepo repository.GroupStore, smtpConfig config.SMTPConfig, publicBaseURL string) *EmailService {
+	return &EmailService{
+		UserRepo:      userRepo,
+		GroupRepo:     groupRepo,
+		Config:        smtpConfig,
+		PublicBaseURL: publicBaseURL,
+	}
+}
+
+// emailData is shared by every template
+type emailData struct {
+	Subject        string
+	Name           string
+	GroupName      string
+	UnsubscribeURL string
+}
+
+type expenseEmailData struct {
+	emailData
+	CreatedBy   string
+	Description string
+	Currency    string
+	Total       float64
+	Paid        float64
+	Share       float64
+}
+
+type paymentLine struct {
+	From     string
+	To       string
+	Currency string
+	Amount   float64
+}
+
+type settlementEmailData struct {
+	emailData
+	Payments []paymentLine
+}
+
+// GenerateUnsubscribeToken creates a new random unsubscribe token
+func GenerateUnsubscribeToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ProcessExpenseCreatedEvent emails every user involved in a new expense
+func (s *EmailService) ProcessExpenseCreatedEvent(ctx context.Context, event *domain.Event) error {
+	var expense events.ExpenseCreated
+	if err := json.Unmarshal(event.Payload, &expense); err != nil {
+		return fmt.Errorf("invalid expense data format: %w", err)
+	}
+
+	group, err := s.GroupRepo.GetByID(ctx, event.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+
+	// Collect everyone who paid or shares in the expense
+	paid := make(map[string]float64)
+	share := make(map[string]float64)
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, p := range expense.PaidBy {
+		paid[p.UserID] += p.Amount
+		if !seen[p.UserID] {
+			seen[p.UserID] = true
+			userIDs = append(userIDs, p.UserID)
+		}
+	}
+	for _, p := range expense.PaidFor {
+		share[p.UserID] += p.Amount
+		if !seen[p.UserID] {
+			seen[p.UserID] = true
+			userIDs = append(userIDs, p.UserID)
+		}
+	}
+
+	names := s.nameResolver(ctx)
+	for _, userID := range userIDs {
+		user, err := s.UserRepo.GetByID(ctx, userID)
+		if err != nil || !user.CanReceiveEmail() {
+			continue
+		}
+
+		data := expenseEmailData{
+			emailData:   s.baseData(user, group, "New expense: "+expense.Description),
+			CreatedBy:   names(event.UserID),
+			Description: expense.Description,
+			Currency:    expense.Currency,
+			Total:       expense.Total,
+			Paid:        paid[userID],
+			Share:       share[userID],
+		}
+		if err := s.send(ctx, user, "expense_created", data.Subject, data); err != nil {
+			slog.ErrorContext(ctx, "Failed to send expense email", "user_id", userID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// SendSettlementPlan emails the group's settlement plan to every member with a balance
+func (s *EmailService) SendSettlementPlan(ctx context.Context, group *domain.Group, balances Balances) int {
+	names := s.nameResolver(ctx)
+
+	var payments []paymentLine
+	recipients := make(map[string]bool)
+	for _, settlement := range balances.SettlementPlan() {
+		payments = append(payments, paymentLine{
+			From:     names(settlement.FromUserID),
+			To:       names(settlement.ToUserID),
+			Currency: settlement.Currency,
+			Amount:   settlement.Amount,
+		})
+		recipients[settlement.FromUserID] = true
+		recipients[settlement.ToUserID] = true
+	}
+
+	sent := 0
+	for userID := range recipients {
+		user, err := s.UserRepo.GetByID(ctx, userID)
+		if err != nil || !user.CanReceiveEmail() {
+			continue
+		}
+
+		data := settlementEmailData{
+			emailData: s.baseData(user, group, "Settle up "+group.Name),
+			Payments:  payments,
+		}
+		if err := s.send(ctx, user, "settlement", data.Subject, data); err != nil {
+			slog.ErrorContext(ctx, "Failed to send settlement email", "user_id", userID, "error", err)
+			continue
+		}
+		sent++
+	}
+
+	return sent
+}
+
+// SendReminders emails every member who still owes money in the group the payments they need to make
+func (s *EmailService) SendReminders(ctx context.Context, group *domain.Group, balances Balances) int {
+	names := s.nameResolver(ctx)
+
+	owed := make(map[string][]paymentLine)
+	for _, settlement := range balances.SettlementPlan() {
+		owed[settlement.FromUserID] = append(owed[settlement.FromUserID], paymentLine{
+			From:     names(settlement.FromUserID),
+			To:       names(settlement.ToUserID),
+			Currency: settlement.Currency,
+			Amount:   settlement.Amount,
+		})
+	}
+
+	sent := 0
+	for userID, payments := range owed {
+		user, err := s.UserRepo.GetByID(ctx, userID)
+		if err != nil || !user.CanReceiveEmail() {
+			continue
+		}
+
+		data := settlementEmailData{
+			emailData: s.baseData(user, group, "Reminder: outstanding payments in "+group.Name),
+			Payments:  payments,
+		}
+		if err := s.send(ctx, user, "reminder", data.Subject, data); err != nil {
+			slog.ErrorContext(ctx, "Failed to send reminder email", "user_id", userID, "error", err)
+			continue
+		}
+		sent++
+	}
+
+	return sent
+}
+
+// UnsubscribeURL returns the link that stops emails for the user
+func (s *EmailService) UnsubscribeURL(user *domain.User) string {
+	return strings.TrimRight(s.PublicBaseURL, "/") + "/api/users/unsubscribe?token=" + url.QueryEscape(user.UnsubscribeToken.String)
+}
+
+func (s *EmailService) baseData(user *domain.User, group *domain.Group, subject string) emailData {
+	return emailData{
+		Subject:        subject,
+		Name:           user.Name,
+		GroupName:      group.Name,
+		UnsubscribeURL: s.UnsubscribeURL(user),
+	}
+}
+
+// nameResolver returns a function that looks up user names, caching the results
+func (s *EmailService) nameResolver(ctx context.Context) func(string) string {
+	cache := make(map[string]string)
+	return func(userID string) string {
+		if name, ok := cache[userID]; ok {
+			return name
+		}
+		name := "Someone"
+		if user, err := s.UserRepo.GetByID(ctx, userID); err == nil {
+			name = user.Name
+		}
+		cache[userID] = name
+		return name
+	}
+}
+
+// send renders the text and HTML variants of a template and mails them to the user
+func (s *EmailService) send(ctx context.Context, user *domain.User, templateName string, subject string, data interface{}) error {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, templateName+".txt.tmpl", data); err != nil {
+		return fmt.Errorf("failed to render text template: %w", err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, templateName+".html.tmpl", data); err != nil {
+		return fmt.Errorf("failed to render html template: %w", err)
+	}
+
+	message, err := buildMessage(s.Config.From, user.Email.String, subject, s.UnsubscribeURL(user), text.Bytes(), html.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = s.sendMail(ctx, user.Email.String, message)
+	metrics.NotificationResult(metrics.ChannelEmail, err)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Sent email", "template", templateName, "user_id", user.UserID)
+	return nil
+}
+
+// sendMail runs an SMTP session delivering message to one recipient, as
+// smtp.SendMail does, but gives up at the configured timeout or when ctx is
+// done so a server that stops answering can't hold up shutdown
+func (s *EmailService) sendMail(ctx context.Context, to string, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.Config.Timeout.Std())
+	defer cancel()
+
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Closing the connection unblocks the session when ctx is canceled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.Config.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)); err != nil {
+			return err
+		}
+	}
+	from := s.Config.From
+	if addr, err := mail.ParseAddress(from); err == nil {
+		from = addr.Address
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage assembles a multipart/alternative MIME message
+func buildMessage(from, to, subject, unsubscribeURL string, text, html []byte) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message part: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, fmt.Errorf("failed to write message part: %w", err)
+		}
+		qp.Close()
+	}
+	writer.Close()
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "List-Unsubscribe: <%s>\r\n", unsubscribeURL)
+	fmt.Fprintf(&message, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n", writer.Boundary())
+	fmt.Fprintf(&message, "\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
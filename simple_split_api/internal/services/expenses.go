@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/models/events"
+	"github.com/RealZimboGuy/budgetApp/internal/util"
+)
+
+// compareReplay orders events by HLC, then by arrival and ID, the order state is replayed in
+func compareReplay(a, b *domain.Event) int {
+	if c := a.HLC.Compare(b.HLC); c != 0 {
+		return c
+	}
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.EventID, b.EventID)
+}
+
+// FoldExpenses folds a group's events into its expenses, in the order they were
+// created in by HLC. Events are applied in HLC order: an expense is as its latest
+// EXPENSE_UPDATED event, and deleted when the latest of its EXPENSE_DELETED and
+// EXPENSE_RESTORED events is a deletion.
+func FoldExpenses(groupEvents []*domain.Event) []*domain.Expense {
+	groupEvents = slices.Clone(groupEvents)
+	slices.SortFunc(groupEvents, compareReplay)
+
+	var expenses []*domain.Expense
+	byID := make(map[string]*domain.Expense)
+	for _, event := range groupEvents {
+		if event.EventType == util.ExpenseCreated {
+			expense := &domain.Expense{
+				ExpenseID: event.EventID,
+				GroupID:   event.GroupID,
+				Status:    domain.ExpenseActive,
+				Payload:   event.Payload,
+				UserID:    event.UserID,
+				HLC:       event.HLC,
+				CreatedAt: event.CreatedAt,
+			}
+			expenses = append(expenses, expense)
+			byID[event.EventID] = expense
+			continue
+		}
+
+		expense, ok := byID[event.LinkedEventID]
+		if !ok {
+			continue
+		}
+		switch event.EventType {
+		case util.ExpenseUpdated:
+			expense.Payload = event.Payload
+		case util.ExpenseDeleted:
+			expense.Status = domain.ExpenseDeleted
+		case util.ExpenseRestored:
+			expense.Status = domain.ExpenseActive
+		}
+	}
+	return expenses
+}
+
+// DeletionState reports whether the expense of a chain, its EXPENSE_CREATED event
+// and the events linked to it, is deleted. It also returns the latest of the
+// chain's EXPENSE_DELETED and EXPENSE_RESTORED events by HLC, nil if there are none.
+func DeletionState(chain []*domain.Event) (bool, *domain.Event) {
+	var last *domain.Event
+	for _, event := range chain {
+		if event.EventType != util.ExpenseDeleted && event.EventType != util.ExpenseRestored {
+			continue
+		}
+		if last == nil || compareReplay(last, event) < 0 {
+			last = event
+		}
+	}
+	return last != nil && last.EventType == util.ExpenseDeleted, last
+}
+
+// BuildExpenseHistory folds the chain of an expense, its EXPENSE_CREATED event and
+// the events linked to it, into its history. Revisions are in HLC order, each
+// EXPENSE_UPDATED revision listing the fields it changed.
+func BuildExpenseHistory(expenseID string, chain []*domain.Event) (*domain.ExpenseHistory, error) {
+	chain = slices.Clone(chain)
+	slices.SortFunc(chain, compareReplay)
+
+	var history *domain.ExpenseHistory
+	var current map[string]json.RawMessage
+	for _, event := range chain {
+		if event.EventID == expenseID {
+			if event.EventType != util.ExpenseCreated {
+				return nil, fmt.Errorf("event %s is a %s event, not an expense", expenseID, event.EventType)
+			}
+			history = &domain.ExpenseHistory{ExpenseID: expenseID, GroupID: event.GroupID}
+			break
+		}
+	}
+	if history == nil {
+		return nil, fmt.Errorf("expense %s is not in its chain", expenseID)
+	}
+
+	for _, event := range chain {
+		revision := domain.ExpenseRevision{
+			EventID:   event.EventID,
+			EventType: event.EventType,
+			UserID:    event.UserID,
+			HLC:       event.HLC,
+			CreatedAt: event.CreatedAt,
+		}
+
+		switch event.EventType {
+		case util.ExpenseCreated, util.ExpenseUpdated:
+			fields, err := expenseFields(event.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s event %s: %w", event.EventType, event.EventID, err)
+			}
+			if event.EventType == util.ExpenseUpdated {
+				revision.Changes = diffFields(current, fields)
+			}
+			current = fields
+		case util.ExpenseDeleted:
+			history.Deleted = true
+		case util.ExpenseRestored:
+			history.Deleted = false
+		}
+
+		if current != nil {
+			encoded, err := json.Marshal(current)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode expense: %w", err)
+			}
+			revision.Expense = encoded
+			history.Expense = encoded
+		}
+		history.Revisions = append(history.Revisions, revision)
+	}
+
+	return history, nil
+}
+
+// expenseFields decodes an expense payload into its fields, in the form they are
+// encoded by the events package, so equal values compare equal as JSON
+func expenseFields(payload json.RawMessage) (map[string]json.RawMessage, error) {
+	var expense events.ExpenseCreated
+	if err := json.Unmarshal(payload, &expense); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(expense)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffFields returns the fields whose values differ between from and to, by name
+func diffFields(from map[string]json.RawMessage, to map[string]json.RawMessage) []domain.FieldChange {
+	var changes []domain.FieldChange
+	for field, value := range to {
+		if previous := from[field]; !bytes.Equal(previous, value) {
+			changes = append(changes, domain.FieldChange{Field: field, From: previous, To: value})
+		}
+	}
+	slices.SortFunc(changes, func(a, b domain.FieldChange) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+	return changes
+}
@@ -14,21 +14,28 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"log/slog"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
+	"github.com/RealZimboGuy/budgetApp/internal/config"
 	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/metrics"
 	"github.com/RealZimboGuy/budgetApp/internal/models/events"
 	"github.com/RealZimboGuy/budgetApp/internal/repository"
+	"github.com/RealZimboGuy/budgetApp/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // FirebaseService handles sending push notifications to Firebase
 type FirebaseService struct {
-	UserRepo *repository.UserRepository
+	UserRepo repository.UserStore
+	Config   config.FirebaseConfig
 }
 
 type ServiceAccount struct {
@@ -37,9 +44,10 @@ type ServiceAccount struct {
 }
 
 // NewFirebaseService creates a new Firebase service
-func NewFirebaseService(userRepo *repository.UserRepository, apiKey string) *FirebaseService {
+func NewFirebaseService(userRepo repository.UserStore, firebaseConfig config.FirebaseConfig) *FirebaseService {
 	return &FirebaseService{
 		UserRepo: userRepo,
+		Config:   firebaseConfig,
 	}
 }
 
@@ -61,13 +69,13 @@ type FirebaseMessage struct {
 func (s *FirebaseService) SendNotification(ctx context.Context, userID, title, body string, data map[string]string, accessToken string) error {
 	user, err := s.UserRepo.GetByID(ctx, userID)
 	if err != nil {
-		slog.Error("Failed to get user", "error", err)
+		slog.ErrorContext(ctx, "Failed to get user", "error", err)
 		return fmt.Errorf("failed to get user: %w", err)
 	}
 
 	if !user.FirebaseID.Valid || user.FirebaseID.String == "" {
 		// User doesn't have a Firebase token
-		slog.Warn("User doesn't have a Firebase token", "user_id", userID)
+		slog.WarnContext(ctx, "User doesn't have a Firebase token", "user_id", userID)
 		return fmt.Errorf("user %s doesn't have a Firebase token", userID)
 	}
 
@@ -80,18 +88,20 @@ func (s *FirebaseService) SendNotification(ctx context.Context, userID, title, b
 		Data: data,
 	}
 
-	return s.sendMessage(message, accessToken)
+	err = s.sendMessage(ctx, message, accessToken)
+	metrics.NotificationResult(metrics.ChannelPush, err)
+	return err
 }
 
 // SendNotificationToMultipleUsers sends a notification to multiple users
 func (s *FirebaseService) SendNotificationToMultipleUsers(ctx context.Context, userIDs []string, title, body string, data map[string]string) {
 
-	slog.Info("Sending notification to multiple users", "user_ids", userIDs)
+	slog.InfoContext(ctx, "Sending notification to multiple users", "user_ids", userIDs)
 
-	err, accessToken := authenticateGoogle()
+	err, accessToken := authenticateGoogle(s.Config.ServiceAccount, s.Config.TokenURL)
 
 	if err != nil {
-		slog.Error("Failed to authenticate with Google", "error", err)
+		slog.ErrorContext(ctx, "Failed to authenticate with Google", "error", err)
 		return
 	}
 
@@ -103,7 +113,7 @@ func (s *FirebaseService) SendNotificationToMultipleUsers(ctx context.Context, u
 			defer wg.Done()
 			err := s.SendNotification(ctx, uid, title, body, data, accessToken)
 			if err != nil {
-				log.Printf("Failed to send notification to user %s: %v", uid, err)
+				slog.ErrorContext(ctx, "Failed to send notification", "user_id", uid, "error", err)
 			}
 		}(userID)
 	}
@@ -111,27 +121,49 @@ func (s *FirebaseService) SendNotificationToMultipleUsers(ctx context.Context, u
 	wg.Wait()
 }
 
+// SendNotificationToUser authenticates with Google and sends a notification to a single user
+func (s *FirebaseService) SendNotificationToUser(ctx context.Context, userID, title, body string, data map[string]string) error {
+	err, accessToken := authenticateGoogle(s.Config.ServiceAccount, s.Config.TokenURL)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with Google: %w", err)
+	}
+
+	return s.SendNotification(ctx, userID, title, body, data, accessToken)
+}
+
 // sendMessage sends a Firebase message
-func (s *FirebaseService) sendMessage(message FirebaseMessage, token string) error {
+func (s *FirebaseService) sendMessage(ctx context.Context, message FirebaseMessage, token string) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "fcm.send", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
 	fcmRequest := FCMRequest{Message: message}
 
-	slog.Info("Sending Firebase message", "message", fcmRequest)
+	slog.InfoContext(ctx, "Sending Firebase message", "message", fcmRequest)
 	jsonData, err := json.Marshal(fcmRequest)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	firebaseUrl := os.Getenv("FIREBASE_URL")
+	firebaseUrl := s.Config.URL
 	if firebaseUrl == "" {
-		return fmt.Errorf("FIREBASE_URL environment variable is not set")
+		return fmt.Errorf("firebase URL is not configured")
 	}
-	slog.Info("Sending Firebase message", "firebase_url", firebaseUrl)
+	slog.InfoContext(ctx, "Sending Firebase message", "firebase_url", firebaseUrl)
 
-	req, err := http.NewRequest("POST", firebaseUrl, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", firebaseUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	span.SetAttributes(attribute.String("server.address", req.URL.Host))
+
+	// Propagate the trace to the callee
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
@@ -142,11 +174,12 @@ func (s *FirebaseService) sendMessage(message FirebaseMessage, token string) err
 		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		//rpint the body if it exists
 		body, _ := ioutil.ReadAll(resp.Body)
-		slog.Error("Failed to send Firebase message", "status", resp.Status, "body", string(body))
+		slog.ErrorContext(ctx, "Failed to send Firebase message", "status", resp.Status, "body", string(body))
 		return fmt.Errorf("bad status: %s", resp.Status)
 	}
 
@@ -210,16 +243,17 @@ func (s *FirebaseService) ProcessExpenseCreatedEvent(ctx context.Context, event
 		"type":     "expense_created",
 	}
 
-	// Send notifications in goroutines
-	go s.SendNotificationToMultipleUsers(context.Background(), userIDs, title, body, data)
+	// Send notifications, each user in its own goroutine
+	s.SendNotificationToMultipleUsers(ctx, userIDs, title, body, data)
 
 	return nil
 }
 
-func authenticateGoogle() (error, string) {
-	data := os.Getenv("GOOGLE_SERVICE_ACCOUNT")
+// authenticateGoogle exchanges a JWT signed with the service account's key for an
+// access token at tokenURL
+func authenticateGoogle(data string, tokenURL string) (error, string) {
 	if data == "" {
-		return errors.New("GOOGLE_SERVICE_ACCOUNT environment variable is not set"), ""
+		return errors.New("google service account is not configured"), ""
 	}
 
 	var sa ServiceAccount
@@ -262,7 +296,7 @@ func authenticateGoogle() (error, string) {
 
 	// Exchange JWT for access token
 	form := fmt.Sprintf("grant_type=urn:ietf:params:oauth:grant-type:jwt-bearer&assertion=%s", jwt)
-	resp, err := http.Post("https://oauth2.googleapis.com/token",
+	resp, err := http.Post(tokenURL,
 		"application/x-www-form-urlencoded",
 		bytes.NewBuffer([]byte(form)))
 	if err != nil {
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/internal/config"
+	"github.com/RealZimboGuy/budgetApp/internal/domain"
+	"github.com/RealZimboGuy/budgetApp/internal/metrics"
+	"github.com/RealZimboGuy/budgetApp/internal/repository"
+)
+
+// Webhook request headers
+const (
+	WebhookIDHeader        = "X-SimpleSplit-Webhook-ID"
+	WebhookDeliveryHeader  = "X-SimpleSplit-Delivery"
+	WebhookEventHeader     = "X-SimpleSplit-Event"
+	WebhookTimestampHeader = "X-SimpleSplit-Timestamp"
+	WebhookSignatureHeader = "X-SimpleSplit-Signature"
+)
+
+// ErrPrivateWebhookTarget is returned for webhook URLs that resolve to loopback,
+// private or link-local addresses
+var ErrPrivateWebhookTarget = errors.New("webhook target is not a public address")
+
+// WebhookService delivers group events to registered webhooks
+type WebhookService struct {
+	WebhookRepo repository.WebhookStore
+	Client      *http.Client
+	// MaxAttempts is the number of times a delivery is tried before it is marked as failed
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled on every following retry
+	InitialBackoff time.Duration
+	// DisableAfterFailures deactivates a webhook whose last n deliveries failed, 0 never does
+	DisableAfterFailures int
+	// AllowPrivateTargets skips the checks that webhooks only reach public addresses
+	AllowPrivateTargets bool
+	// Resolver looks up webhook hosts when they are registered
+	Resolver *net.Resolver
+}
+
+// NewWebhookService creates a new webhook service. Unless private targets are
+// allowed, its client refuses to connect to anything but public addresses, which
+// also covers redirects and hosts that resolve differently after registration.
+func NewWebhookService(webhookRepo repository.WebhookStore, webhookConfig config.WebhookConfig) *WebhookService {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !webhookConfig.AllowPrivateTargets {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublicOnly}
+		transport.DialContext = dialer.DialContext
+		// A proxy would be dialed instead of the target, and the check would pass
+		transport.Proxy = nil
+	}
+
+	return &WebhookService{
+		WebhookRepo:          webhookRepo,
+		Client:               &http.Client{Timeout: webhookConfig.Timeout.Std(), Transport: transport},
+		MaxAttempts:          webhookConfig.MaxAttempts,
+		InitialBackoff:       webhookConfig.InitialBackoff.Std(),
+		DisableAfterFailures: webhookConfig.DisableAfterFailures,
+		AllowPrivateTargets:  webhookConfig.AllowPrivateTargets,
+		Resolver:             net.DefaultResolver,
+	}
+}
+
+// CheckTarget resolves the host of a webhook URL and returns ErrPrivateWebhookTarget
+// when any of its addresses is not public
+func (s *WebhookService) CheckTarget(ctx context.Context, target *url.URL) error {
+	if s.AllowPrivateTargets {
+		return nil
+	}
+
+	addrs, err := s.Resolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateWebhookTarget, target.Hostname(), addr)
+		}
+	}
+	return nil
+}
+
+// dialPublicOnly is a net.Dialer Control function refusing connections to
+// addresses that are not public
+func dialPublicOnly(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateWebhookTarget, address)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateWebhookTarget, addrPort.Addr())
+	}
+	return nil
+}
+
+// isPublicAddr reports whether addr is not a loopback, private, link-local,
+// multicast or unspecified address
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() && !addr.IsMulticast() && !addr.IsUnspecified()
+}
+
+// webhookEnvelope is the JSON body posted to a webhook
+type webhookEnvelope struct {
+	WebhookID string        `json:"webhook_id"`
+	Event     *domain.Event `json:"event"`
+}
+
+// GenerateWebhookSecret creates a new random signing secret
+func GenerateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// SignWebhookPayload computes the hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// DispatchEvent delivers an event to every active webhook registered for its group.
+// Webhooks are delivered concurrently and DispatchEvent returns once all deliveries have finished.
+func (s *WebhookService) DispatchEvent(ctx context.Context, event *domain.Event) {
+	webhooks, err := s.WebhookRepo.GetByGroupID(ctx, event.GroupID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get webhooks for group", "group_id", event.GroupID, "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, webhook := range webhooks {
+		if !webhook.Active {
+			continue
+		}
+
+		payload, err := json.Marshal(webhookEnvelope{WebhookID: webhook.WebhookID, Event: event})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to marshal webhook payload", "webhook_id", webhook.WebhookID, "error", err)
+			continue
+		}
+
+		delivery := domain.NewWebhookDelivery(webhook.WebhookID, event.EventID, payload)
+		if err := s.WebhookRepo.CreateDelivery(ctx, delivery); err != nil {
+			slog.ErrorContext(ctx, "Failed to record webhook delivery", "webhook_id", webhook.WebhookID, "error", err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(webhook *domain.Webhook) {
+			defer wg.Done()
+			s.Deliver(ctx, webhook, delivery)
+		}(webhook)
+	}
+}
+
+// Replay records a new delivery with the same payload as an earlier one
+func (s *WebhookService) Replay(ctx context.Context, original *domain.WebhookDelivery) (*domain.WebhookDelivery, error) {
+	delivery := domain.NewWebhookDelivery(original.WebhookID, original.EventID, original.Payload)
+	delivery.ReplayOf = sql.NullString{String: original.DeliveryID, Valid: true}
+
+	if err := s.WebhookRepo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// Deliver posts a delivery to its webhook, retrying with exponential backoff
+func (s *WebhookService) Deliver(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) {
+	backoff := s.InitialBackoff
+
+	for delivery.Attempts < s.MaxAttempts {
+		delivery.Attempts++
+
+		status, err := s.send(ctx, webhook, delivery)
+		if status != 0 {
+			delivery.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: true}
+		}
+
+		retry := false
+		if err == nil {
+			delivery.Status = domain.DeliverySucceeded
+			delivery.LastError = sql.NullString{}
+		} else {
+			delivery.LastError = sql.NullString{String: err.Error(), Valid: true}
+			// A refused target stays refused, so it isn't retried
+			retry = delivery.Attempts < s.MaxAttempts && isRetryableStatus(status) && !errors.Is(err, ErrPrivateWebhookTarget)
+			if retry {
+				delivery.Status = domain.DeliveryPending
+			} else {
+				delivery.Status = domain.DeliveryFailed
+			}
+		}
+
+		if err := s.WebhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+			slog.ErrorContext(ctx, "Failed to update webhook delivery", "delivery_id", delivery.DeliveryID, "error", err)
+		}
+
+		if !retry {
+			metrics.NotificationResult(metrics.ChannelWebhook, err)
+			if delivery.Status == domain.DeliveryFailed {
+				slog.WarnContext(ctx, "Webhook delivery failed", "delivery_id", delivery.DeliveryID, "webhook_id", webhook.WebhookID, "attempts", delivery.Attempts)
+				s.deactivateIfFailing(ctx, webhook)
+			}
+			return
+		}
+
+		// Stop retrying when the server shuts down, the delivery stays PENDING and can be replayed
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "Webhook delivery interrupted", "delivery_id", delivery.DeliveryID, "attempts", delivery.Attempts)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// deactivateIfFailing deactivates a webhook when its last DisableAfterFailures
+// deliveries all failed. The group owner can turn it back on.
+func (s *WebhookService) deactivateIfFailing(ctx context.Context, webhook *domain.Webhook) {
+	if s.DisableAfterFailures <= 0 {
+		return
+	}
+
+	deliveries, err := s.WebhookRepo.GetDeliveriesByWebhookID(ctx, webhook.WebhookID, s.DisableAfterFailures)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get webhook deliveries", "webhook_id", webhook.WebhookID, "error", err)
+		return
+	}
+	if len(deliveries) < s.DisableAfterFailures {
+		return
+	}
+	for _, delivery := range deliveries {
+		if delivery.Status != domain.DeliveryFailed {
+			return
+		}
+	}
+
+	if err := s.WebhookRepo.SetActive(ctx, webhook.WebhookID, false); err != nil {
+		slog.ErrorContext(ctx, "Failed to deactivate webhook", "webhook_id", webhook.WebhookID, "error", err)
+		return
+	}
+	webhook.Active = false
+	slog.WarnContext(ctx, "Webhook deactivated after repeated failures", "webhook_id", webhook.WebhookID, "failures", len(deliveries))
+}
+
+// send performs a single delivery attempt and returns the response status code
+func (s *WebhookService) send(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	now := time.Now()
+	timestamp := now.Unix()
+
+	var signatures []string
+	for _, secret := range webhook.SigningSecrets(now) {
+		signatures = append(signatures, "sha256="+SignWebhookPayload(secret, timestamp, delivery.Payload))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var envelope webhookEnvelope
+	if err := json.Unmarshal(delivery.Payload, &envelope); err == nil && envelope.Event != nil {
+		req.Header.Set(WebhookEventHeader, string(envelope.Event.EventType))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SimpleSplit-Webhooks/1.0")
+	req.Header.Set(WebhookIDHeader, webhook.WebhookID)
+	req.Header.Set(WebhookDeliveryHeader, delivery.DeliveryID)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, strings.Join(signatures, ","))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// isRetryableStatus reports whether a failed attempt is worth retrying.
+// Network errors (status 0), rate limiting and server errors are retried, other client errors are not.
+func isRetryableStatus(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
@@ -14,6 +14,10 @@ const (
 	// Group events
 	GroupCreate      EventType = "GROUP_CREATED"
 	GroupAddCurrency EventType = "GROUP_ADD_CURRENCY"
+	// GroupRemoveCurrency disables a currency, which must have no outstanding balances
+	GroupRemoveCurrency EventType = "GROUP_REMOVE_CURRENCY"
+	// GroupSetDefaultCurrency sets the currency new expenses start in
+	GroupSetDefaultCurrency EventType = "GROUP_SET_DEFAULT_CURRENCY"
 	//UpdateGroup      EventType = "GROUP_UPDATE"
 	GroupUserJoined EventType = "GROUP_USER_JOINED"
 
@@ -23,17 +27,37 @@ const (
 	ExpenseCreated EventType = "EXPENSE_CREATED"
 	ExpenseUpdated EventType = "EXPENSE_UPDATED"
 	ExpenseDeleted EventType = "EXPENSE_DELETED"
+	// ExpenseRestored undoes the deletion of an expense
+	ExpenseRestored EventType = "EXPENSE_RESTORED"
 )
 
+// IsExpense reports whether events of the type make up the revision chain of an expense
+func (t EventType) IsExpense() bool {
+	return t == ExpenseCreated || t == ExpenseUpdated || t == ExpenseDeleted || t == ExpenseRestored
+}
+
+// IsCurrencyChange reports whether events of the type change the currencies of a group
+func (t EventType) IsCurrencyChange() bool {
+	return t == GroupAddCurrency || t == GroupRemoveCurrency || t == GroupSetDefaultCurrency
+}
+
 // Database represents a database connection
 type Database struct {
 	DB *sql.DB
+	// System names the database in query spans, "postgresql" when empty
+	System string
+	// Isolation is the isolation level of the transactions started by InTx
+	Isolation sql.IsolationLevel
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(db *sql.DB) *Database {
 	return &Database{
 		DB: db,
+		// Statements in a read committed transaction see rows committed by others
+		// while it runs, so a unit of work that loses an ON CONFLICT race can read
+		// the winning row
+		Isolation: sql.LevelReadCommitted,
 	}
 }
 
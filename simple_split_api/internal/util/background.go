@@ -0,0 +1,64 @@
+package util
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// Background tracks work that outlives the request that started it, such as
+// sending notifications, so that shutdown can wait for it to finish
+type Background struct {
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewBackground creates a new background task tracker
+func NewBackground() *Background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Background{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Go runs fn in a new goroutine. The context passed to fn is cancelled when
+// Shutdown gives up waiting, so long running work should watch it.
+func (b *Background) Go(fn func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				slog.Error("Panic recovered in background task", "error", err, "stack", string(debug.Stack()))
+			}
+		}()
+		fn(b.ctx)
+	}()
+}
+
+// Wait blocks until all running tasks have finished, without cancelling them
+func (b *Background) Wait() {
+	b.wg.Wait()
+}
+
+// Shutdown waits for all running tasks to finish. If ctx expires first the
+// tasks' context is cancelled and ctx's error is returned.
+func (b *Background) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
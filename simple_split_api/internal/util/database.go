@@ -0,0 +1,124 @@
+package util
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/RealZimboGuy/budgetApp/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// txKey is the context key of the transaction InTx started on a database
+type txKey struct {
+	db *Database
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// InTx runs fn as a unit of work in a single transaction. Statements run through d
+// with the context passed to fn are part of the transaction, which is committed when
+// fn returns nil and rolled back otherwise. Calling InTx inside fn joins the
+// transaction already running.
+func (d *Database) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{d}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := d.DB.BeginTx(ctx, &sql.TxOptions{Isolation: d.Isolation})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{d}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// conn returns the transaction InTx is running for ctx, or the database outside one
+func (d *Database) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{d}).(*sql.Tx); ok {
+		return tx
+	}
+	return d.DB
+}
+
+// ExecContext runs a statement that returns no rows, recording a trace span for it
+func (d *Database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, d.System, query)
+	defer span.End()
+
+	result, err := d.conn(ctx).ExecContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+// QueryContext runs a query that returns rows, recording a trace span for it.
+// The span covers executing the query, not iterating the rows.
+func (d *Database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, d.System, query)
+	defer span.End()
+
+	rows, err := d.conn(ctx).QueryContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+// QueryRowContext runs a query that returns at most one row, recording a trace span for it
+func (d *Database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, d.System, query)
+	defer span.End()
+
+	row := d.conn(ctx).QueryRowContext(ctx, query, args...)
+	endQuerySpan(span, row.Err())
+	return row
+}
+
+func startQuerySpan(ctx context.Context, system string, query string) (context.Context, trace.Span) {
+	if system == "" {
+		system = "postgresql"
+	}
+	query = strings.TrimSpace(query)
+	operation := ""
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	return tracing.Tracer().Start(ctx, "db "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", system),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	// No rows is an expected outcome for lookups, not a failed query
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,190 @@
+// Package client is a Go client for the Simple Split API.
+//
+//	c := client.New("https://split.example.com")
+//	user, err := c.CreateUser(ctx, "Alice")
+//
+// Requests that are safe to repeat are retried with exponential backoff when the
+// server can't be reached, is rate limiting or answers with a server error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultMaxAttempts is the number of times a request is sent before giving up
+	DefaultMaxAttempts = 4
+	// DefaultInitialBackoff is the wait before the first retry, doubled on every following retry
+	DefaultInitialBackoff = 200 * time.Millisecond
+	// DefaultMaxBackoff caps the wait between retries
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// Client calls the Simple Split API. It is safe for concurrent use.
+type Client struct {
+	baseURL        string
+	httpClient     *http.Client
+	userAgent      string
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetry sets how often and how patiently requests are retried. A maxAttempts of 1 disables retries.
+func WithRetry(maxAttempts int, initialBackoff time.Duration, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New creates a client for the API at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		userAgent:      "SimpleSplit-Go-Client/1.0",
+		maxAttempts:    DefaultMaxAttempts,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// idempotencyKey returns the header making a creation request safe to retry.
+// Every attempt sends the same key, so the server replays the first response.
+func idempotencyKey() http.Header {
+	return http.Header{"Idempotency-Key": {uuid.NewString()}}
+}
+
+// request describes a single API call
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	header http.Header
+	// idempotent requests can be sent again when the outcome of an attempt is unknown
+	idempotent bool
+}
+
+// do sends the request, retrying idempotent ones, and decodes the response into out unless it is nil
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		body, err = json.Marshal(req.body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	backoff := c.initialBackoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := c.send(ctx, req.method, target, req.header, body, out)
+		if err == nil {
+			return nil
+		}
+		if !req.idempotent || attempt >= c.maxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		wait := min(backoff, c.maxBackoff)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+		backoff *= 2
+	}
+}
+
+// send performs a single attempt. It returns how long the server asked to wait
+// before retrying, if it did.
+func (c *Client) send(ctx context.Context, method string, target string, header http.Header, body []byte, out any) (time.Duration, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		httpReq.Header[name] = values
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, &networkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseRetryAfter(resp.Header.Get("Retry-After")), decodeError(resp)
+	}
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return 0, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,249 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/pkg/client"
+)
+
+// newServer serves handler and returns a client of it, with short backoffs unless opts say otherwise
+func newServer(t *testing.T, handler http.HandlerFunc, opts ...client.Option) *client.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	opts = append([]client.Option{client.WithRetry(4, time.Millisecond, 5*time.Millisecond)}, opts...)
+	return client.New(server.URL, opts...)
+}
+
+// problem writes an error response the way the API does
+func problem(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"status": status, "title": http.StatusText(status), "code": code})
+}
+
+func TestRetry(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		failures []int
+		code     string
+		attempts int
+		ok       bool
+	}{
+		{name: "server errors", failures: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}, attempts: 3, ok: true},
+		{name: "rate limited", failures: []int{http.StatusTooManyRequests}, attempts: 2, ok: true},
+		{name: "request in progress", failures: []int{http.StatusConflict}, code: "request_in_progress", attempts: 2, ok: true},
+		{name: "gives up", failures: []int{500, 500, 500, 500, 500}, attempts: 4},
+		{name: "client error", failures: []int{http.StatusBadRequest}, code: "validation_failed", attempts: 1},
+		{name: "conflict", failures: []int{http.StatusConflict}, code: "user_exists", attempts: 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var keys []string
+			c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				attempt := len(keys)
+				keys = append(keys, r.Header.Get("Idempotency-Key"))
+				mu.Unlock()
+				if attempt < len(test.failures) {
+					problem(w, test.failures[attempt], test.code)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]any{"user_id": "u1", "name": "Ann"})
+			})
+
+			user, err := c.CreateUser(context.Background(), "Ann")
+			mu.Lock()
+			defer mu.Unlock()
+			if len(keys) != test.attempts {
+				t.Errorf("sent %d attempts, want %d", len(keys), test.attempts)
+			}
+			if !test.ok {
+				var apiErr *client.Error
+				if !errors.As(err, &apiErr) || apiErr.Status != test.failures[0] {
+					t.Fatalf("got %v, want a %d error", err, test.failures[0])
+				}
+				return
+			}
+			if err != nil || user.UserID != "u1" {
+				t.Fatalf("got %+v, %v", user, err)
+			}
+			for _, key := range keys {
+				if key == "" || key != keys[0] {
+					t.Errorf("attempts sent Idempotency-Keys %q, want one key", keys)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	c := client.New(server.URL, client.WithRetry(2, time.Millisecond, time.Millisecond))
+
+	_, err := c.GetUser(context.Background(), "u1")
+	var apiErr *client.Error
+	if err == nil || errors.As(err, &apiErr) {
+		t.Errorf("got %v, want a network error", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var attempts atomic.Int32
+	// Waits 20ms, then 40ms capped to 30ms, then 30ms
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		problem(w, http.StatusServiceUnavailable, "")
+	}, client.WithRetry(4, 20*time.Millisecond, 30*time.Millisecond))
+	start := time.Now()
+	if _, err := c.GetUser(context.Background(), "u1"); err == nil {
+		t.Fatal("request succeeded")
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > time.Second {
+		t.Errorf("4 attempts took %v, want about 80ms", elapsed)
+	}
+	if attempts.Load() != 4 {
+		t.Errorf("sent %d attempts, want 4", attempts.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			problem(w, http.StatusTooManyRequests, "")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"user_id": "u1"})
+	})
+
+	// Retry-After overrides the shorter backoff
+	start := time.Now()
+	if _, err := c.GetUser(context.Background(), "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s of Retry-After", elapsed)
+	}
+
+	// Cancelling the context stops waiting
+	c = newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		problem(w, http.StatusServiceUnavailable, "")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetUser(ctx, "u1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+}
+
+// eventLog serves the events of group g1 in pages of two, like the API does,
+// and returns the after_id of every page requested
+func eventLog(t *testing.T, count int) (*client.Client, func() []string) {
+	var ids []string
+	for i := range count {
+		ids = append(ids, fmt.Sprintf("e%d", i+1))
+	}
+	var mu sync.Mutex
+	var requested []string
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/groups/g1/events" {
+			problem(w, http.StatusNotFound, "group_not_found")
+			return
+		}
+		afterID := r.URL.Query().Get("after_id")
+		mu.Lock()
+		requested = append(requested, afterID)
+		mu.Unlock()
+		start := 0
+		for i, id := range ids {
+			if id == afterID {
+				start = i + 1
+			}
+		}
+		page := []map[string]any{}
+		for _, id := range ids[start:min(start+2, len(ids))] {
+			page = append(page, map[string]any{"event_id": id, "group_id": "g1", "event_type": client.ExpenseCreated})
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+	return c, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
+
+func TestEventPager(t *testing.T) {
+	c, requested := eventLog(t, 3)
+	ctx := context.Background()
+
+	pager := c.Events("g1", "")
+	var read []string
+	for {
+		events, err := pager.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, event := range events {
+			read = append(read, event.EventID)
+		}
+	}
+	if fmt.Sprint(read) != "[e1 e2 e3]" || pager.Cursor() != "e3" {
+		t.Errorf("read %v up to %q, want [e1 e2 e3] up to e3", read, pager.Cursor())
+	}
+	if got := requested(); fmt.Sprint(got) != "[ e2 e3]" {
+		t.Errorf("requested pages after %q", got)
+	}
+
+	// Resuming from the cursor only returns later events
+	events, err := c.Events("g1", pager.Cursor()).Next(ctx)
+	if err != nil || len(events) != 0 {
+		t.Errorf("resumed with %d events, %v", len(events), err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	c, _ := eventLog(t, 5)
+	ctx := context.Background()
+
+	var applied []string
+	cursor, err := c.Sync(ctx, "g1", "e1", func(event *client.Event) error {
+		applied = append(applied, event.EventID)
+		return nil
+	})
+	if err != nil || cursor != "e5" || fmt.Sprint(applied) != "[e2 e3 e4 e5]" {
+		t.Errorf("applied %v up to %q, %v", applied, cursor, err)
+	}
+
+	// A failing apply stops the sync at the last event applied
+	failure := errors.New("disk full")
+	cursor, err = c.Sync(ctx, "g1", "", func(event *client.Event) error {
+		if event.EventID == "e4" {
+			return failure
+		}
+		return nil
+	})
+	if !errors.Is(err, failure) || cursor != "e3" {
+		t.Errorf("stopped at %q with %v, want e3 and the apply error", cursor, err)
+	}
+
+	if _, err := c.Sync(ctx, "g2", "", func(*client.Event) error { return nil }); !client.IsNotFound(err) {
+		t.Errorf("unknown group: got %v", err)
+	}
+}
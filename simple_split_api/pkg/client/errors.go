@@ -0,0 +1,104 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Error is an error response from the API. Its fields follow the RFC 7807
+// problem details the API returns.
+type Error struct {
+	Status int    `json:"status"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	// Code identifies the error, e.g. "group_not_found"
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+	// GroupVersion and Head are the current state of the group and of the linked
+	// event chain, sent with precondition_failed errors
+	GroupVersion int64  `json:"group_version"`
+	Head         *Event `json:"head"`
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("simplesplit: %d %s", e.Status, e.Title)
+	if e.Code != "" {
+		message += " (" + e.Code + ")"
+	}
+	if e.Detail != "" {
+		message += ": " + e.Detail
+	}
+	return message
+}
+
+// IsNotFound reports whether err is an API error saying the requested resource doesn't exist
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an API error saying the resource already exists
+func IsConflict(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict && apiErr.Code != "precondition_failed"
+}
+
+// IsPreconditionFailed reports whether err is an API error refusing an event because
+// the group or the linked event chain changed since the expected state. The Error
+// carries the current state.
+func IsPreconditionFailed(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict && apiErr.Code == "precondition_failed"
+}
+
+// networkError is a failure to get any response from the server
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return fmt.Sprintf("simplesplit: failed to send request: %v", e.err)
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+// isRetryable reports whether a failed attempt is worth retrying.
+// Network errors, rate limiting and server errors are retried, as is a request whose
+// Idempotency-Key is still held by an earlier attempt. Other client errors are not.
+func isRetryable(err error) bool {
+	var netErr *networkError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= 500 ||
+		(apiErr.Status == http.StatusConflict && apiErr.Code == "request_in_progress")
+}
+
+// decodeError builds an Error from an unsuccessful response
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	apiErr := &Error{}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Status == 0 {
+		// Not a problem response, e.g. from a proxy in front of the API
+		apiErr = &Error{Detail: strings.TrimSpace(string(body))}
+	}
+	apiErr.Status = resp.StatusCode
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	return apiErr
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+	"github.com/google/uuid"
+)
+
+// clock is the hybrid logical clock of this process. It stamps new events and
+// merges the HLCs of the events read from the server, so an event recorded after
+// reading another orders after it even when the device's clock is behind.
+var clock = hlc.NewClock(0)
+
+// NewEvent creates an event with a new client-generated ID, stamped with the HLC
+// of the moment it is recorded. The payload is encoded to JSON, e.g. an expense
+// for EXPENSE_CREATED events.
+func NewEvent(groupID string, userID string, eventType EventType, payload any) (*Event, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return &Event{
+		EventID:   uuid.NewString(),
+		GroupID:   groupID,
+		UserID:    userID,
+		EventType: eventType,
+		Payload:   encoded,
+		HLC:       clock.Now(),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// SortByHLC sorts events into the order they were recorded in, by HLC, then by
+// arrival and ID. Events are listed in the order they reached the server, so clients
+// folding them into state sort them first for offline edits to interleave
+// correctly with online ones.
+func SortByHLC(events []*Event) {
+	slices.SortFunc(events, func(a, b *Event) int {
+		if c := a.HLC.Compare(b.HLC); c != 0 {
+			return c
+		}
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.EventID, b.EventID)
+	})
+}
+
+// observe merges the HLCs of events read from the server into the clock
+func observe(events ...*Event) {
+	for _, event := range events {
+		clock.Update(event.HLC)
+	}
+}
+
+// Precondition is a state CreateEvent expects, the event is refused when the
+// server's state differs
+type Precondition func(body map[string]any)
+
+// ExpectGroupVersion refuses the event unless the group is still at version,
+// the Version of the group when the caller last read it
+func ExpectGroupVersion(version int64) Precondition {
+	return func(body map[string]any) {
+		body["expected_group_version"] = version
+	}
+}
+
+// ExpectHead refuses an expense event unless eventID is still the latest expense
+// event linked to the same expense, or the expense itself when nothing links to it yet
+func ExpectHead(eventID string) Precondition {
+	return func(body map[string]any) {
+		body["expected_head_id"] = eventID
+	}
+}
+
+// CreateEvent records an event. An event without an ID or HLC is given them first,
+// so the caller can send it again after a failure without recording it twice. The
+// server answers a repeated event ID with the event it already has, which makes the
+// request safe to retry. An event whose preconditions no longer hold is refused
+// with an error for which IsPreconditionFailed is true.
+func (c *Client) CreateEvent(ctx context.Context, event *Event, preconditions ...Precondition) (*Event, error) {
+	if event.EventID == "" {
+		event.EventID = uuid.NewString()
+	}
+	if event.HLC.IsZero() {
+		event.HLC = clock.Now()
+	}
+
+	body := map[string]any{
+		"event_id":        event.EventID,
+		"linked_event_id": event.LinkedEventID,
+		"user_id":         event.UserID,
+		"event_type":      event.EventType,
+		"payload":         event.Payload,
+		"hlc":             event.HLC,
+	}
+	for _, precondition := range preconditions {
+		precondition(body)
+	}
+
+	created := &Event{}
+	err := c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/groups/" + url.PathEscape(event.GroupID) + "/events",
+		body:       body,
+		idempotent: true,
+	}, created)
+	if IsConflict(err) {
+		// A concurrent attempt with the same ID won the race
+		return c.GetEvent(ctx, event.EventID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	observe(created)
+	return created, nil
+}
+
+// GetEvent returns an event
+func (c *Client) GetEvent(ctx context.Context, eventID string) (*Event, error) {
+	event := &Event{}
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/events/" + url.PathEscape(eventID),
+		idempotent: true,
+	}, event)
+	if err != nil {
+		return nil, err
+	}
+	observe(event)
+	return event, nil
+}
+
+// DeleteEvent removes an event from the log. Clients undo expenses with EXPENSE_DELETED
+// events instead, this is for cleaning up data.
+func (c *Client) DeleteEvent(ctx context.Context, eventID string) error {
+	return c.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       "/api/v2/events/" + url.PathEscape(eventID),
+		idempotent: true,
+	}, nil)
+}
+
+// ListEvents returns one page of the events of a group created after the event
+// afterID, in the order they were created. An empty afterID starts at the first event.
+// SortByHLC puts them in the order they were recorded.
+func (c *Client) ListEvents(ctx context.Context, groupID string, afterID string) ([]*Event, error) {
+	query := url.Values{}
+	if afterID != "" {
+		query.Set("after_id", afterID)
+	}
+
+	var events []*Event
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/groups/" + url.PathEscape(groupID) + "/events",
+		query:      query,
+		idempotent: true,
+	}, &events)
+	if err != nil {
+		return nil, err
+	}
+	observe(events...)
+	return events, nil
+}
+
+// EventPager pages through the events of a group in the order they were created
+type EventPager struct {
+	client  *Client
+	groupID string
+	cursor  string
+}
+
+// Events returns a pager over the events of a group created after the event afterID.
+// An empty afterID starts at the first event.
+func (c *Client) Events(groupID string, afterID string) *EventPager {
+	return &EventPager{client: c, groupID: groupID, cursor: afterID}
+}
+
+// Next returns the next page of events. An empty page means every event has been
+// read, calling Next again later returns the events recorded since.
+func (p *EventPager) Next(ctx context.Context) ([]*Event, error) {
+	events, err := p.client.ListEvents(ctx, p.groupID, p.cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		p.cursor = events[len(events)-1].EventID
+	}
+	return events, nil
+}
+
+// Cursor returns the ID of the last event read. Pass it to Events to resume from there.
+func (p *EventPager) Cursor() string {
+	return p.cursor
+}
+
+// Sync passes every event of a group created after the event afterID to apply, in
+// the order they were created, and returns the ID of the last event applied. It stops
+// at the first error, the returned ID then is that of the last event applied successfully.
+// Events recorded offline may arrive after later ones, so apply should store events
+// rather than fold them into state, which is rebuilt in SortByHLC order.
+func (c *Client) Sync(ctx context.Context, groupID string, afterID string, apply func(*Event) error) (string, error) {
+	pager := c.Events(groupID, afterID)
+	lastApplied := afterID
+	for {
+		events, err := pager.Next(ctx)
+		if err != nil {
+			return lastApplied, err
+		}
+		if len(events) == 0 {
+			return lastApplied, nil
+		}
+		for _, event := range events {
+			if err := apply(event); err != nil {
+				return lastApplied, fmt.Errorf("failed to apply event %s: %w", event.EventID, err)
+			}
+			lastApplied = event.EventID
+		}
+	}
+}
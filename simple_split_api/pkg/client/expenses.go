@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// NewExpenseUpdate creates an EXPENSE_UPDATED event replacing the expense recorded
+// by the EXPENSE_CREATED event expenseID with update, the whole expense as edited
+// in the form of an EXPENSE_CREATED payload
+func NewExpenseUpdate(groupID string, userID string, expenseID string, update any) (*Event, error) {
+	event, err := NewEvent(groupID, userID, ExpenseUpdated, update)
+	if err != nil {
+		return nil, err
+	}
+	event.LinkedEventID = expenseID
+	return event, nil
+}
+
+// GetExpenseHistory returns the revisions of the expense recorded by the
+// EXPENSE_CREATED event expenseID
+func (c *Client) GetExpenseHistory(ctx context.Context, expenseID string) (*ExpenseHistory, error) {
+	history := &ExpenseHistory{}
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/expenses/" + url.PathEscape(expenseID) + "/history",
+		idempotent: true,
+	}, history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ListExpenses returns the expenses of a group as last edited, in the order they
+// were recorded in. An empty status returns both active and deleted expenses.
+func (c *Client) ListExpenses(ctx context.Context, groupID string, status ExpenseStatus) ([]*Expense, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+
+	var expenses []*Expense
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/groups/" + url.PathEscape(groupID) + "/expenses",
+		query:      query,
+		idempotent: true,
+	}, &expenses)
+	if err != nil {
+		return nil, err
+	}
+	return expenses, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CreateGroup creates a group. It is sent with an Idempotency-Key, so retries never create a second group.
+// Clients usually follow it with a GROUP_CREATED event.
+func (c *Client) CreateGroup(ctx context.Context, name string) (*Group, error) {
+	group := &Group{}
+	err := c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/groups",
+		body:       map[string]string{"name": name},
+		header:     idempotencyKey(),
+		idempotent: true,
+	}, group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// CreateGroupWithID creates a group with an ID chosen by the caller, a version 4 or 7
+// UUID such as uuid.NewString(). A group created offline can be given events before
+// it is sent. Sending it again returns the stored group, so it is retried.
+func (c *Client) CreateGroupWithID(ctx context.Context, groupID string, name string) (*Group, error) {
+	group := &Group{}
+	err := c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/groups",
+		body:       map[string]string{"group_id": groupID, "name": name},
+		idempotent: true,
+	}, group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// GetGroup returns a group
+func (c *Client) GetGroup(ctx context.Context, groupID string) (*Group, error) {
+	group := &Group{}
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/groups/" + url.PathEscape(groupID),
+		idempotent: true,
+	}, group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// UpdateGroup renames a group
+func (c *Client) UpdateGroup(ctx context.Context, groupID string, name string) (*Group, error) {
+	group := &Group{}
+	err := c.do(ctx, request{
+		method:     http.MethodPut,
+		path:       "/api/v2/groups/" + url.PathEscape(groupID),
+		body:       map[string]string{"name": name},
+		idempotent: true,
+	}, group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// DeleteGroup deletes a group
+func (c *Client) DeleteGroup(ctx context.Context, groupID string) error {
+	return c.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       "/api/v2/groups/" + url.PathEscape(groupID),
+		idempotent: true,
+	}, nil)
+}
+
+// GetGroupsByUser returns the groups a user has joined
+func (c *Client) GetGroupsByUser(ctx context.Context, userID string) ([]*Group, error) {
+	var groups []*Group
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/users/" + url.PathEscape(userID) + "/groups",
+		idempotent: true,
+	}, &groups)
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/RealZimboGuy/budgetApp/pkg/hlc"
+)
+
+// EventType is the type of an event
+type EventType string
+
+const (
+	GroupCreated            EventType = "GROUP_CREATED"
+	GroupAddCurrency        EventType = "GROUP_ADD_CURRENCY"
+	GroupRemoveCurrency     EventType = "GROUP_REMOVE_CURRENCY"
+	GroupSetDefaultCurrency EventType = "GROUP_SET_DEFAULT_CURRENCY"
+	GroupUserJoined         EventType = "GROUP_USER_JOINED"
+	UserNameChanged         EventType = "USER_NAME_CHANGED"
+	ExpenseCreated          EventType = "EXPENSE_CREATED"
+	ExpenseUpdated          EventType = "EXPENSE_UPDATED"
+	ExpenseDeleted          EventType = "EXPENSE_DELETED"
+	ExpenseRestored         EventType = "EXPENSE_RESTORED"
+)
+
+// User is a user as returned by the API
+type User struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// FirebaseID is empty when none is registered. The email address is never
+	// returned, see RegisterEmail.
+	FirebaseID        string    `json:"firebase_id,omitempty"`
+	EmailUnsubscribed bool      `json:"email_unsubscribed"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Group is a group as returned by the API
+type Group struct {
+	GroupID string `json:"group_id"`
+	Name    string `json:"name"`
+	// Version counts the events written to the group, see ExpectGroupVersion
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Event is an event of a group's log
+type Event struct {
+	EventID       string          `json:"event_id"`
+	LinkedEventID string          `json:"linked_event_id"`
+	GroupID       string          `json:"group_id"`
+	UserID        string          `json:"user_id"`
+	EventType     EventType       `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	// HLC is when the event was recorded, events are replayed in HLC order
+	HLC       hlc.HLC   `json:"hlc"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExpenseStatus tells whether an expense counts towards the balances of its group
+type ExpenseStatus string
+
+const (
+	ExpenseStatusActive  ExpenseStatus = "active"
+	ExpenseStatusDeleted ExpenseStatus = "deleted"
+)
+
+// Expense is the current state of an expense
+type Expense struct {
+	// ExpenseID is the ID of the EXPENSE_CREATED event
+	ExpenseID string        `json:"expense_id"`
+	GroupID   string        `json:"group_id"`
+	Status    ExpenseStatus `json:"status"`
+	// Payload is the expense as last edited
+	Payload json.RawMessage `json:"payload"`
+	// UserID, HLC and CreatedAt are those of the EXPENSE_CREATED event
+	UserID    string    `json:"user_id"`
+	HLC       hlc.HLC   `json:"hlc"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExpenseHistory is the revision chain of an expense, in HLC order
+type ExpenseHistory struct {
+	// ExpenseID is the ID of the EXPENSE_CREATED event
+	ExpenseID string `json:"expense_id"`
+	GroupID   string `json:"group_id"`
+	Deleted   bool   `json:"deleted"`
+	// Expense is the current state of the expense
+	Expense   json.RawMessage   `json:"expense"`
+	Revisions []ExpenseRevision `json:"revisions"`
+}
+
+// ExpenseRevision is one event of an expense's history
+type ExpenseRevision struct {
+	EventID   string    `json:"event_id"`
+	EventType EventType `json:"event_type"`
+	UserID    string    `json:"user_id"`
+	HLC       hlc.HLC   `json:"hlc"`
+	CreatedAt time.Time `json:"created_at"`
+	// Expense is the state of the expense after the revision
+	Expense json.RawMessage `json:"expense"`
+	// Changes lists the fields an EXPENSE_UPDATED event changed
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange is a field of an expense changed by a revision
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CreateUser creates a user. It is sent with an Idempotency-Key, so retries never create a second user.
+func (c *Client) CreateUser(ctx context.Context, name string) (*User, error) {
+	user := &User{}
+	err := c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/users",
+		body:       map[string]string{"name": name},
+		header:     idempotencyKey(),
+		idempotent: true,
+	}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUserWithID creates a user with an ID chosen by the caller, a version 4 or 7
+// UUID such as uuid.NewString(). A user created offline can be referenced by events
+// before it is sent. Sending it again returns the stored user, so it is retried.
+func (c *Client) CreateUserWithID(ctx context.Context, userID string, name string) (*User, error) {
+	user := &User{}
+	err := c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/users",
+		body:       map[string]string{"user_id": userID, "name": name},
+		idempotent: true,
+	}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUser returns a user
+func (c *Client) GetUser(ctx context.Context, userID string) (*User, error) {
+	user := &User{}
+	err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/v2/users/" + url.PathEscape(userID),
+		idempotent: true,
+	}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser renames a user
+func (c *Client) UpdateUser(ctx context.Context, userID string, name string) (*User, error) {
+	user := &User{}
+	err := c.do(ctx, request{
+		method:     http.MethodPut,
+		path:       "/api/v2/users/" + url.PathEscape(userID),
+		body:       map[string]string{"name": name},
+		idempotent: true,
+	}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteUser deletes a user
+func (c *Client) DeleteUser(ctx context.Context, userID string) error {
+	return c.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       "/api/v2/users/" + url.PathEscape(userID),
+		idempotent: true,
+	}, nil)
+}
+
+// RegisterFirebaseToken sets the token push notifications are sent to, an empty token removes it
+func (c *Client) RegisterFirebaseToken(ctx context.Context, userID string, token string) error {
+	return c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/users/" + url.PathEscape(userID) + "/firebase-token",
+		body:       map[string]string{"token": token},
+		idempotent: true,
+	}, nil)
+}
+
+// RegisterEmail sets the email address of a user, an empty address removes it
+func (c *Client) RegisterEmail(ctx context.Context, userID string, email string) error {
+	return c.do(ctx, request{
+		method:     http.MethodPost,
+		path:       "/api/v2/users/" + url.PathEscape(userID) + "/email",
+		body:       map[string]string{"email": email},
+		idempotent: true,
+	}, nil)
+}
@@ -0,0 +1,185 @@
+// Package hlc implements the hybrid logical clock timestamps events are ordered by.
+// The server and pkg/client share it, so both read and write the same text form.
+package hlc
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HLC is a hybrid logical clock timestamp: a wall clock time in milliseconds and
+// a counter ordering timestamps within the same millisecond. Its text form, e.g.
+// "001700000000000-00002", sorts in the same order as the timestamps, so
+// databases can order by it as a string. The zero HLC means none was given.
+type HLC struct {
+	Wall    int64
+	Logical uint16
+}
+
+// hlcLength is the length of the text form of an HLC
+const hlcLength = 21
+
+// maxHLCWall keeps the wall time within the 15 digits of the text form
+const maxHLCWall = 999_999_999_999_999
+
+// IsZero reports whether no timestamp is set
+func (h HLC) IsZero() bool {
+	return h == HLC{}
+}
+
+// Before reports whether h orders before other
+func (h HLC) Before(other HLC) bool {
+	return h.Compare(other) < 0
+}
+
+// Compare returns -1, 0 or +1 depending on whether h orders before, with or after other
+func (h HLC) Compare(other HLC) int {
+	if c := cmp.Compare(h.Wall, other.Wall); c != 0 {
+		return c
+	}
+	return cmp.Compare(h.Logical, other.Logical)
+}
+
+// Time returns the wall clock part of the timestamp
+func (h HLC) Time() time.Time {
+	return time.UnixMilli(h.Wall).UTC()
+}
+
+func (h HLC) String() string {
+	if h.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%015d-%05d", h.Wall, h.Logical)
+}
+
+// ParseHLC parses the text form of an HLC. An empty string is the zero HLC.
+func ParseHLC(value string) (HLC, error) {
+	if value == "" {
+		return HLC{}, nil
+	}
+	if len(value) != hlcLength || value[15] != '-' || !isDigits(value[:15]) || !isDigits(value[16:]) {
+		return HLC{}, fmt.Errorf("invalid HLC %q, want 15 digits of milliseconds, '-' and a 5 digit counter", value)
+	}
+	wall, _ := strconv.ParseInt(value[:15], 10, 64)
+	logical, err := strconv.ParseUint(value[16:], 10, 16)
+	if err != nil {
+		return HLC{}, fmt.Errorf("invalid HLC %q, the counter is at most %d", value, math.MaxUint16)
+	}
+	return HLC{Wall: wall, Logical: uint16(logical)}, nil
+}
+
+// isDigits reports whether value consists of ASCII digits only
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalText encodes the HLC as its text form, so it is a string in JSON
+func (h HLC) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText decodes the text form of an HLC
+func (h *HLC) UnmarshalText(text []byte) error {
+	parsed, err := ParseHLC(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// Value stores the HLC as its text form, or NULL when it is zero
+func (h HLC) Value() (driver.Value, error) {
+	if h.IsZero() {
+		return nil, nil
+	}
+	return h.String(), nil
+}
+
+// Scan reads an HLC stored by Value
+func (h *HLC) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*h = HLC{}
+		return nil
+	case string:
+		return h.UnmarshalText([]byte(value))
+	case []byte:
+		return h.UnmarshalText(value)
+	}
+	return fmt.Errorf("cannot scan %T into an HLC", src)
+}
+
+// ErrClockDrift is returned for a timestamp too far ahead of the local clock
+var ErrClockDrift = errors.New("timestamp is too far in the future")
+
+// Clock is the server's hybrid logical clock. Timestamps it issues are never
+// lower than any timestamp it has issued or been sent, so an event stamped
+// by the server orders after every event the server has already seen.
+type Clock struct {
+	mu       sync.Mutex
+	last     HLC
+	maxDrift time.Duration
+	now      func() time.Time
+}
+
+// NewClock creates a clock that accepts timestamps at most maxDrift ahead of
+// its wall clock. A maxDrift of zero accepts any timestamp.
+func NewClock(maxDrift time.Duration) *Clock {
+	return &Clock{
+		maxDrift: maxDrift,
+		now:      time.Now,
+	}
+}
+
+// Now issues a timestamp for a local event
+func (c *Clock) Now() HLC {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last = c.next(c.last)
+	return c.last
+}
+
+// Update merges a timestamp received from a client into the clock and issues a
+// timestamp after both. A timestamp more than maxDrift ahead of the wall clock
+// is rejected with ErrClockDrift and leaves the clock unchanged, so one device
+// with a wrong clock can't push the clock of every group into the future.
+func (c *Clock) Update(remote HLC) (HLC, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit := c.now().Add(c.maxDrift).UnixMilli(); c.maxDrift > 0 && remote.Wall > limit {
+		return HLC{}, fmt.Errorf("%w: %s is more than %v ahead of %s", ErrClockDrift, remote, c.maxDrift, c.now().UTC().Format(time.RFC3339))
+	}
+
+	latest := c.last
+	if latest.Before(remote) {
+		latest = remote
+	}
+	c.last = c.next(latest)
+	return c.last, nil
+}
+
+// next returns the timestamp following latest, taking the wall clock when it is ahead
+func (c *Clock) next(latest HLC) HLC {
+	if wall := min(c.now().UnixMilli(), maxHLCWall); wall > latest.Wall {
+		return HLC{Wall: wall}
+	}
+	if latest.Logical == math.MaxUint16 {
+		// The counter is spent, borrow the next millisecond
+		return HLC{Wall: latest.Wall + 1}
+	}
+	return HLC{Wall: latest.Wall, Logical: latest.Logical + 1}
+}
@@ -0,0 +1,81 @@
+package hlc
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHLCText(t *testing.T) {
+	h := HLC{Wall: 1_714_979_289_123, Logical: 7}
+	encoded, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `"001714979289123-00007"` {
+		t.Errorf("encoded as %s", encoded)
+	}
+
+	var decoded HLC
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != h {
+		t.Errorf("decoded %s as %+v, %v", encoded, decoded, err)
+	}
+
+	// The text form sorts like the timestamps
+	if later := (HLC{Wall: 1_714_979_289_124}); !(h.String() < later.String()) || !h.Before(later) {
+		t.Errorf("%s does not order before %s", h, later)
+	}
+
+	for _, invalid := range []string{"1714979289123-7", "001714979289123:00007", "+01714979289123-00007", "001714979289123-99999", "001714979289123-0000a"} {
+		if _, err := ParseHLC(invalid); err == nil {
+			t.Errorf("ParseHLC(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	wall := time.UnixMilli(1_000_000)
+	clock := NewClock(time.Minute)
+	clock.now = func() time.Time { return wall }
+
+	first := clock.Now()
+	if first != (HLC{Wall: 1_000_000}) {
+		t.Errorf("first timestamp is %s", first)
+	}
+	// The wall clock standing still or going back only advances the counter
+	if second := clock.Now(); second != (HLC{Wall: 1_000_000, Logical: 1}) {
+		t.Errorf("second timestamp is %s", second)
+	}
+	wall = wall.Add(-time.Second)
+	if third := clock.Now(); third != (HLC{Wall: 1_000_000, Logical: 2}) {
+		t.Errorf("timestamp after the wall clock went back is %s", third)
+	}
+
+	// A client ahead of the server moves the clock forward
+	remote := HLC{Wall: 1_030_000, Logical: 4}
+	merged, err := clock.Update(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !remote.Before(merged) || !remote.Before(clock.Now()) {
+		t.Errorf("merged %s to %s, want a later timestamp", remote, merged)
+	}
+
+	before := clock.Now()
+	if _, err := clock.Update(HLC{Wall: wall.Add(2 * time.Minute).UnixMilli()}); !errors.Is(err, ErrClockDrift) {
+		t.Errorf("Update with a timestamp 2 minutes ahead: got %v, want ErrClockDrift", err)
+	}
+	if after := clock.Now(); after.Wall != before.Wall {
+		t.Errorf("a rejected timestamp moved the clock from %s to %s", before, after)
+	}
+
+	// A spent counter borrows the next millisecond
+	if _, err := clock.Update(HLC{Wall: 1_030_000, Logical: math.MaxUint16}); err != nil {
+		t.Fatal(err)
+	}
+	if next := clock.Now(); next.Wall != 1_030_001 {
+		t.Errorf("timestamp after a spent counter is %s", next)
+	}
+}